@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+)
+
+// apiKeyCmd represents the apikey command
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Generate a REST API key",
+	Long: `Generate a random REST API key and its hash.
+The key is handed to the client, while the hash is added to auth.api_keys configuration.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := auth.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("key:  %s\nhash: %s\n", key, auth.HashKey(key))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(apiKeyCmd)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/spf13/viper"
+	"github.com/xn3cr0nx/email-service/internal/attachment"
 	"github.com/xn3cr0nx/email-service/internal/environment"
 )
 
@@ -11,6 +12,13 @@ func parseFlags(env *environment.Env) (err error) {
 	viper.SetDefault("debug", false)
 	viper.SetDefault("name", "Mailer")
 	viper.SetDefault("template_dir", "templates/")
+	viper.SetDefault("template_watch", false)
+	viper.SetDefault("templates.store", "")
+	viper.SetDefault("templates.store_dir", "template_versions/")
+	viper.SetDefault("templates.database.driver", "postgres")
+	viper.SetDefault("templates.database.url", "")
+	viper.SetDefault("templates.poll_interval", "30s")
+	viper.SetDefault("templates.asset_base", "")
 	viper.SetDefault("sender", "info@test.com")
 	viper.SetDefault("frontend_host", "https://frontend.com")
 	viper.SetDefault("concurrency", 10)
@@ -19,11 +27,39 @@ func parseFlags(env *environment.Env) (err error) {
 	viper.SetDefault("http.port", 8080)
 	viper.SetDefault("provider", "postmark")
 	viper.SetDefault("backend", "nats")
+	viper.SetDefault("auth.disabled", false)
+	viper.SetDefault("rate_limit.rate", 0)
+	viper.SetDefault("rate_limit.burst", 0)
+	viper.SetDefault("recipients.check", false)
+	viper.SetDefault("recipients.mx_lookup", false)
+	viper.SetDefault("recipients.disposable_domains", "")
+	viper.SetDefault("links.enable", false)
+	viper.SetDefault("links.ttl", "24h")
+	viper.SetDefault("verify.enable", false)
+	viper.SetDefault("verify.ttl", "10m")
+	viper.SetDefault("verify.length", 6)
+	viper.SetDefault("verify.max_attempts", 5)
+	viper.SetDefault("verify.cooldown", "1m")
+	viper.SetDefault("verify.path", "/verify")
+	viper.SetDefault("verify.secret", "")
+	viper.SetDefault("tracking.enable", false)
+	viper.SetDefault("tracking.base_url", "")
+	viper.SetDefault("tracking.secret", "")
+	viper.SetDefault("tracking.opens", false)
+	viper.SetDefault("tracking.clicks", true)
+	viper.SetDefault("tracking.retention", "720h")
+	viper.SetDefault("attachments.dir", "")
+	viper.SetDefault("attachments.max_size", 10<<20)
+	viper.SetDefault("attachments.forbidden_extensions", attachment.DefaultForbiddenExtensions)
 	viper.SetDefault("postmark.server", "")
 	viper.SetDefault("postmark.account", "")
 	viper.SetDefault("sendgrid.api_key", "")
 	viper.SetDefault("mailgun.domain", "")
 	viper.SetDefault("mailgun.api_key", "")
+	viper.SetDefault("smtp.host", "localhost")
+	viper.SetDefault("smtp.port", 587)
+	viper.SetDefault("smtp.username", "")
+	viper.SetDefault("smtp.password", "")
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", 6379)
 	viper.SetDefault("redis.password", "")
@@ -46,19 +82,26 @@ func parseFlags(env *environment.Env) (err error) {
 	rootCmd.Flags().BoolVarP(&env.Debug, "debug", "d", viper.GetBool("debug"), "Sets logging level to Debug")
 	rootCmd.Flags().StringVarP(&env.ServiceName, "name", "n", viper.GetString("name"), "Set service name")
 	rootCmd.Flags().StringVar(&env.TemplateDir, "template_dir", viper.GetString("template_dir"), "Define templates folder path")
+	rootCmd.Flags().BoolVar(&env.TemplateWatch, "template_watch", viper.GetBool("template_watch"), "Reload templates when they change")
 	rootCmd.Flags().StringVar(&env.Sender, "sender", viper.GetString("sender"), "Set emails sender")
 	rootCmd.Flags().StringVar(&env.FrontendHost, "frontend_host", viper.GetString("frontend_host"), "Set frontend host")
 	rootCmd.Flags().IntVar(&env.Concurrency, "concurrency", viper.GetInt("concurrency"), "Define templates folder path")
 	rootCmd.Flags().StringVar(&env.Queue, "queue", viper.GetString("queue"), "Set queue broker name")
 	rootCmd.Flags().StringVarP(&env.Host, "host", "s", viper.GetString("http.host"), "bind http server to host")
 	rootCmd.Flags().IntVarP(&env.Port, "port", "p", viper.GetInt("http.port"), "Bind http server to port")
-	rootCmd.Flags().StringVar(&env.Provider, "provider", viper.GetString("provider"), "Define which email provider the service is configured to rely on - Options: postmark, sendgrid, mailgun")
+	rootCmd.Flags().StringVar(&env.Provider, "provider", viper.GetString("provider"), "Define which email provider the service is configured to rely on - Options: postmark, sendgrid, mailgun, smtp")
 	rootCmd.Flags().StringVar(&env.Backend, "backend", viper.GetString("backend"), "Define which backend the service is configured to rely on - Options: asynq, kafka, nats")
+	rootCmd.Flags().Float64Var(&env.RateLimit, "rate_limit", viper.GetFloat64("rate_limit.rate"), "Set max emails per second sent through the provider, 0 means unlimited")
+	rootCmd.Flags().IntVar(&env.RateLimitBurst, "rate_limit_burst", viper.GetInt("rate_limit.burst"), "Set max burst of emails sent through the provider")
 	rootCmd.Flags().StringVar(&env.PostmarkServer, "postmark_server", viper.GetString("postmark.server"), "Set postmark server key")
 	rootCmd.Flags().StringVar(&env.PostmarkAccount, "postmark_account", viper.GetString("postmark.account"), "Set postmark account key")
 	rootCmd.Flags().StringVar(&env.SendgridAPIKey, "sendgrid_api_key", viper.GetString("sendgrid.api_key"), "Set sendgrid api key")
 	rootCmd.Flags().StringVar(&env.MailgunDomain, "mailgun_domain", viper.GetString("mailgun.domain"), "Set mailgun domain")
 	rootCmd.Flags().StringVar(&env.MailgunAPIKey, "mailgun_api_key", viper.GetString("mailgun.api_key"), "Set mailgun api key")
+	rootCmd.Flags().StringVar(&env.SMTPHost, "smtp_host", viper.GetString("smtp.host"), "Set smtp relay host")
+	rootCmd.Flags().IntVar(&env.SMTPPort, "smtp_port", viper.GetInt("smtp.port"), "Set smtp relay port")
+	rootCmd.Flags().StringVar(&env.SMTPUsername, "smtp_username", viper.GetString("smtp.username"), "Set smtp relay username")
+	rootCmd.Flags().StringVar(&env.SMTPPassword, "smtp_password", viper.GetString("smtp.password"), "Set smtp relay password")
 	rootCmd.Flags().StringVar(&env.RedisHost, "redis_host", viper.GetString("redis.host"), "Set host for redis backend")
 	rootCmd.Flags().IntVar(&env.RedisPort, "redis_port", viper.GetInt("redis.port"), "Set port for redis backend")
 	rootCmd.Flags().StringVar(&env.RedisPassword, "redis_password", viper.GetString("redis.password"), "Set password for redis backend")
@@ -90,6 +133,9 @@ func parseFlags(env *environment.Env) (err error) {
 	if err = viper.BindPFlag("template_dir", rootCmd.Flags().Lookup("template_dir")); err != nil {
 		return
 	}
+	if err = viper.BindPFlag("template_watch", rootCmd.Flags().Lookup("template_watch")); err != nil {
+		return
+	}
 	if err = viper.BindPFlag("sender", rootCmd.Flags().Lookup("sender")); err != nil {
 		return
 	}
@@ -115,6 +161,12 @@ func parseFlags(env *environment.Env) (err error) {
 	if err = viper.BindPFlag("backend", rootCmd.Flags().Lookup("backend")); err != nil {
 		return
 	}
+	if err = viper.BindPFlag("rate_limit.rate", rootCmd.Flags().Lookup("rate_limit")); err != nil {
+		return
+	}
+	if err = viper.BindPFlag("rate_limit.burst", rootCmd.Flags().Lookup("rate_limit_burst")); err != nil {
+		return
+	}
 	if err = viper.BindPFlag("postmark.server", rootCmd.Flags().Lookup("postmark_server")); err != nil {
 		return
 	}
@@ -130,6 +182,18 @@ func parseFlags(env *environment.Env) (err error) {
 	if err = viper.BindPFlag("mailgun.api_key", rootCmd.Flags().Lookup("mailgun_api_key")); err != nil {
 		return
 	}
+	if err = viper.BindPFlag("smtp.host", rootCmd.Flags().Lookup("smtp_host")); err != nil {
+		return
+	}
+	if err = viper.BindPFlag("smtp.port", rootCmd.Flags().Lookup("smtp_port")); err != nil {
+		return
+	}
+	if err = viper.BindPFlag("smtp.username", rootCmd.Flags().Lookup("smtp_username")); err != nil {
+		return
+	}
+	if err = viper.BindPFlag("smtp.password", rootCmd.Flags().Lookup("smtp_password")); err != nil {
+		return
+	}
 	if err = viper.BindPFlag("redis.host", rootCmd.Flags().Lookup("redis_host")); err != nil {
 		return
 	}
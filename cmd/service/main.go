@@ -18,6 +18,10 @@ import (
 //
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
+// @name X-API-Key
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
 // @name Authorization
 func main() {
 	Execute()
@@ -2,25 +2,37 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"strings"
 	"time"
 
 	"github.com/hibiken/asynq"
+	_ "github.com/lib/pq"
 	"github.com/nats-io/nats.go"
 	"github.com/segmentio/kafka-go"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/xn3cr0nx/email-service/internal/attachment"
 	"github.com/xn3cr0nx/email-service/internal/backend"
+	"github.com/xn3cr0nx/email-service/internal/email"
 	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/link"
+	"github.com/xn3cr0nx/email-service/internal/preference"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/provider/postmark"
 	"github.com/xn3cr0nx/email-service/internal/provider/sendgrid"
+	"github.com/xn3cr0nx/email-service/internal/provider/smtp"
+	"github.com/xn3cr0nx/email-service/internal/sender"
 	"github.com/xn3cr0nx/email-service/internal/server"
 	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+	"github.com/xn3cr0nx/email-service/internal/verify"
 	"github.com/xn3cr0nx/email-service/pkg/logger"
 	"github.com/xn3cr0nx/email-service/pkg/meter"
 	"github.com/xn3cr0nx/email-service/pkg/tracer"
@@ -111,27 +123,149 @@ func run(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	store, err := templateStore(ctx)
+	if err != nil {
+		logger.Error("Email Service", fmt.Errorf("cannot initialize template store: %w", err), logger.Params{})
+		os.Exit(-1)
+	}
+	if store != nil {
+		template.SetStore(store)
+	}
+
+	if err := template.SetAssetBase(viper.GetString("templates.asset_base")); err != nil {
+		logger.Error("Email Service", err, logger.Params{})
+		os.Exit(-1)
+	}
+
 	// initialize template cache
 	templateDir := env.TemplateDir
 	if templateDir == "" {
 		templateDir = "templates/"
 	}
-	_, err := template.NewTemplateCache(&templateDir)
+	cache, err := template.NewTemplateCache(&templateDir)
 	if err != nil {
 		logger.Error("Email Service", fmt.Errorf("cannot initialize template cache: %w", err), logger.Params{})
 		os.Exit(-1)
 	}
+	if err := cache.Check(); err != nil {
+		logger.Error("Email Service", fmt.Errorf("invalid templates: %w", err), logger.Params{})
+		os.Exit(-1)
+	}
+	if env.TemplateWatch {
+		if err := cache.Watch(ctx); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot watch templates: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+	}
+	// templates published through other instances are applied by polling
+	if interval := viper.GetDuration("templates.poll_interval"); store != nil && interval > 0 {
+		if err := cache.Poll(ctx, interval); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot poll published templates: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+	}
+
+	preference.Set(preference.NewMemoryStore())
+
+	// link keys are configured as list of objects with id, algorithm (HS256 or
+	// EdDSA), secret or private_key and not_before fields
+	if viper.GetBool("links.enable") {
+		var conf link.Config
+		if err := viper.UnmarshalKey("links", &conf); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot read links configuration: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		signer, err := link.New(env.FrontendHost, conf)
+		if err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot initialize links: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		link.Set(signer)
+	}
+
+	if viper.GetBool("verify.enable") {
+		var conf verify.Config
+		if err := viper.UnmarshalKey("verify", &conf); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot read verify configuration: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		v, err := verify.New(env.FrontendHost, conf, verify.NewMemoryStore())
+		if err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot initialize verification: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		verify.Set(v)
+	}
+
+	if viper.GetBool("tracking.enable") {
+		var conf tracking.Config
+		if err := viper.UnmarshalKey("tracking", &conf); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot read tracking configuration: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		t, err := tracking.New(conf)
+		if err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot initialize tracking: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		tracking.SetTracker(t)
+		tracking.SetStore(tracking.NewMemoryStore(conf.Retention))
+	}
+
+	// sender identities are configured as list of objects with id, address,
+	// name, reply_to, types and clients fields
+	var identities []sender.Identity
+	if err := viper.UnmarshalKey("senders", &identities); err != nil {
+		logger.Error("Email Service", fmt.Errorf("cannot read sender identities: %w", err), logger.Params{})
+		os.Exit(-1)
+	}
+	if len(identities) > 0 {
+		registry, err := sender.NewRegistry(identities)
+		if err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot initialize sender identities: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
+		sender.Set(registry)
+	}
+
+	checker, err := recipientChecker()
+	if err != nil {
+		logger.Error("Email Service", fmt.Errorf("cannot initialize recipient checker: %w", err), logger.Params{})
+		os.Exit(-1)
+	}
+	email.SetChecker(checker)
+
+	conf := &attachment.Config{
+		MaxSize:             viper.GetInt64("attachments.max_size"),
+		ForbiddenExtensions: viper.GetStringSlice("attachments.forbidden_extensions"),
+	}
+	if dir := viper.GetString("attachments.dir"); dir != "" {
+		conf.Store = os.DirFS(dir)
+	}
+	attachment.Set(conf)
 
 	var mailer provider.Mailer
 	if env.Provider == "postmark" {
 		mailer = postmark.NewClient(viper.GetString("postmark.server"), viper.GetString("postmark.account"))
 	} else if env.Provider == "sendgrid" {
 		mailer = sendgrid.NewClient(env.SendgridAPIKey)
+	} else if env.Provider == "smtp" {
+		mailer = smtp.NewClient(smtp.Config{
+			Host:     env.SMTPHost,
+			Port:     env.SMTPPort,
+			Username: env.SMTPUsername,
+			Password: env.SMTPPassword,
+		}, sender.Signer)
 	} else {
 		logger.Error("Email service", errInvalidProvider, logger.Params{"env": env.Backend})
 		os.Exit(-1)
 	}
 
+	if mailer, err = rateLimit(env, mailer); err != nil {
+		logger.Error("Email Service", fmt.Errorf("cannot configure rate limit: %w", err), logger.Params{})
+		os.Exit(-1)
+	}
+
 	if env.Rest {
 		s := server.NewServer(env.Port, mailer, tr, mt)
 		go s.Listen()
@@ -139,12 +273,13 @@ func run(cmd *cobra.Command, args []string) {
 
 	if env.Backend == "asynq" {
 		redisAddress := fmt.Sprintf("%s:%d", env.RedisHost, env.RedisPort)
+		redis := asynq.RedisClientOpt{
+			Addr:     redisAddress,
+			Password: env.RedisPassword,
+			DB:       env.RedisDB,
+		}
 		server := asynq.NewServer(
-			asynq.RedisClientOpt{
-				Addr:     redisAddress,
-				Password: env.RedisPassword,
-				DB:       env.RedisDB,
-			},
+			redis,
 			asynq.Config{
 				Concurrency: env.Concurrency,
 				Queues: map[string]int{
@@ -154,6 +289,16 @@ func run(cmd *cobra.Command, args []string) {
 		defer server.Stop()
 
 		h := backend.NewEmailHandler(mailer, tr, mt)
+		if h == nil {
+			logger.Error("Email Service", errors.New("cannot initialize queue handler metrics"), logger.Params{})
+			os.Exit(-1)
+		}
+		inspector := asynq.NewInspector(redis)
+		defer inspector.Close()
+		if err := h.ObserveQueue(inspector, env.Queue); err != nil {
+			logger.Error("Email Service", fmt.Errorf("cannot observe queue: %w", err), logger.Params{})
+			os.Exit(-1)
+		}
 		if err := server.Run(h); err != nil {
 			logger.Error("Email Service", fmt.Errorf("cannot start queue server %v", err), logger.Params{})
 		}
@@ -200,6 +345,76 @@ func run(cmd *cobra.Command, args []string) {
 	}
 }
 
+// recipientChecker returns the recipient checker extended with the disposable
+// domains listed in recipients.disposable_domains file, performing MX lookups
+// if recipients.mx_lookup is set. Flagged recipients are rejected before
+// sending if recipients.check is set.
+func recipientChecker() (*email.Checker, error) {
+	var resolver email.Resolver
+	if viper.GetBool("recipients.mx_lookup") {
+		resolver = net.DefaultResolver
+	}
+	var lists []io.Reader
+	if path := viper.GetString("recipients.disposable_domains"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		lists = append(lists, f)
+	}
+	checker, err := email.NewChecker(resolver, lists...)
+	if err != nil {
+		return nil, err
+	}
+	checker.Enforce = viper.GetBool("recipients.check")
+	return checker, nil
+}
+
+// templateStore returns the template versions store configured in
+// templates.store, either fs, persisting versions in templates.store_dir, or
+// sql, connecting to templates.database.url with templates.database.driver.
+// Without a store only the template files are used.
+func templateStore(ctx context.Context) (template.TemplateStore, error) {
+	switch kind := viper.GetString("templates.store"); kind {
+	case "":
+		return nil, nil
+	case "fs":
+		return template.NewFileStore(viper.GetString("templates.store_dir"))
+	case "sql":
+		db, err := sql.Open(viper.GetString("templates.database.driver"), viper.GetString("templates.database.url"))
+		if err != nil {
+			return nil, err
+		}
+		return template.NewSQLStore(ctx, db)
+	default:
+		return nil, fmt.Errorf("unknown template store %s, options: fs, sql", kind)
+	}
+}
+
+// rateLimit wraps the mailer with the rate limits configured for the provider
+// and for recipient domains. Provider specific limits are read from
+// rate_limit.providers.<provider>, domain limits from rate_limit.domains.
+func rateLimit(env *environment.Env, mailer provider.Mailer) (provider.Mailer, error) {
+	conf := &provider.RateLimitConfig{
+		Provider: provider.Limit{Rate: env.RateLimit, Burst: env.RateLimitBurst},
+	}
+	if viper.IsSet("rate_limit.providers." + env.Provider) {
+		if err := viper.UnmarshalKey("rate_limit.providers."+env.Provider, &conf.Provider); err != nil {
+			return nil, err
+		}
+	}
+	if err := viper.UnmarshalKey("rate_limit.domains", &conf.Domains); err != nil {
+		return nil, err
+	}
+
+	if conf.Provider.Rate <= 0 && len(conf.Domains) == 0 {
+		return mailer, nil
+	}
+	logger.Info("Email Service", "Rate limit enabled", logger.Params{"rate": conf.Provider.Rate, "burst": conf.Provider.Burst, "domains": len(conf.Domains)})
+	return provider.NewRateLimitedMailer(mailer, conf), nil
+}
+
 var (
 	errMissingPort            = errors.New("missing server port")
 	errMissingRedisAddress    = errors.New("missing redis address")
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+// templatesCmd represents the templates command
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage email templates",
+}
+
+// lintCmd represents the templates lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [dir]",
+	Short: "Lint email templates",
+	Long: `Check the templates of the directory, template_dir by default, for params not
+matching the schema of the template type, missing messages, unbalanced html,
+images without alt text, non HTTPS links and emails exceeding the Gmail
+clipping size. Exits with a non-zero status if errors are found, or warnings
+with --strict.`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := viper.GetString("template_dir")
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		strict, err := cmd.Flags().GetBool("strict")
+		if err != nil {
+			return err
+		}
+
+		issues, err := template.Lint(dir)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Severity == template.SeverityError || strict {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d template issues found in %s", failed, dir)
+		}
+		fmt.Printf("templates in %s are valid (%d warnings)\n", dir, len(issues))
+		return nil
+	},
+}
+
+func init() {
+	lintCmd.Flags().Bool("strict", false, "Fail on warnings too")
+	templatesCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(templatesCmd)
+}
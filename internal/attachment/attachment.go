@@ -0,0 +1,217 @@
+package attachment
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+var (
+	errMissingName         = errors.New("missing attachment name")
+	errMissingContent      = errors.New("attachment requires either content or path")
+	errInvalidContent      = errors.New("invalid base64 attachment content")
+	errForbiddenExtension  = errors.New("forbidden attachment extension")
+	errPathDisabled        = errors.New("attachments by path are not enabled")
+	errInvalidPath         = errors.New("invalid attachment path")
+	errAttachmentNotFound  = errors.New("attachment file not found")
+	errAttachmentsTooLarge = errors.New("attachments exceed the max message size")
+)
+
+// DefaultForbiddenExtensions executable and script extensions rejected by default
+var DefaultForbiddenExtensions = []string{
+	".bat", ".cmd", ".com", ".cpl", ".dll", ".exe", ".hta", ".jar", ".js",
+	".jse", ".lnk", ".msi", ".pif", ".ps1", ".reg", ".scr", ".sh", ".vbe",
+	".vbs", ".wsf",
+}
+
+// Attachment of an email request, either with base64 encoded content or with
+// the path of a file in the attachment store
+type Attachment struct {
+	Name string `json:"name,omitempty"`
+	// Content: base64 encoded attachment data
+	Content string `json:"content,omitempty"`
+	// Path: path of the file in the attachment store, alternative to Content
+	Path string `json:"path,omitempty"`
+	// ContentType: MIME type, sniffed from the content if missing
+	ContentType string `json:"content_type,omitempty"`
+	// ContentID: populate for inlining images with the images cid
+	ContentID string `json:"content_id,omitempty"`
+}
+
+// Config attachment store and limits. MaxSize is the max total size in bytes
+// of the attachments of a message, zero means unlimited.
+type Config struct {
+	Store               fs.FS
+	MaxSize             int64
+	ForbiddenExtensions []string
+}
+
+var config = &Config{ForbiddenExtensions: DefaultForbiddenExtensions}
+
+// Set assign the shared global attachment configuration
+func Set(c *Config) {
+	config = c
+}
+
+// Get returns the shared global attachment configuration
+func Get() *Config {
+	return config
+}
+
+// Validate checks the attachments of a request, without reading their content
+func Validate(errs *validator.FieldErrors, attachments []Attachment) {
+	for i, a := range attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+		if a.Name == "" {
+			errs.Add(field+".name", errMissingName)
+		} else if config.forbidden(a.Name) {
+			errs.Add(field+".name", fmt.Errorf("%w: %s", errForbiddenExtension, path.Ext(a.Name)))
+		}
+		if (a.Content == "") == (a.Path == "") {
+			errs.Add(field, errMissingContent)
+		}
+	}
+}
+
+// Resolve loads the attachments content, from the request or from the store,
+// detecting missing content types and checking the message size limit. The
+// content exceeding the limit is not decoded nor read.
+func Resolve(attachments []Attachment) ([]model.Attachment, error) {
+	var errs validator.FieldErrors
+	Validate(&errs, attachments)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	var size int64
+	resolved := make([]model.Attachment, 0, len(attachments))
+	for i, a := range attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+		content, err := config.load(a, config.MaxSize-size)
+		if errors.Is(err, errAttachmentsTooLarge) {
+			errs.Add("attachments", fmt.Errorf("%w: max %d bytes", errAttachmentsTooLarge, config.MaxSize))
+			break
+		}
+		if err != nil {
+			errs.Add(field, err)
+			continue
+		}
+		size += int64(len(content))
+
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = sniff(a.Name, content)
+		}
+		resolved = append(resolved, model.Attachment{
+			Name:        a.Name,
+			Content:     base64.StdEncoding.EncodeToString(content),
+			ContentType: contentType,
+			ContentID:   a.ContentID,
+		})
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// CheckSize checks the total size of the attachments of a message, including
+// the generated ones, e.g. receipts and calendar invites, against the message
+// size limit
+func CheckSize(attachments []model.Attachment) error {
+	if config.MaxSize <= 0 {
+		return nil
+	}
+	var size int64
+	for _, a := range attachments {
+		size += decodedLen(a.Content)
+	}
+	if size > config.MaxSize {
+		var errs validator.FieldErrors
+		errs.Add("attachments", fmt.Errorf("%w: %d bytes, max %d", errAttachmentsTooLarge, size, config.MaxSize))
+		return errs.Err()
+	}
+	return nil
+}
+
+// forbidden checks the file name extension against the forbidden ones
+func (c *Config) forbidden(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, forbidden := range c.ForbiddenExtensions {
+		if ext == strings.ToLower(forbidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the decoded content or reads the file from the store,
+// errAttachmentsTooLarge if it exceeds the remaining bytes of MaxSize
+func (c *Config) load(a Attachment, remaining int64) ([]byte, error) {
+	if a.Content != "" {
+		// the size is checked before decoding, line breaks may overestimate it
+		if c.MaxSize > 0 && decodedLen(a.Content) > remaining {
+			return nil, errAttachmentsTooLarge
+		}
+		content, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return nil, errInvalidContent
+		}
+		return content, nil
+	}
+
+	if c.Store == nil {
+		return nil, errPathDisabled
+	}
+	name := strings.TrimPrefix(a.Path, "/")
+	if !fs.ValidPath(name) || name == "." {
+		return nil, errInvalidPath
+	}
+	f, err := c.Store.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errAttachmentNotFound, a.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if c.MaxSize <= 0 {
+		return io.ReadAll(f)
+	}
+	content, err := io.ReadAll(io.LimitReader(f, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > remaining {
+		return nil, errAttachmentsTooLarge
+	}
+	return content, nil
+}
+
+// decodedLen returns the length of the base64 encoded content once decoded
+func decodedLen(content string) int64 {
+	padding := len(content) - len(strings.TrimRight(content, "="))
+	return int64(base64.StdEncoding.DecodedLen(len(content)) - padding)
+}
+
+// sniff detects the content type from the content, relying on the file
+// extension when the content is not recognized
+func sniff(name string, content []byte) string {
+	contentType := http.DetectContentType(content)
+	if strings.HasPrefix(contentType, "application/octet-stream") || strings.HasPrefix(contentType, "text/plain") {
+		if byExtension := mime.TypeByExtension(path.Ext(name)); byExtension != "" {
+			return byExtension
+		}
+	}
+	return contentType
+}
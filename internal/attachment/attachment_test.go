@@ -0,0 +1,84 @@
+package attachment_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing/fstest"
+
+	"github.com/xn3cr0nx/email-service/internal/attachment"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+func (s *AttachmentTestSuite) TestResolve() {
+	attachment.Set(&attachment.Config{
+		Store: fstest.MapFS{
+			"receipts/1.pdf": {Data: []byte("%PDF-1.4 receipt")},
+			"notes.csv":      {Data: []byte("a,b\n1,2\n")},
+		},
+		ForbiddenExtensions: attachment.DefaultForbiddenExtensions,
+	})
+
+	resolved, err := attachment.Resolve([]attachment.Attachment{
+		{Name: "receipt.pdf", Path: "/receipts/1.pdf"},
+		{Name: "notes.csv", Path: "notes.csv"},
+		{Name: "logo.png", Content: base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nfake")), ContentID: "logo"},
+		{Name: "data.bin", Content: base64.StdEncoding.EncodeToString([]byte{0, 1, 2}), ContentType: "application/x-custom"},
+	})
+	s.Nil(err)
+	s.Len(resolved, 4)
+	s.Equal("application/pdf", resolved[0].ContentType)
+	s.Equal(base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 receipt")), resolved[0].Content)
+	s.Equal("text/csv; charset=utf-8", resolved[1].ContentType)
+	s.Equal("image/png", resolved[2].ContentType)
+	s.Equal("logo", resolved[2].ContentID)
+	s.Equal("application/x-custom", resolved[3].ContentType)
+}
+
+func (s *AttachmentTestSuite) TestResolveErrors() {
+	attachment.Set(&attachment.Config{
+		Store:               fstest.MapFS{"big.pdf": {Data: make([]byte, 20)}},
+		MaxSize:             10,
+		ForbiddenExtensions: attachment.DefaultForbiddenExtensions,
+	})
+
+	for _, invalid := range [][]attachment.Attachment{
+		{{Name: "run.EXE", Content: "AAAA"}},
+		{{Content: "AAAA"}},
+		{{Name: "empty.txt"}},
+		{{Name: "both.txt", Content: "AAAA", Path: "big.pdf"}},
+		{{Name: "invalid.txt", Content: "not base64!"}},
+		{{Name: "missing.pdf", Path: "missing.pdf"}},
+		{{Name: "secret", Path: "../etc/passwd"}},
+		{{Name: "big.pdf", Path: "big.pdf"}},
+		{{Name: "big.bin", Content: base64.StdEncoding.EncodeToString(make([]byte, 11))}},
+		{
+			{Name: "half.bin", Content: base64.StdEncoding.EncodeToString(make([]byte, 6))},
+			{Name: "other.bin", Content: base64.StdEncoding.EncodeToString(make([]byte, 6))},
+		},
+	} {
+		_, err := attachment.Resolve(invalid)
+		var fields validator.FieldErrors
+		s.True(errors.As(err, &fields), invalid[0].Name)
+	}
+
+	attachment.Set(&attachment.Config{})
+	_, err := attachment.Resolve([]attachment.Attachment{{Name: "file.pdf", Path: "file.pdf"}})
+	s.NotNil(err)
+}
+
+func (s *AttachmentTestSuite) TestCheckSize() {
+	attachment.Set(&attachment.Config{MaxSize: 10, ForbiddenExtensions: attachment.DefaultForbiddenExtensions})
+	defer attachment.Set(&attachment.Config{ForbiddenExtensions: attachment.DefaultForbiddenExtensions})
+
+	resolved, err := attachment.Resolve([]attachment.Attachment{{Name: "exact.bin", Content: base64.StdEncoding.EncodeToString(make([]byte, 10))}})
+	s.Nil(err)
+	s.Nil(attachment.CheckSize(resolved))
+
+	// generated attachments count toward the limit
+	invite := model.Attachment{Name: "invite.ics", Content: base64.StdEncoding.EncodeToString([]byte("BEGIN:VCALENDAR"))}
+	err = attachment.CheckSize(append(resolved, invite))
+	var fields validator.FieldErrors
+	s.True(errors.As(err, &fields))
+	s.Equal("attachments", fields[0].Field)
+}
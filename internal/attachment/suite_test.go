@@ -0,0 +1,25 @@
+package attachment_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/attachment"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(AttachmentTestSuite))
+}
+
+type AttachmentTestSuite struct {
+	suite.Suite
+	config *attachment.Config
+}
+
+func (s *AttachmentTestSuite) SetupTest() {
+	s.config = attachment.Get()
+}
+
+func (s *AttachmentTestSuite) TearDownTest() {
+	attachment.Set(s.config)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// APIKey configuration of a client authenticated by API key. Only the SHA-256
+// hash of the key is stored. RateLimit is expressed in requests per second,
+// zero values mean no limit.
+type APIKey struct {
+	Client     `mapstructure:",squash"`
+	Hash       string  `mapstructure:"hash"`
+	RateLimit  float64 `mapstructure:"rate_limit"`
+	Burst      int     `mapstructure:"burst"`
+	DailyQuota int     `mapstructure:"daily_quota"`
+}
+
+// KeyStore interface exports methods to lookup API keys
+type KeyStore interface {
+	Lookup(ctx context.Context, hash string) (*APIKey, error)
+}
+
+// MemoryKeyStore in memory implementation of KeyStore, usually loaded from config
+type MemoryKeyStore struct {
+	keys map[string]*APIKey
+}
+
+// NewMemoryKeyStore returns a key store indexing the keys by hash
+func NewMemoryKeyStore(keys []APIKey) *MemoryKeyStore {
+	s := &MemoryKeyStore{keys: make(map[string]*APIKey, len(keys))}
+	for i := range keys {
+		s.keys[strings.ToLower(keys[i].Hash)] = &keys[i]
+	}
+	return s
+}
+
+// Lookup returns the API key matching the hash, errorx.ErrNotFound otherwise
+func (s *MemoryKeyStore) Lookup(ctx context.Context, hash string) (*APIKey, error) {
+	key, ok := s.keys[strings.ToLower(hash)]
+	if !ok {
+		return nil, errorx.ErrNotFound
+	}
+	return key, nil
+}
+
+// Len returns the number of stored keys
+func (s *MemoryKeyStore) Len() int {
+	return len(s.keys)
+}
+
+// HashKey returns the hex encoded SHA-256 hash of the API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
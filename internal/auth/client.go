@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized returned when the caller credentials are missing or invalid
+	ErrUnauthorized = errors.New("missing or invalid credentials")
+	// ErrForbidden returned when the caller is not allowed to perform the request
+	ErrForbidden = errors.New("client not allowed to send the email")
+	// ErrNoPermission returned when the caller lacks the permission required
+	// by the request
+	ErrNoPermission = errors.New("client not allowed to perform the request")
+)
+
+// Permissions granted to clients for administrative requests
+const (
+	// TemplatesPermission permission to create and publish template versions
+	TemplatesPermission = "templates"
+	// PreferencesPermission permission to read and update recipient preferences
+	PreferencesPermission = "preferences"
+)
+
+// Client identity of the authenticated caller of the REST API. Empty Senders
+// or Types lists mean any sender or email type is allowed, Permissions grant
+// administrative requests and must be listed explicitly.
+type Client struct {
+	Name        string   `json:"name" mapstructure:"name"`
+	Senders     []string `json:"senders,omitempty" mapstructure:"senders"`
+	Types       []string `json:"types,omitempty" mapstructure:"types"`
+	Permissions []string `json:"permissions,omitempty" mapstructure:"permissions"`
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of the context carrying the client identity
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// FromContext returns the client identity carried by the context, nil if the
+// request is not authenticated (e.g. queue backends)
+func FromContext(ctx context.Context) *Client {
+	c, _ := ctx.Value(clientKey{}).(*Client)
+	return c
+}
+
+// Authorize returns ErrForbidden if the client carried by the context is not
+// allowed to send the email type from the sender address. Unauthenticated
+// contexts are always authorized.
+func Authorize(ctx context.Context, taskType, from string) error {
+	c := FromContext(ctx)
+	if c == nil {
+		return nil
+	}
+	if !c.AllowsType(taskType) || !c.AllowsSender(from) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Permit returns ErrNoPermission if the client carried by the context lacks
+// the permission. Unauthenticated contexts, i.e. authentication is not
+// configured, are always permitted.
+func Permit(ctx context.Context, permission string) error {
+	c := FromContext(ctx)
+	if c == nil || c.HasPermission(permission) {
+		return nil
+	}
+	return ErrNoPermission
+}
+
+// HasPermission returns true if the permission has been granted to the client
+func (c *Client) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsType returns true if the client is allowed to send the email type
+func (c *Client) AllowsType(taskType string) bool {
+	if len(c.Types) == 0 {
+		return true
+	}
+	for _, t := range c.Types {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSender returns true if the client is allowed to send from the address.
+// Senders starting with @ allow any address of the domain.
+func (c *Client) AllowsSender(from string) bool {
+	if len(c.Senders) == 0 {
+		return true
+	}
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	from = strings.ToLower(strings.TrimSpace(from))
+	for _, sender := range c.Senders {
+		sender = strings.ToLower(sender)
+		if sender == from || (strings.HasPrefix(sender, "@") && strings.HasSuffix(from, sender)) {
+			return true
+		}
+	}
+	return false
+}
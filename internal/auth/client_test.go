@@ -0,0 +1,48 @@
+package auth_test
+
+import (
+	"context"
+
+	"github.com/xn3cr0nx/email-service/internal/auth"
+)
+
+func (s *AuthTestSuite) TestAuthorize() {
+	ctx := context.Background()
+	s.Nil(auth.Authorize(ctx, "email:welcome", "any@test.com"))
+
+	ctx = auth.WithClient(ctx, &auth.Client{
+		Name:    "test",
+		Senders: []string{"info@test.com", "@notify.test.com"},
+		Types:   []string{"email:welcome"},
+	})
+	s.Nil(auth.Authorize(ctx, "email:welcome", "Info <INFO@test.com>"))
+	s.Nil(auth.Authorize(ctx, "email:welcome", "alerts@notify.test.com"))
+	s.ErrorIs(auth.Authorize(ctx, "email:welcome", "other@test.com"), auth.ErrForbidden)
+	s.ErrorIs(auth.Authorize(ctx, "email:reset", "info@test.com"), auth.ErrForbidden)
+}
+
+func (s *AuthTestSuite) TestMemoryKeyStore() {
+	key, err := auth.GenerateKey()
+	s.Nil(err)
+
+	store := auth.NewMemoryKeyStore([]auth.APIKey{{Client: auth.Client{Name: "test"}, Hash: auth.HashKey(key)}})
+	apiKey, err := store.Lookup(context.Background(), auth.HashKey(key))
+	s.Nil(err)
+	s.Equal("test", apiKey.Name)
+
+	_, err = store.Lookup(context.Background(), auth.HashKey("wrong"))
+	s.NotNil(err)
+}
+
+func (s *AuthTestSuite) TestPermit() {
+	ctx := context.Background()
+	s.Nil(auth.Permit(ctx, auth.TemplatesPermission))
+
+	// types and senders don't grant permissions
+	sender := auth.WithClient(ctx, &auth.Client{Name: "sender", Types: []string{"email:welcome"}})
+	s.ErrorIs(auth.Permit(sender, auth.TemplatesPermission), auth.ErrNoPermission)
+	s.ErrorIs(auth.Permit(auth.WithClient(ctx, &auth.Client{Name: "any"}), auth.TemplatesPermission), auth.ErrNoPermission)
+
+	admin := auth.WithClient(ctx, &auth.Client{Name: "admin", Permissions: []string{auth.TemplatesPermission}})
+	s.Nil(auth.Permit(admin, auth.TemplatesPermission))
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksMinRefresh minimum interval between two fetches of a remote key set
+const jwksMinRefresh = time.Minute
+
+var (
+	errKeyNotFound    = errors.New("signing key not found in key set")
+	errUnsupportedKey = errors.New("unsupported key type")
+	errNoUsableKeys   = errors.New("no usable signing key in key set")
+)
+
+// jwk JSON Web Key as defined in RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKS public key set used to verify JWT signatures, loaded from a file path
+// or an http(s) URL. Remote key sets are fetched again when an unknown key id
+// is requested, to support key rotation.
+type JWKS struct {
+	source string
+	client *http.Client
+
+	lock    *sync.RWMutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// NewJWKS loads the key set from the source
+func NewJWKS(ctx context.Context, source string) (*JWKS, error) {
+	j := &JWKS{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+		lock:   new(sync.RWMutex),
+	}
+	if err := j.load(ctx); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *JWKS) remote() bool {
+	return strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://")
+}
+
+func (j *JWKS) load(ctx context.Context) error {
+	var data []byte
+	if j.remote() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+		if err != nil {
+			return err
+		}
+		res, err := j.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("cannot fetch key set: status %d", res.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(res.Body, 1<<20)); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(j.source); err != nil {
+			return err
+		}
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	j.lock.Lock()
+	j.keys, j.fetched = keys, time.Now()
+	j.lock.Unlock()
+	return nil
+}
+
+// Key returns the public key identified by kid
+func (j *JWKS) Key(ctx context.Context, kid string) (interface{}, error) {
+	j.lock.RLock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetched) > jwksMinRefresh
+	j.lock.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if !j.remote() || !stale {
+		return nil, errKeyNotFound
+	}
+	if err := j.load(ctx); err != nil {
+		return nil, err
+	}
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errKeyNotFound
+}
+
+// ParseJWKS parses a JSON Web Key Set, returning public keys indexed by key
+// id. RSA, EC (P-256, P-384, P-521) and OKP (Ed25519) signing keys are
+// supported, other keys, e.g. encryption or symmetric ones, are skipped, so
+// that a set shared with other services can be used. The set must have at
+// least a usable key.
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	var skipped []string
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("key %s: %v", k.Kid, err))
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("%w (%s)", errNoUsableKeys, strings.Join(skipped, ", "))
+		}
+		return nil, errNoUsableKeys
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errUnsupportedKey
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errUnsupportedKey
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,35 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/xn3cr0nx/email-service/internal/auth"
+)
+
+func (s *AuthTestSuite) TestParseJWKS() {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().Nil(err)
+	x := base64.RawURLEncoding.EncodeToString(public)
+
+	// symmetric, encryption and malformed keys are skipped
+	keys, err := auth.ParseJWKS([]byte(fmt.Sprintf(`{"keys":[
+		{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"EC","kid":"secp256k1","crv":"secp256k1","x":"AQAB","y":"AQAB"},
+		{"kty":"OKP","kid":"short","crv":"Ed25519","x":"AQAB"},
+		{"kty":"OKP","kid":"test","crv":"Ed25519","use":"sig","x":"%s"}
+	]}`, x)))
+	s.Nil(err)
+	s.Len(keys, 1)
+	s.Equal(ed25519.PublicKey(public), keys["test"])
+
+	_, err = auth.ParseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}]}`))
+	s.NotNil(err)
+	_, err = auth.ParseJWKS([]byte(`{"keys":[]}`))
+	s.NotNil(err)
+	_, err = auth.ParseJWKS([]byte(`{"keys":`))
+	s.NotNil(err)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrQuotaExceeded returned when the caller daily quota can't cover the
+// emails of the request
+var ErrQuotaExceeded = errors.New("api key daily quota exceeded")
+
+// Quota daily quota of the authenticated caller, charged per recipient by
+// the requests sending emails
+type Quota interface {
+	// Charge consumes n units of the quota, returning ErrQuotaExceeded,
+	// without consuming any, if fewer are left
+	Charge(n int) error
+}
+
+type quotaKey struct{}
+
+// WithQuota returns a copy of the context carrying the quota of the caller
+func WithQuota(ctx context.Context, q Quota) context.Context {
+	return context.WithValue(ctx, quotaKey{}, q)
+}
+
+// Charge consumes n units of the quota carried by the context. Contexts
+// without quota, e.g. queue backends, are never charged.
+func Charge(ctx context.Context, n int) error {
+	q, ok := ctx.Value(quotaKey{}).(Quota)
+	if !ok || n <= 0 {
+		return nil
+	}
+	return q.Charge(n)
+}
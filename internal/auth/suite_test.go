@@ -0,0 +1,15 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+type AuthTestSuite struct {
+	suite.Suite
+}
@@ -5,16 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/logger"
 	"go.opentelemetry.io/otel/metric"
-	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -22,34 +21,32 @@ type EmailHandler struct {
 	Mailer provider.Mailer
 	tracer trace.Tracer
 
-	meter                   metric.Meter
-	emailCounter            syncfloat64.Counter
-	emailCounterLock        *sync.RWMutex
-	welcomeEmailCounter     syncfloat64.Counter
-	welcomeEmailCounterLock *sync.RWMutex
+	meter   metric.Meter
+	metrics *metrics.Metrics
 }
 
 func NewEmailHandler(m provider.Mailer, tracer trace.Tracer, meter metric.Meter) *EmailHandler {
-	emailCounterLock := new(sync.RWMutex)
-	var emailCounter syncfloat64.Counter
-	welcomeEmailCounterLock := new(sync.RWMutex)
-	var welcomeEmailCounter syncfloat64.Counter
-	if meter != nil {
-		var err error
-		emailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("kafka.emails")
-		if err != nil {
-			return nil
-		}
-		welcomeEmailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("kafka.emails.welcome")
-		if err != nil {
-			return nil
-		}
+	instruments, err := metrics.New(meter, metrics.Asynq)
+	if err != nil {
+		return nil
 	}
 
-	return &EmailHandler{m, tracer, meter, emailCounter, emailCounterLock, welcomeEmailCounter, welcomeEmailCounterLock}
+	return &EmailHandler{m, tracer, meter, instruments}
+}
+
+// ObserveQueue reports the pending tasks of the queue as queue lag
+func (h *EmailHandler) ObserveQueue(inspector *asynq.Inspector, queue string) error {
+	return h.metrics.ObserveQueue(func(ctx context.Context) (int64, error) {
+		info, err := inspector.GetQueueInfo(queue)
+		if err != nil {
+			return 0, err
+		}
+		return int64(info.Pending), nil
+	})
 }
 
 func (h EmailHandler) ProcessTask(ctx context.Context, t *asynq.Task) (err error) {
+	ctx = metrics.NewContext(ctx, h.metrics)
 	start := time.Now()
 	logger.Info("Email Service Queue", "Start processing", logger.Params{"type": t.Type})
 	defer (func() {
@@ -66,12 +63,19 @@ func (h EmailHandler) ProcessTask(ctx context.Context, t *asynq.Task) (err error
 
 		if err = emailTask.Process(ctx, h.Mailer); err != nil {
 			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type})
+			return skipRetry(err)
+		}
+
+	case template.ReminderEmail:
+		var emailTask email.ReminderEmailBody
+		if err = json.Unmarshal(t.Payload(), &emailTask); err != nil {
+			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
 			return
 		}
-		if h.meter != nil {
-			(*h.welcomeEmailCounterLock).Lock()
-			h.welcomeEmailCounter.Add(ctx, 1)
-			(*h.welcomeEmailCounterLock).Unlock()
+
+		if err = emailTask.Process(ctx, h.Mailer); err != nil {
+			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
+			return skipRetry(err)
 		}
 
 	case template.VerificationEmail:
@@ -83,7 +87,7 @@ func (h EmailHandler) ProcessTask(ctx context.Context, t *asynq.Task) (err error
 
 		if err = emailTask.Process(ctx, h.Mailer); err != nil {
 			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
-			return
+			return skipRetry(err)
 		}
 
 	case template.ResetEmail:
@@ -94,17 +98,35 @@ func (h EmailHandler) ProcessTask(ctx context.Context, t *asynq.Task) (err error
 		}
 
 		if err = emailTask.Process(ctx, h.Mailer); err != nil {
+			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
+			return skipRetry(err)
+		}
+
+	case email.BatchEmail:
+		var emailTask email.BatchEmailBody
+		if err = json.Unmarshal(t.Payload(), &emailTask); err != nil {
+			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
+			return
+		}
+
+		var result *email.BatchResult
+		if result, err = emailTask.Process(ctx, h.Mailer); err != nil {
 			logger.Error("Email Service Queue", err, logger.Params{"type": t.Type()})
 			return
 		}
+		// failed recipients are not retried, since the task would send again to the successful ones
+		logBatchResult("Email Service Queue", result)
 
 	default:
 		return errors.New("unmatched case")
 	}
-	if h.meter != nil {
-		(*h.emailCounterLock).Lock()
-		h.emailCounter.Add(ctx, 1)
-		(*h.emailCounterLock).Unlock()
-	}
 	return
 }
+
+// skipRetry prevents asynq from retrying tasks that would fail again
+func skipRetry(err error) error {
+	if skipped(err) {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+package backend
+
+import (
+	"errors"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+// logBatchResult logs the outcome of a batch, reporting each failed recipient
+func logBatchResult(action string, result *email.BatchResult) {
+	for _, r := range result.Results {
+		if r.Error != "" {
+			logger.Error(action, errors.New(r.Error), logger.Params{"to": r.To})
+		}
+	}
+	logger.Info(action, "Batch processed", logger.Params{"sent": result.Sent, "failed": result.Failed})
+}
+
+// skipped reports whether the email was intentionally not sent to its
+// recipients, so that it should not be processed again
+func skipped(err error) bool {
+	return errors.Is(err, email.ErrRecipientOptedOut) || errors.Is(err, email.ErrUndeliverableRecipient)
+}
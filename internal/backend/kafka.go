@@ -5,17 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/logger"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
-	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -30,21 +29,19 @@ func NewKafkaEmailConsumer(k *kafka.Reader, m provider.Mailer, tracer trace.Trac
 	return &KafkaEmailConsumer{k, m, tracer, meter}
 }
 
+// QueueLag returns the messages behind the high water mark of the partition
+// last fetched by the reader. Reader.Lag is not available to consumer groups.
+func (k *KafkaEmailConsumer) QueueLag(ctx context.Context) (int64, error) {
+	return k.Reader.Stats().Lag, nil
+}
+
 func (k *KafkaEmailConsumer) Run(ctx context.Context) error {
-	emailCounterLock := new(sync.RWMutex)
-	var emailCounter syncfloat64.Counter
-	welcomeEmailCounterLock := new(sync.RWMutex)
-	var welcomeEmailCounter syncfloat64.Counter
-	if k.meter != nil {
-		var err error
-		emailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("kafka.emails")
-		if err != nil {
-			return err
-		}
-		welcomeEmailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("kafka.emails.welcome")
-		if err != nil {
-			return err
-		}
+	instruments, err := metrics.New(k.meter, metrics.Kafka)
+	if err != nil {
+		return err
+	}
+	if err := instruments.ObserveQueue(k.QueueLag); err != nil {
+		return err
 	}
 
 	var spanContext context.Context
@@ -55,10 +52,18 @@ func (k *KafkaEmailConsumer) Run(ctx context.Context) error {
 	} else {
 		spanContext = context.WithValue(ctx, "email", "")
 	}
+	spanContext = metrics.NewContext(spanContext, instruments)
 
 	// TODO: consider if adding unique UUID to message keys
 
 	for {
+		// pause fetching until the mailer has budget to send
+		if t, ok := k.Mailer.(provider.Throttler); ok {
+			if err := t.Wait(spanContext); err != nil {
+				return err
+			}
+		}
+
 		// the `FetchMessage` method blocks until we receive the next event, and the message needs to
 		// be commited in order to update offset
 		msg, err := k.Reader.FetchMessage(spanContext)
@@ -85,14 +90,21 @@ func (k *KafkaEmailConsumer) Run(ctx context.Context) error {
 				continue
 			}
 
-			if err = emailTask.Process(emailSpanContext, k.Mailer); err != nil {
+			if err = emailTask.Process(emailSpanContext, k.Mailer); err != nil && !skipped(err) {
 				logger.Error("Email Service Kafka", fmt.Errorf("could not process message: %v", err), logger.Params{})
 				continue
 			}
-			if k.meter != nil {
-				(*welcomeEmailCounterLock).Lock()
-				welcomeEmailCounter.Add(emailSpanContext, 1)
-				(*welcomeEmailCounterLock).Unlock()
+
+		case template.ReminderEmail:
+			var emailTask email.ReminderEmailBody
+			if err = json.Unmarshal(msg.Value, &emailTask); err != nil {
+				logger.Error("Email Service Kafka", fmt.Errorf("could not unmarshal message: %v", err), logger.Params{})
+				continue
+			}
+
+			if err = emailTask.Process(emailSpanContext, k.Mailer); err != nil && !skipped(err) {
+				logger.Error("Email Service Kafka", fmt.Errorf("could not process message: %v", err), logger.Params{})
+				continue
 			}
 
 		case template.ResetEmail:
@@ -102,19 +114,28 @@ func (k *KafkaEmailConsumer) Run(ctx context.Context) error {
 				continue
 			}
 
-			if err = emailTask.Process(emailSpanContext, k.Mailer); err != nil {
+			if err = emailTask.Process(emailSpanContext, k.Mailer); err != nil && !skipped(err) {
+				logger.Error("Email Service Kafka", fmt.Errorf("could not process message: %v", err), logger.Params{})
+				continue
+			}
+		case email.BatchEmail:
+			var emailTask email.BatchEmailBody
+			if err = json.Unmarshal(msg.Value, &emailTask); err != nil {
+				logger.Error("Email Service Kafka", fmt.Errorf("could not unmarshal message: %v", err), logger.Params{})
+				continue
+			}
+
+			result, err := emailTask.Process(emailSpanContext, k.Mailer)
+			if err != nil {
 				logger.Error("Email Service Kafka", fmt.Errorf("could not process message: %v", err), logger.Params{})
 				continue
 			}
+			logBatchResult("Email Service Kafka", result)
+
 		default:
 			logger.Error("Email Service Kafka", errors.New("unmatched case"), logger.Params{})
 			continue
 		}
-		if k.meter != nil {
-			(*emailCounterLock).Lock()
-			emailCounter.Add(emailSpanContext, 1)
-			(*emailCounterLock).Unlock()
-		}
 
 		if err := k.Reader.CommitMessages(emailSpanContext, msg); err != nil {
 			logger.Error("Email Service Kafka", fmt.Errorf("could not commit message: %v. Retrying", err), logger.Params{"message": string(msg.Value)})
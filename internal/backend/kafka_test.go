@@ -0,0 +1,29 @@
+package backend_test
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/xn3cr0nx/email-service/internal/backend"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+)
+
+func (s *BackendTestSuite) TestKafkaQueueLag() {
+	// readers of consumer groups don't report Reader.Lag
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{"localhost:0"}, GroupID: "test", Topic: "emails"})
+	defer reader.Close()
+	s.Equal(int64(-1), reader.Lag())
+
+	provider, exporter := metrictest.NewTestMeterProvider()
+	m, err := metrics.New(provider.Meter("test"), metrics.Kafka)
+	s.Require().Nil(err)
+	consumer := backend.NewKafkaEmailConsumer(reader, nil, nil, nil)
+	s.Nil(m.ObserveQueue(consumer.QueueLag))
+
+	s.Nil(exporter.Collect(context.Background()))
+	record, err := exporter.GetByNameAndAttributes("email.queue.lag", []attribute.KeyValue{attribute.String("backend", metrics.Kafka)})
+	s.Nil(err)
+	s.Equal(int64(0), record.LastValue.AsInt64())
+}
@@ -5,18 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/xn3cr0nx/email-service/internal/email"
 	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/logger"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
-	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -37,20 +36,9 @@ func NewNatsEmailConsumer(n *nats.Conn, m provider.Mailer, tracer trace.Tracer,
 }
 
 func (n *NatsEmailConsumer) Run(ctx context.Context) error {
-	emailCounterLock := new(sync.RWMutex)
-	var emailCounter syncfloat64.Counter
-	welcomeEmailCounterLock := new(sync.RWMutex)
-	var welcomeEmailCounter syncfloat64.Counter
-	if n.meter != nil {
-		var err error
-		emailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("NATS.emails")
-		if err != nil {
-			return err
-		}
-		welcomeEmailCounter, err = metric.NewNoopMeter().SyncFloat64().Counter("NATS.emails.welcome")
-		if err != nil {
-			return err
-		}
+	instruments, err := metrics.New(n.meter, metrics.NATS)
+	if err != nil {
+		return err
 	}
 
 	var spanContext context.Context
@@ -61,14 +49,29 @@ func (n *NatsEmailConsumer) Run(ctx context.Context) error {
 	} else {
 		spanContext = context.WithValue(ctx, "email", "")
 	}
+	spanContext = metrics.NewContext(spanContext, instruments)
 
 	// TODO: inject the subscribe subject from env
 	sub, err := n.Subscriber.SubscribeSync(environment.Get().NatsSubject)
 	if err != nil {
 		return err
 	}
+	err = instruments.ObserveQueue(func(ctx context.Context) (int64, error) {
+		pending, _, err := sub.Pending()
+		return int64(pending), err
+	})
+	if err != nil {
+		return err
+	}
 
 	for {
+		// pause fetching until the mailer has budget to send
+		if t, ok := n.Mailer.(provider.Throttler); ok {
+			if err := t.Wait(spanContext); err != nil {
+				return err
+			}
+		}
+
 		var msg NatsMessage
 		m, err := sub.NextMsg(5 * time.Second)
 		if err != nil {
@@ -102,14 +105,21 @@ func (n *NatsEmailConsumer) Run(ctx context.Context) error {
 
 			logger.Info("Email Service NATS", "Received WelcomeEmail", logger.Params{"subject": emailTask.Subject, "to": emailTask.To, "from": emailTask.From})
 
-			if err := emailTask.Process(emailSpanContext, n.Mailer); err != nil {
+			if err := emailTask.Process(emailSpanContext, n.Mailer); err != nil && !skipped(err) {
 				logger.Error("Email Service NATS", fmt.Errorf("could not process message: %v", err), logger.Params{})
 				continue
 			}
-			if n.meter != nil {
-				(*welcomeEmailCounterLock).Lock()
-				welcomeEmailCounter.Add(emailSpanContext, 1)
-				(*welcomeEmailCounterLock).Unlock()
+
+		case template.ReminderEmail:
+			var emailTask email.ReminderEmailBody
+			if err := json.Unmarshal(msg.Value, &emailTask); err != nil {
+				logger.Error("Email Service NATS", fmt.Errorf("could not unmarshal message: %v", err), logger.Params{})
+				continue
+			}
+
+			if err := emailTask.Process(emailSpanContext, n.Mailer); err != nil && !skipped(err) {
+				logger.Error("Email Service NATS", fmt.Errorf("could not process message: %v", err), logger.Params{})
+				continue
 			}
 
 		case template.ResetEmail:
@@ -119,19 +129,27 @@ func (n *NatsEmailConsumer) Run(ctx context.Context) error {
 				continue
 			}
 
-			if err := emailTask.Process(emailSpanContext, n.Mailer); err != nil {
+			if err := emailTask.Process(emailSpanContext, n.Mailer); err != nil && !skipped(err) {
 				logger.Error("Email Service NATS", fmt.Errorf("could not process message: %v", err), logger.Params{})
 				continue
 			}
 
+		case email.BatchEmail:
+			var emailTask email.BatchEmailBody
+			if err := json.Unmarshal(msg.Value, &emailTask); err != nil {
+				logger.Error("Email Service NATS", fmt.Errorf("could not unmarshal message: %v", err), logger.Params{})
+				continue
+			}
+
+			result, err := emailTask.Process(emailSpanContext, n.Mailer)
+			if err != nil {
+				logger.Error("Email Service NATS", fmt.Errorf("could not process message: %v", err), logger.Params{})
+				continue
+			}
+			logBatchResult("Email Service NATS", result)
+
 		default:
 			logger.Error("Email Service NATS", errors.New("unmatched case"), logger.Params{})
 		}
-
-		if n.meter != nil {
-			(*emailCounterLock).Lock()
-			emailCounter.Add(emailSpanContext, 1)
-			(*emailCounterLock).Unlock()
-		}
 	}
 }
@@ -0,0 +1,20 @@
+package backend_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(BackendTestSuite))
+}
+
+type BackendTestSuite struct {
+	suite.Suite
+}
+
+func (s *BackendTestSuite) SetupSuite() {
+	logger.Setup()
+}
@@ -0,0 +1,228 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/attachment"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/sender"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// BatchEmail task type of batch email requests
+const BatchEmail = "email:batch"
+
+// maxBatchRecipients maximum number of recipients accepted in a single batch request
+const maxBatchRecipients = 10000
+
+// BatchEmailBody renders the email type once and personalizes it for each
+// recipient. Recipient params replace the params of the email type, and the
+// recipient name is used as name param when not explicitly set.
+type BatchEmailBody struct {
+	Type string `json:"type,omitempty"`
+	// Sender: id of the sender identity, takes precedence over From
+	Sender  string `json:"sender,omitempty"`
+	From    string `json:"from,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: locale of the template and subject, shared by the recipients
+	Locale     string            `json:"locale,omitempty"`
+	Recipients []model.Recipient `json:"recipients,omitempty"`
+	// Attachments: sent to every recipient
+	Attachments []attachment.Attachment `json:"attachments,omitempty"`
+}
+
+type BatchResult struct {
+	Sent    int                `json:"sent"`
+	Failed  int                `json:"failed"`
+	Results []model.SendResult `json:"results"`
+}
+
+// batchBody is implemented by email types supporting batch sending
+type batchBody interface {
+	ValidateBody() error
+	params() interface{}
+	render() (model.Email, error)
+}
+
+func (b *BatchEmailBody) ValidateBody() error {
+	var errs validator.FieldErrors
+	if _, err := b.body("", nil); err != nil {
+		errs.Add("type", err)
+	}
+	if b.From != "" {
+		if _, err := validator.ParseAddress(b.From); err != nil {
+			errs.Add("from", err)
+		}
+	}
+	localize(&errs, b.Type, &b.Locale, &b.Subject)
+	if b.Subject == "" {
+		errs.Add("subject", errInvalidSubject)
+	}
+	if len(b.Recipients) == 0 {
+		errs.Add("recipients", errInvalidRecipients)
+	}
+	if len(b.Recipients) > maxBatchRecipients {
+		errs.Add("recipients", errTooManyRecipients)
+	}
+	attachment.Validate(&errs, b.Attachments)
+	return errs.Err()
+}
+
+// body returns the email body of the batch type for the recipient
+func (b *BatchEmailBody) body(to string, params map[string]string) (batchBody, error) {
+	var body batchBody
+	switch b.Type {
+	case template.WelcomeEmail:
+		body = &WelcomeEmailBody{Sender: b.Sender, From: b.From, To: to, Subject: b.Subject, Locale: b.Locale, Attachments: b.Attachments}
+	case template.ReminderEmail:
+		body = &ReminderEmailBody{Sender: b.Sender, From: b.From, To: to, Subject: b.Subject, Locale: b.Locale, Attachments: b.Attachments}
+	case template.VerificationEmail:
+		body = &VerificationEmailBody{Sender: b.Sender, From: b.From, To: to, Subject: b.Subject, Locale: b.Locale, Attachments: b.Attachments}
+	case template.ResetEmail:
+		body = &ResetEmailBody{Sender: b.Sender, From: b.From, To: to, Subject: b.Subject, Locale: b.Locale, Attachments: b.Attachments}
+	default:
+		return nil, errInvalidType
+	}
+	if len(params) > 0 {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, body.params()); err != nil {
+			return nil, errInvalidParams
+		}
+	}
+	return body, nil
+}
+
+// Process validates each recipient params and sends the personalized email in
+// batch using the provider. Invalid, opted out or undeliverable recipients,
+// and recipients of more than one address, are reported as failed.
+func (b *BatchEmailBody) Process(ctx context.Context, m provider.Mailer) (*BatchResult, error) {
+	if err := b.ValidateBody(); err != nil {
+		// the type may be invalid, it is not used as metric attribute
+		metrics.FromContext(ctx).Failed(ctx, BatchEmail, len(b.Recipients))
+		return nil, err
+	}
+
+	result := new(BatchResult)
+	recipients := make([]model.Recipient, 0, len(b.Recipients))
+	for _, recipient := range b.Recipients {
+		if _, ok := recipient.Params["name"]; !ok && recipient.Name != "" {
+			params := map[string]string{"name": recipient.Name}
+			for key, value := range recipient.Params {
+				params[key] = value
+			}
+			recipient.Params = params
+		}
+
+		body, err := b.body(recipient.To, recipient.Params)
+		if err == nil {
+			err = body.ValidateBody()
+		}
+		if err == nil {
+			// each recipient is sent an individual email
+			_, err = validator.ParseAddressList(recipient.To, 1)
+		}
+		if err == nil {
+			// a single address is either allowed as is or opted out
+			_, err = filterRecipients(ctx, b.Type, recipient.To)
+		}
+		if err == nil {
+			err = checkRecipients(ctx, recipient.To)
+		}
+		if err != nil {
+			result.add(model.SendResult{To: recipient.To, Error: err.Error()})
+			continue
+		}
+		recipients = append(recipients, recipient)
+	}
+	rejected := result.Failed
+	metrics.FromContext(ctx).Failed(ctx, b.Type, rejected)
+	if len(recipients) == 0 {
+		return result, nil
+	}
+
+	email, err := b.template(ctx, recipients[0].To)
+	if err != nil {
+		metrics.FromContext(ctx).Failed(ctx, b.Type, len(recipients))
+		return nil, err
+	}
+	if err := auth.Charge(ctx, len(recipients)); err != nil {
+		metrics.FromContext(ctx).Failed(ctx, b.Type, len(recipients))
+		return nil, err
+	}
+	start := time.Now()
+	results, err := m.SendBatch(ctx, email, recipients)
+	metrics.FromContext(ctx).SendDuration(ctx, b.Type, time.Since(start))
+	if err != nil {
+		metrics.FromContext(ctx).Failed(ctx, b.Type, len(recipients))
+		return nil, err
+	}
+	for _, r := range results {
+		result.add(r)
+	}
+	metrics.FromContext(ctx).Sent(ctx, b.Type, result.Sent)
+	metrics.FromContext(ctx).Failed(ctx, b.Type, result.Failed-rejected)
+	return result, nil
+}
+
+// template renders the email type with placeholders in place of params,
+// resolving the sender identity and authorizing the caller to send it
+func (b *BatchEmailBody) template(ctx context.Context, to string) (model.Email, error) {
+	body, err := b.body(to, nil)
+	if err != nil {
+		return model.Email{}, err
+	}
+	// placeholders are not valid params, recipients have already been validated
+	fillPlaceholders(body.params())
+	email, err := timeRender(ctx, b.Type, body.render)
+	if err != nil {
+		return model.Email{}, err
+	}
+	if email.From == "" {
+		email.From = environment.Get().Sender
+	}
+	identity, err := sender.Resolve(ctx, b.Type, b.Sender, email.From)
+	if err != nil {
+		return model.Email{}, err
+	}
+	if err := auth.Authorize(ctx, b.Type, identity.Address); err != nil {
+		return model.Email{}, err
+	}
+	email.From, email.ReplyTo = identity.From(), identity.ReplyTo
+	email.To = ""
+	return email, nil
+}
+
+// fillPlaceholders sets each string field of the params struct to the model
+// placeholder of its json key
+func fillPlaceholders(params interface{}) {
+	v := reflect.ValueOf(params).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || field.Type.Kind() != reflect.String {
+			continue
+		}
+		v.Field(i).SetString(model.Placeholder(key))
+	}
+}
+
+func (r *BatchResult) add(result model.SendResult) {
+	if result.Error != "" {
+		r.Failed++
+	} else {
+		r.Sent++
+	}
+	r.Results = append(r.Results, result)
+}
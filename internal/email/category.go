@@ -0,0 +1,59 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// CategoryByType returns the preference category the email type belongs to.
+// Unknown types are considered marketing, so that opt-outs are always honored.
+func CategoryByType(taskType string) preference.Category {
+	if category, ok := map[string]preference.Category{
+		template.WelcomeEmail:      preference.Transactional,
+		template.VerificationEmail: preference.Security,
+		template.ResetEmail:        preference.Security,
+		template.ReminderEmail:     preference.Product,
+	}[taskType]; ok {
+		return category
+	}
+	return preference.Marketing
+}
+
+// filterRecipients removes from the comma separated list of recipients the ones
+// that opted out from the email type category. Returns ErrRecipientOptedOut if
+// none is left.
+func filterRecipients(ctx context.Context, taskType, to string) (string, error) {
+	category := CategoryByType(taskType)
+	store := preference.Get()
+	if store == nil || category.Mandatory() {
+		return to, nil
+	}
+
+	addresses, err := mail.ParseAddressList(to)
+	if err != nil {
+		return "", err
+	}
+	var allowed []*mail.Address
+	for _, recipient := range addresses {
+		p, err := store.Get(ctx, recipient.Address)
+		if err != nil && !errors.Is(err, errorx.ErrNotFound) {
+			return "", err
+		}
+		if !p.Allows(category) {
+			logger.Info("Email Service", "Recipient opted out", logger.Params{"type": taskType, "to": recipient.Address, "category": category})
+			continue
+		}
+		allowed = append(allowed, recipient)
+	}
+	if len(allowed) == 0 {
+		return "", ErrRecipientOptedOut
+	}
+	return validator.FormatAddressList(allowed), nil
+}
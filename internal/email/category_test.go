@@ -0,0 +1,33 @@
+package email_test
+
+import (
+	"context"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *EmailTestSuite) TestCategoryByType() {
+	s.True(email.CategoryByType(template.WelcomeEmail).Mandatory())
+	s.True(email.CategoryByType(template.ResetEmail).Mandatory())
+	s.Equal(preference.Product, email.CategoryByType(template.ReminderEmail))
+	s.Equal(preference.Marketing, email.CategoryByType("email:unknown"))
+}
+
+func (s *EmailTestSuite) TestOptOut() {
+	store := preference.NewMemoryStore()
+	preference.Set(store)
+	defer preference.Set(nil)
+	ctx := context.Background()
+	s.Require().Nil(store.Set(ctx, &preference.Preferences{Recipient: "user@test.com", OptOut: []preference.Category{preference.Product}}))
+
+	// the email is discarded before rendering, the mailer is never used
+	reminder := &email.ReminderEmailBody{
+		From:    "noreply@test.com",
+		To:      "user@test.com",
+		Subject: "Reminder",
+		Params:  email.ReminderEmailBodyParams{Name: "User", URL: "https://test.com/onboarding"},
+	}
+	s.ErrorIs(reminder.Process(ctx, nil), email.ErrRecipientOptedOut)
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/link"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/sender"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// envelope resolves the sender identity of the email type and authorizes the
+// caller to send from it, returning the identity and the recipients that did
+// not opt out from the email category. Recipients flagged by the recipient
+// checker are rejected, if enforced.
+func envelope(ctx context.Context, taskType, senderID, from, to string) (*sender.Identity, string, error) {
+	identity, err := sender.Resolve(ctx, taskType, senderID, from)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := auth.Authorize(ctx, taskType, identity.Address); err != nil {
+		return nil, "", err
+	}
+
+	to, err = filterRecipients(ctx, taskType, to)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkRecipients(ctx, to); err != nil {
+		return nil, "", err
+	}
+	return identity, to, nil
+}
+
+// localize normalizes the locale of the request and defaults the subject to
+// the translated subject of the email type
+func localize(errs *validator.FieldErrors, taskType string, locale, subject *string) {
+	if *locale != "" {
+		normalized, err := template.NormalizeLocale(*locale)
+		if err != nil {
+			errs.Add("locale", err)
+		} else {
+			*locale = normalized
+		}
+	}
+	if *subject == "" {
+		*subject = template.Subject(taskType, *locale)
+	}
+}
+
+// validateEnvelope checks the subject, the from address, if set, and the comma
+// separated recipients, within the limit of the configured provider. Valid
+// addresses are normalized to their ASCII form.
+func validateEnvelope(errs *validator.FieldErrors, from, to *string, subject string) {
+	if *from != "" {
+		address, err := validator.ParseAddress(*from)
+		if err != nil {
+			errs.Add("from", err)
+		} else {
+			*from = validator.FormatAddressList([]*mail.Address{address})
+		}
+	}
+
+	max := 0
+	if env := environment.Get(); env != nil {
+		max = provider.MaxRecipients(env.Provider)
+	}
+	addresses, err := validator.ParseAddressList(*to, max)
+	if err != nil {
+		errs.Add("to", err)
+	} else {
+		*to = validator.FormatAddressList(addresses)
+	}
+
+	if subject == "" {
+		errs.Add("subject", errInvalidSubject)
+	}
+}
+
+// identify assigns a Message-ID to the email, passed to the provider and
+// returned to the caller, and rewrites the email for first-party open and
+// click tracking, if enabled. Links of security emails carry secrets, e.g.
+// reset tokens, so their clicks are not tracked. Batch emails are not
+// identified, they share the headers of the template among recipients.
+func identify(taskType string, email *model.Email) error {
+	if email.Header("Message-ID") == "" {
+		from, err := validator.ParseAddress(email.From)
+		if err != nil {
+			return err
+		}
+		id, err := model.NewMessageID(from.Address[strings.LastIndex(from.Address, "@")+1:])
+		if err != nil {
+			return err
+		}
+		email.Headers = append(email.Headers, model.Header{Name: "Message-ID", Value: id})
+	}
+
+	t := tracking.GetTracker()
+	if t == nil {
+		return nil
+	}
+	_, err := t.Track(email, taskType, CategoryByType(taskType) != preference.Security)
+	return err
+}
+
+// validateLink checks the link request replacing the url param, if any,
+// otherwise the url param itself
+func validateLink(errs *validator.FieldErrors, l *link.Request, url string) {
+	if l == nil {
+		if err := validator.ValidateURL(url); err != nil {
+			errs.Add("params.url", err)
+		}
+		return
+	}
+	if link.Get() == nil {
+		errs.Add("link", link.ErrDisabled)
+		return
+	}
+	if err := l.Validate(); err != nil {
+		errs.Add("link", err)
+	}
+}
+
+// signLink sets the url param to the signed link of the request, if any
+func signLink(l *link.Request, url *string) error {
+	if l == nil {
+		return nil
+	}
+	signed, err := link.Get().URL(l)
+	if err != nil {
+		return err
+	}
+	*url = signed
+	return nil
+}
+
+// Deliver sends the email of the type using the provider, recording the send
+// duration and outcome in the metrics of the context
+func Deliver(ctx context.Context, taskType string, m provider.Mailer, email model.Email) error {
+	if err := auth.Charge(ctx, countRecipients(email)); err != nil {
+		metrics.FromContext(ctx).Failed(ctx, taskType, 1)
+		return err
+	}
+	start := time.Now()
+	err := m.Send(ctx, email)
+	metrics.FromContext(ctx).SendDuration(ctx, taskType, time.Since(start))
+	if err != nil {
+		metrics.FromContext(ctx).Failed(ctx, taskType, 1)
+		return err
+	}
+	metrics.FromContext(ctx).Sent(ctx, taskType, 1)
+	return nil
+}
+
+// countRecipients returns the number of recipients of the email, charged to
+// the quota of the caller
+func countRecipients(email model.Email) int {
+	n := 0
+	for _, list := range []string{email.To, email.Cc, email.Bcc} {
+		if list == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(list)
+		if err != nil {
+			n++
+			continue
+		}
+		n += len(addresses)
+	}
+	return n
+}
+
+// failed records the email of the type as not sent, skipped if the recipients
+// opted out or are undeliverable
+func failed(ctx context.Context, taskType string, err error) {
+	if errors.Is(err, ErrRecipientOptedOut) || errors.Is(err, ErrUndeliverableRecipient) {
+		metrics.FromContext(ctx).Skipped(ctx, taskType)
+		return
+	}
+	metrics.FromContext(ctx).Failed(ctx, taskType, 1)
+}
+
+// timeRender renders the email of the type, recording the render duration
+func timeRender(ctx context.Context, taskType string, render func() (model.Email, error)) (model.Email, error) {
+	start := time.Now()
+	email, err := render()
+	metrics.FromContext(ctx).Rendered(ctx, taskType, time.Since(start))
+	return email, err
+}
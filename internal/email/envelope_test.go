@@ -0,0 +1,69 @@
+package email_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *EmailTestSuite) TestMessageID() {
+	mailer := new(mockMailer)
+	body := &email.WelcomeEmailBody{From: "noreply@test.com", To: "user@test.com", Params: email.WelcomeEmailBodyParams{Name: "User", URL: "https://test.com"}}
+	id, err := body.Send(context.Background(), mailer)
+	s.Nil(err)
+	s.Regexp(`^<.+@test\.com>$`, id)
+
+	s.Require().Len(mailer.sent, 1)
+	s.Equal(id, mailer.sent[0].Header("Message-ID"))
+}
+
+func (s *EmailTestSuite) TestTrackingSecurity() {
+	t, err := tracking.New(tracking.Config{BaseURL: "https://mail.test.com", Secret: "secret", Clicks: true})
+	s.Require().Nil(err)
+	tracking.SetTracker(t)
+	defer tracking.SetTracker(nil)
+
+	welcome := &email.WelcomeEmailBody{From: "noreply@test.com", To: "user@test.com", Params: email.WelcomeEmailBodyParams{Name: "User", URL: "https://test.com/confirm"}}
+	rendered, err := welcome.Email(context.Background())
+	s.Nil(err)
+	s.NotContains(rendered.HtmlBody, `href="https://test.com/confirm"`)
+	s.Contains(rendered.HtmlBody, "https://mail.test.com/t/c/")
+
+	// links of security emails carry secrets, they are not tracked
+	reset := &email.ResetEmailBody{From: "noreply@test.com", To: "user@test.com", Params: email.ResetEmailParams{URL: "https://test.com/reset?token=secret"}}
+	rendered, err = reset.Email(context.Background())
+	s.Nil(err)
+	s.Contains(rendered.HtmlBody, `href="https://test.com/reset?token=secret"`)
+	s.NotContains(rendered.HtmlBody, "https://mail.test.com/t/c/")
+}
+
+type quota struct {
+	left int
+}
+
+func (q *quota) Charge(n int) error {
+	if n > q.left {
+		return auth.ErrQuotaExceeded
+	}
+	q.left -= n
+	return nil
+}
+
+func (s *EmailTestSuite) TestQuota() {
+	q := &quota{left: 3}
+	ctx := auth.WithQuota(context.Background(), q)
+	mailer := new(mockMailer)
+
+	// emails are charged per recipient
+	s.Nil(email.Deliver(ctx, "email:welcome", mailer, model.Email{To: "a@test.com, b@test.com", Bcc: "c@test.com"}))
+	s.Equal(0, q.left)
+	err := email.Deliver(ctx, "email:welcome", mailer, model.Email{To: "a@test.com"})
+	s.ErrorIs(err, auth.ErrQuotaExceeded)
+	s.Equal(http.StatusTooManyRequests, email.HTTPError(err).(*echo.HTTPError).Code)
+	s.Len(mailer.sent, 1)
+}
@@ -5,9 +5,19 @@ import "errors"
 var (
 	errServiceInitialization = errors.New("injected service not correcly initialized")
 	errInvalidFrom           = errors.New("invalid from parameter")
-	errInvalidTo             = errors.New("invalid to parameter")
 	errInvalidSubject        = errors.New("invalid subject parameter")
 	errInvalidName           = errors.New("invalid name parameter for welcome email")
-	errInvalidURL            = errors.New("invalid URL parameter for welcome email")
 	errTemplateNotFound      = errors.New("cannot find template path using task type")
+	errInvalidType           = errors.New("invalid email type")
+	errInvalidParams         = errors.New("invalid params for email type")
+	errInvalidRecipients     = errors.New("invalid recipients parameter")
+	errTooManyRecipients     = errors.New("too many recipients in batch")
+	errDisposableDomain      = errors.New("disposable email domain")
+	errMissingMX             = errors.New("email domain does not accept emails")
+	errTooManyAddresses      = errors.New("too many addresses to validate")
+
+	// ErrRecipientOptedOut returned when every recipient opted out from the email category
+	ErrRecipientOptedOut = errors.New("recipient opted out from email category")
+	// ErrUndeliverableRecipient returned when a recipient is flagged by the recipient checker
+	ErrUndeliverableRecipient = errors.New("undeliverable recipient")
 )
@@ -0,0 +1,31 @@
+package email_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/link"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+func (s *EmailTestSuite) TestSignedLink() {
+	body := &email.ResetEmailBody{To: "user@test.com", Link: &link.Request{Path: "/reset"}}
+	var fields validator.FieldErrors
+	s.True(errors.As(body.ValidateBody(), &fields))
+	s.Equal("link", fields[0].Field)
+
+	signer, err := link.New("https://frontend.com", link.Config{
+		TTL:  time.Hour,
+		Keys: []link.KeyConfig{{ID: "k1", Algorithm: link.HS256, Secret: "0123456789abcdef0123456789abcdef"}},
+	})
+	s.Require().Nil(err)
+	link.Set(signer)
+	defer link.Set(nil)
+
+	rendered, err := body.Email(context.Background())
+	s.Nil(err)
+	s.Contains(body.Params.URL, "https://frontend.com/reset?token=")
+	s.Contains(rendered.HtmlBody, body.Params.URL)
+}
@@ -0,0 +1,100 @@
+package email_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+)
+
+type mockMailer struct {
+	err        error
+	sent       []model.Email
+	recipients []model.Recipient
+}
+
+func (m *mockMailer) Send(ctx context.Context, email model.Email) error {
+	m.sent = append(m.sent, email)
+	return m.err
+}
+
+func (m *mockMailer) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.recipients = append(m.recipients, recipients...)
+	results := make([]model.SendResult, len(recipients))
+	for i, recipient := range recipients {
+		results[i] = model.SendResult{To: recipient.To}
+	}
+	return results, nil
+}
+
+func (s *EmailTestSuite) TestBatchMetrics() {
+	provider, exporter := metrictest.NewTestMeterProvider()
+	m, err := metrics.New(provider.Meter("test"), metrics.HTTP)
+	s.Require().Nil(err)
+	ctx := metrics.NewContext(context.Background(), m)
+	attrs := []attribute.KeyValue{attribute.String("type", template.WelcomeEmail), attribute.String("backend", metrics.HTTP)}
+
+	batch := &email.BatchEmailBody{
+		Type:    template.WelcomeEmail,
+		From:    "noreply@test.com",
+		Subject: "Welcome",
+		Recipients: []model.Recipient{
+			{To: "user@test.com", Params: map[string]string{"name": "User", "url": "https://test.com"}},
+			{To: "a@test.com, b@test.com", Params: map[string]string{"name": "User", "url": "https://test.com"}},
+		},
+	}
+	mailer := new(mockMailer)
+	result, err := batch.Process(ctx, mailer)
+	s.Require().Nil(err)
+	s.Equal(1, result.Sent)
+	s.Equal(1, result.Failed)
+	// recipients of more than one address are not sent
+	s.Equal([]model.Recipient{batch.Recipients[0]}, mailer.recipients)
+
+	_, err = batch.Process(ctx, &mockMailer{err: errors.New("provider error")})
+	s.NotNil(err)
+	s.Nil(exporter.Collect(ctx))
+
+	record, err := exporter.GetByNameAndAttributes("email.sent", attrs)
+	s.Nil(err)
+	s.Equal(int64(1), record.Sum.AsInt64())
+	// the rejected recipient is counted once per request
+	record, err = exporter.GetByNameAndAttributes("email.failed", attrs)
+	s.Nil(err)
+	s.Equal(int64(3), record.Sum.AsInt64())
+}
+
+func (s *EmailTestSuite) TestMetrics() {
+	provider, exporter := metrictest.NewTestMeterProvider()
+	m, err := metrics.New(provider.Meter("test"), metrics.HTTP)
+	s.Require().Nil(err)
+	ctx := metrics.NewContext(context.Background(), m)
+	attrs := []attribute.KeyValue{attribute.String("type", template.WelcomeEmail), attribute.String("backend", metrics.HTTP)}
+
+	body := &email.WelcomeEmailBody{From: "noreply@test.com", To: "user@test.com", Params: email.WelcomeEmailBodyParams{Name: "User", URL: "https://test.com"}}
+	s.Nil(body.Process(ctx, &mockMailer{}))
+	s.NotNil(body.Process(ctx, &mockMailer{err: errors.New("provider error")}))
+	s.NotNil((&email.WelcomeEmailBody{To: "invalid"}).Process(ctx, &mockMailer{}))
+	s.Nil(exporter.Collect(ctx))
+
+	record, err := exporter.GetByNameAndAttributes("email.sent", attrs)
+	s.Nil(err)
+	s.Equal(int64(1), record.Sum.AsInt64())
+	record, err = exporter.GetByNameAndAttributes("email.failed", attrs)
+	s.Nil(err)
+	s.Equal(int64(2), record.Sum.AsInt64())
+	record, err = exporter.GetByNameAndAttributes("email.send.duration", attrs)
+	s.Nil(err)
+	s.Equal(uint64(2), record.Count)
+	record, err = exporter.GetByNameAndAttributes("email.render.duration", attrs)
+	s.Nil(err)
+	s.Equal(uint64(2), record.Count)
+}
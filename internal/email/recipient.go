@@ -0,0 +1,260 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// disposableDomains bundled list of disposable email domains, one per line
+//
+//go:embed disposable.txt
+var disposableDomains string
+
+// commonDomains popular mailbox domains used to suggest typo corrections
+var commonDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "yahoo.co.uk", "yahoo.fr",
+	"hotmail.com", "hotmail.co.uk", "hotmail.it", "outlook.com", "live.com",
+	"msn.com", "icloud.com", "me.com", "mac.com", "aol.com", "gmx.com",
+	"gmx.de", "web.de", "libero.it", "protonmail.com", "proton.me",
+	"yandex.com", "mail.com", "zoho.com", "comcast.net", "verizon.net",
+}
+
+// maxSuggestionDistance maximum edit distance of a domain from a common one to
+// be considered a typo
+const maxSuggestionDistance = 2
+
+// Resolver looks up MX and address records, net.Resolver implements it
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// RecipientCheck outcome of the verification of a recipient address
+type RecipientCheck struct {
+	Address    string `json:"address"`
+	Valid      bool   `json:"valid"`
+	Disposable bool   `json:"disposable"`
+	// Suggestion: corrected address when the domain looks like a typo of a common one
+	Suggestion string `json:"suggestion,omitempty"`
+	// MX: whether the domain has MX records, omitted when lookups are disabled
+	MX     *bool  `json:"mx,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Checker verifies recipients offline against the list of disposable domains
+// and common domain typos, and optionally through MX lookups
+type Checker struct {
+	// Enforce rejects flagged recipients before sending
+	Enforce    bool
+	resolver   Resolver
+	disposable map[string]bool
+}
+
+var checker *Checker
+
+// NewChecker returns a checker of the bundled disposable domains extended with
+// the lists read from readers. A nil resolver disables MX lookups.
+func NewChecker(resolver Resolver, lists ...io.Reader) (*Checker, error) {
+	c := &Checker{resolver: resolver, disposable: make(map[string]bool)}
+	if err := c.LoadDisposable(strings.NewReader(disposableDomains)); err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		if err := c.LoadDisposable(list); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// SetChecker sets the recipient checker used by email requests
+func SetChecker(c *Checker) {
+	checker = c
+}
+
+// GetChecker returns the recipient checker, nil if not configured
+func GetChecker() *Checker {
+	return checker
+}
+
+// LoadDisposable adds the domains listed one per line to the disposable ones.
+// Empty lines and lines starting with # are ignored.
+func (c *Checker) LoadDisposable(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		domain := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if domain == "" || strings.HasPrefix(domain, "#") {
+			continue
+		}
+		c.disposable[domain] = true
+	}
+	return scanner.Err()
+}
+
+// Check verifies the address, flagging it as invalid if it is malformed,
+// belongs to a disposable domain, its domain doesn't accept emails or cannot
+// be looked up
+func (c *Checker) Check(ctx context.Context, address string) RecipientCheck {
+	check, err := c.check(ctx, address)
+	if err != nil {
+		check.Reason = err.Error()
+	}
+	return check
+}
+
+// check verifies the address, returning an error if the domain lookup failed,
+// e.g. timed out, and the address could not be verified
+func (c *Checker) check(ctx context.Context, address string) (RecipientCheck, error) {
+	check := RecipientCheck{Address: address}
+	parsed, err := validator.ParseAddress(address)
+	if err != nil {
+		check.Reason = err.Error()
+		return check, nil
+	}
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	check.Address = parsed.Address
+
+	if suggestion := suggestDomain(domain); suggestion != "" {
+		check.Suggestion = local + "@" + suggestion
+	}
+	if c.isDisposable(domain) {
+		check.Disposable = true
+		check.Reason = errDisposableDomain.Error()
+		return check, nil
+	}
+	if c.resolver != nil {
+		mx, accepts, err := c.lookup(ctx, domain)
+		if err != nil {
+			return check, err
+		}
+		check.MX = &mx
+		if !accepts {
+			check.Reason = errMissingMX.Error()
+			return check, nil
+		}
+	}
+	check.Valid = true
+	return check, nil
+}
+
+// lookup reports whether the domain has MX records and whether it accepts
+// emails, through MX records or, without them, address records as implicit
+// MX (RFC 5321 section 5.1). Lookup errors other than not found are returned.
+func (c *Checker) lookup(ctx context.Context, domain string) (mx, accepts bool, err error) {
+	records, err := c.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, false, fmt.Errorf("cannot look up %s: %w", domain, err)
+	}
+	if len(records) > 0 {
+		// a null MX (RFC 7505) declares that the domain doesn't accept emails
+		nullMX := len(records) == 1 && (records[0].Host == "." || records[0].Host == "")
+		return true, !nullMX, nil
+	}
+	hosts, err := c.resolver.LookupHost(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return false, false, fmt.Errorf("cannot look up %s: %w", domain, err)
+	}
+	return false, len(hosts) > 0, nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// isDisposable checks the domain and its parent domains against the list
+func (c *Checker) isDisposable(domain string) bool {
+	for {
+		if c.disposable[domain] {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot == -1 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// checkRecipients rejects the comma separated recipients flagged by the
+// checker, if enforced. Failed lookups are returned as they are, so that the
+// email is retried rather than dropped as undeliverable.
+func checkRecipients(ctx context.Context, to string) error {
+	if checker == nil || !checker.Enforce {
+		return nil
+	}
+	addresses, err := validator.ParseAddressList(to, 0)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		check, err := checker.check(ctx, address.Address)
+		if err != nil {
+			return err
+		}
+		if !check.Valid {
+			return fmt.Errorf("%w: %s, %s", ErrUndeliverableRecipient, address.Address, check.Reason)
+		}
+	}
+	return nil
+}
+
+// suggestDomain returns the closest common domain within the max edit
+// distance, empty if the domain is already common or none is close enough
+func suggestDomain(domain string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+	for _, common := range commonDomains {
+		if domain == common {
+			return ""
+		}
+		if d := distance(domain, common); d < bestDistance {
+			best, bestDistance = common, d
+		}
+	}
+	return best
+}
+
+// distance returns the optimal string alignment distance between a and b,
+// counting insertions, deletions, substitutions and adjacent transpositions
+func distance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
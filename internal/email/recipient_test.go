@@ -0,0 +1,125 @@
+package email_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+)
+
+// stubResolver resolves MX and address records of the configured domains
+// only, failing lookups of the domains with an error
+type stubResolver struct {
+	mx     map[string][]*net.MX
+	hosts  map[string][]string
+	errors map[string]error
+}
+
+func (r stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if err, ok := r.errors[name]; ok {
+		return nil, err
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err, ok := r.errors[host]; ok {
+		return nil, err
+	}
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (s *EmailTestSuite) TestCheckDisposable() {
+	checker, err := email.NewChecker(nil, strings.NewReader("# custom\nthrowaway.test\n"))
+	s.Nil(err)
+
+	check := checker.Check(context.Background(), "user@mailinator.com")
+	s.False(check.Valid)
+	s.True(check.Disposable)
+
+	check = checker.Check(context.Background(), "user@inbox.throwaway.test")
+	s.False(check.Valid)
+	s.True(check.Disposable)
+
+	check = checker.Check(context.Background(), "User <user@test.com>")
+	s.True(check.Valid)
+	s.Equal("user@test.com", check.Address)
+	s.Nil(check.MX)
+
+	check = checker.Check(context.Background(), "user")
+	s.False(check.Valid)
+	s.NotEmpty(check.Reason)
+}
+
+func (s *EmailTestSuite) TestCheckSuggestion() {
+	checker, err := email.NewChecker(nil)
+	s.Nil(err)
+
+	s.Equal("user@gmail.com", checker.Check(context.Background(), "user@gmial.com").Suggestion)
+	s.Equal("user@hotmail.com", checker.Check(context.Background(), "user@hotmal.com").Suggestion)
+	s.Equal("user@yahoo.com", checker.Check(context.Background(), "user@yaho.com").Suggestion)
+	s.Empty(checker.Check(context.Background(), "user@gmail.com").Suggestion)
+	s.Empty(checker.Check(context.Background(), "user@company.org").Suggestion)
+}
+
+func (s *EmailTestSuite) TestCheckMX() {
+	checker, err := email.NewChecker(stubResolver{
+		mx: map[string][]*net.MX{
+			"test.com":  {{Host: "mx.test.com", Pref: 10}},
+			"null.test": {{Host: ".", Pref: 0}},
+		},
+		hosts:  map[string][]string{"host.test": {"192.0.2.1"}},
+		errors: map[string]error{"timeout.test": &net.DNSError{Err: "i/o timeout", Name: "timeout.test", IsTimeout: true}},
+	})
+	s.Nil(err)
+
+	check := checker.Check(context.Background(), "user@test.com")
+	s.True(check.Valid)
+	s.True(*check.MX)
+
+	check = checker.Check(context.Background(), "user@nomx.test")
+	s.False(check.Valid)
+	s.False(*check.MX)
+
+	// address records are an implicit MX
+	check = checker.Check(context.Background(), "user@host.test")
+	s.True(check.Valid)
+	s.False(*check.MX)
+
+	check = checker.Check(context.Background(), "user@null.test")
+	s.False(check.Valid)
+	s.True(*check.MX)
+
+	check = checker.Check(context.Background(), "user@timeout.test")
+	s.False(check.Valid)
+	s.Nil(check.MX)
+	s.Contains(check.Reason, "i/o timeout")
+}
+
+func (s *EmailTestSuite) TestCheckRecipientsTransient() {
+	checker, err := email.NewChecker(stubResolver{
+		errors: map[string]error{"timeout.test": &net.DNSError{Err: "i/o timeout", Name: "timeout.test", IsTimeout: true}},
+	})
+	s.Require().Nil(err)
+	checker.Enforce = true
+	email.SetChecker(checker)
+	defer email.SetChecker(nil)
+
+	body := &email.WelcomeEmailBody{From: "noreply@test.com", To: "user@timeout.test", Subject: "Welcome", Params: email.WelcomeEmailBodyParams{Name: "Jane", URL: "https://test.com"}}
+	_, err = body.Email(context.Background())
+	s.NotNil(err)
+	// lookup failures are retried, not dropped as undeliverable
+	s.False(errors.Is(err, email.ErrUndeliverableRecipient))
+
+	body.To = "user@nomx.test"
+	_, err = body.Email(context.Background())
+	s.ErrorIs(err, email.ErrUndeliverableRecipient)
+}
@@ -0,0 +1,132 @@
+package email
+
+import (
+	"context"
+
+	"github.com/xn3cr0nx/email-service/internal/attachment"
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// ReminderEmailBody reminds the recipient to complete an action, e.g. the
+// onboarding. Reminders belong to the product category, so recipients can
+// opt out.
+type ReminderEmailBody struct {
+	// Sender: id of the sender identity, takes precedence over From
+	Sender  string `json:"sender,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale      string                  `json:"locale,omitempty"`
+	Params      ReminderEmailBodyParams `json:"params,omitempty"`
+	Attachments []attachment.Attachment `json:"attachments,omitempty"`
+	// Event: calendar event attached as invite
+	Event *template.Event `json:"event,omitempty"`
+}
+
+type ReminderEmailBodyParams struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+func (b *ReminderEmailBody) ValidateBody() error {
+	var errs validator.FieldErrors
+	if b.From == "" && b.Sender == "" {
+		errs.Add("from", errInvalidFrom)
+	}
+	localize(&errs, template.ReminderEmail, &b.Locale, &b.Subject)
+	validateEnvelope(&errs, &b.From, &b.To, b.Subject)
+	attachment.Validate(&errs, b.Attachments)
+	if b.Event != nil {
+		if err := b.Event.Validate(); err != nil {
+			errs.Add("event", err)
+		}
+	}
+
+	if b.Params.Name == "" || len(b.Params.Name) > 200 {
+		errs.Add("params.name", errInvalidName)
+	}
+	if err := validator.ValidateURL(b.Params.URL); err != nil {
+		errs.Add("params.url", err)
+	}
+	return errs.Err()
+}
+
+// Process renders the email and sends it using the provider
+func (b *ReminderEmailBody) Process(ctx context.Context, m provider.Mailer) error {
+	email, err := b.Email(ctx)
+	if err != nil {
+		failed(ctx, template.ReminderEmail, err)
+		return err
+	}
+	return Deliver(ctx, template.ReminderEmail, m, email)
+}
+
+// Email validates the body and renders the email to be sent
+func (b *ReminderEmailBody) Email(ctx context.Context) (model.Email, error) {
+	if err := b.ValidateBody(); err != nil {
+		return model.Email{}, err
+	}
+
+	identity, to, err := envelope(ctx, template.ReminderEmail, b.Sender, b.From, b.To)
+	if err != nil {
+		return model.Email{}, err
+	}
+	b.From = identity.From()
+
+	email, err := timeRender(ctx, template.ReminderEmail, b.render)
+	if err != nil {
+		return model.Email{}, err
+	}
+	generated, err := template.Attachments(template.ReminderEmail, b.Locale, b.Event, escapeParams(b.Params.Name, b.Params.URL, b.Params.URL)...)
+	if err != nil {
+		return model.Email{}, err
+	}
+	email.Attachments = append(email.Attachments, generated...)
+	if err := attachment.CheckSize(email.Attachments); err != nil {
+		return model.Email{}, err
+	}
+	email.To = to
+	email.ReplyTo = identity.ReplyTo
+	if err := identify(template.ReminderEmail, &email); err != nil {
+		return model.Email{}, err
+	}
+	return email, nil
+}
+
+func (b *ReminderEmailBody) params() interface{} {
+	return &b.Params
+}
+
+// render fills the template with body params
+func (b *ReminderEmailBody) render() (model.Email, error) {
+	filledHtml, err := fillTemplate(template.ReminderEmail, b.Locale, b.Params.Name, b.Params.URL, b.Params.URL)
+	if err != nil {
+		return model.Email{}, err
+	}
+	attachments, err := attachment.Resolve(b.Attachments)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	htmlBody, err := template.PostProcess(template.ReminderEmail, template.FillLayout(filledHtml, b.Locale))
+	if err != nil {
+		return model.Email{}, err
+	}
+	text, err := template.PlainText(htmlBody)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	return model.Email{
+		From:        b.From,
+		To:          b.To,
+		Subject:     b.Subject,
+		HtmlBody:    htmlBody,
+		TextBody:    text,
+		Attachments: attachments,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// RenderBody params of the email type rendered without sending it
+type RenderBody struct {
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale string            `json:"locale,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Rendered subject and bodies of a rendered email
+type Rendered struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// envelopeFields request fields not needed to render an email
+var envelopeFields = map[string]bool{"from": true, "to": true}
+
+// emailType returns the task type of the email type name, accepting both
+// welcome and email:welcome
+func emailType(name string) string {
+	if strings.HasPrefix(name, "email:") {
+		return name
+	}
+	return "email:" + name
+}
+
+// escapeParams escapes the params inserted into HTML templates
+func escapeParams(params ...string) []interface{} {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = html.EscapeString(param)
+	}
+	return args
+}
+
+// fillTemplate fills the template of the email type in the locale with the
+// escaped params, the layout is not applied
+func fillTemplate(taskType, locale string, params ...string) (string, error) {
+	path := template.PathByType(taskType, locale)
+	if path == "" {
+		return "", errTemplateNotFound
+	}
+
+	// cache is a singleton, so it is already initialized
+	cache, err := template.NewTemplateCache(nil)
+	if err != nil {
+		return "", err
+	}
+	content := cache.Get(path)
+	if content == nil {
+		return "", errTemplateNotFound
+	}
+	return template.Fill(string(content), locale, escapeParams(params...)...), nil
+}
+
+// Render validates the params and renders the email type the same way it is
+// rendered when sent, ignoring sender and recipients
+func (b *RenderBody) Render(taskType string) (*Rendered, error) {
+	batch := &BatchEmailBody{Type: taskType, Subject: b.Subject, Locale: b.Locale}
+	body, err := batch.body("", b.Params)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateParams(body); err != nil {
+		return nil, err
+	}
+
+	email, err := body.render()
+	if err != nil {
+		return nil, err
+	}
+	return &Rendered{Subject: email.Subject, HTML: email.HtmlBody, Text: email.TextBody}, nil
+}
+
+// validateParams validates the body, ignoring the errors of the envelope
+func validateParams(body batchBody) error {
+	err := body.ValidateBody()
+	var fields validator.FieldErrors
+	if !errors.As(err, &fields) {
+		return err
+	}
+	var errs validator.FieldErrors
+	for _, field := range fields {
+		if !envelopeFields[field.Field] {
+			errs = append(errs, field)
+		}
+	}
+	return errs.Err()
+}
+
+// Render renders the email type with the params without sending it
+func (s *service) Render(ctx context.Context, taskType string, body *RenderBody) (*Rendered, error) {
+	return body.Render(emailType(taskType))
+}
+
+// Preview renders the email type with the sample data of its template
+func (s *service) Preview(ctx context.Context, taskType, locale string) (*Rendered, error) {
+	taskType = emailType(taskType)
+	params, err := template.Sample(taskType)
+	if err != nil {
+		return nil, err
+	}
+	return (&RenderBody{Locale: locale, Params: params}).Render(taskType)
+}
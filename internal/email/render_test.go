@@ -0,0 +1,73 @@
+package email_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+func (s *EmailTestSuite) TestRender() {
+	service := email.NewService(nil, nil, nil)
+	rendered, err := service.Render(context.Background(), "welcome", &email.RenderBody{
+		Params: map[string]string{"name": "Jane", "url": "https://example.com/confirm"},
+	})
+	s.Nil(err)
+	s.Equal("Welcome!", rendered.Subject)
+	s.Contains(rendered.HTML, `href="https://example.com/confirm"`)
+	s.Contains(rendered.Text, "Welcome Jane")
+	s.Contains(rendered.Text, "https://example.com/confirm")
+	s.NotContains(rendered.Text, "<")
+
+	rendered, err = service.Render(context.Background(), "email:reset", &email.RenderBody{
+		Subject: "Password",
+		Locale:  "it_it",
+		Params:  map[string]string{"url": "https://example.com/reset"},
+	})
+	s.Nil(err)
+	s.Equal("Password", rendered.Subject)
+	s.Contains(rendered.HTML, "https://example.com/reset")
+}
+
+func (s *EmailTestSuite) TestRenderInvalid() {
+	service := email.NewService(nil, nil, nil)
+	_, err := service.Render(context.Background(), "welcome", &email.RenderBody{
+		Params: map[string]string{"url": "invalid"},
+	})
+	var fields validator.FieldErrors
+	s.True(errors.As(err, &fields))
+	s.Len(fields, 2)
+	for _, field := range fields {
+		s.NotEqual("to", field.Field)
+		s.NotEqual("from", field.Field)
+	}
+
+	_, err = service.Render(context.Background(), "layout", &email.RenderBody{})
+	s.NotNil(err)
+	s.False(errors.As(err, &fields))
+}
+
+func (s *EmailTestSuite) TestPreview() {
+	service := email.NewService(nil, nil, nil)
+	rendered, err := service.Preview(context.Background(), "verification", "it-IT")
+	s.Nil(err)
+	s.Contains(rendered.HTML, "Jane Doe")
+	s.NotEmpty(rendered.Subject)
+
+	_, err = service.Preview(context.Background(), "unknown", "")
+	s.NotNil(err)
+}
+
+func (s *EmailTestSuite) TestRenderEscaped() {
+	service := email.NewService(nil, nil, nil)
+	rendered, err := service.Render(context.Background(), "welcome", &email.RenderBody{
+		Params: map[string]string{"name": `<script>alert("x")</script>`, "url": "https://example.com/confirm?a=1&b=2"},
+	})
+	s.Nil(err)
+	s.NotContains(rendered.HTML, "<script>")
+	s.Contains(rendered.HTML, "&lt;script&gt;")
+	s.Contains(rendered.HTML, `href="https://example.com/confirm?a=1&amp;b=2"`)
+	s.Contains(rendered.Text, `Welcome <script>alert("x")</script>`)
+	s.Contains(rendered.Text, "https://example.com/confirm?a=1&b=2")
+}
@@ -2,20 +2,30 @@ package email
 
 import (
 	"context"
-	"fmt"
-	"net/url"
 
+	"github.com/xn3cr0nx/email-service/internal/attachment"
 	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/link"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
 )
 
 type ResetEmailBody struct {
-	From    string           `json:"from,omitempty"`
-	To      string           `json:"to,omitempty"`
-	Subject string           `json:"subject,omitempty"`
-	Params  ResetEmailParams `json:"params,omitempty"`
+	// Sender: id of the sender identity, takes precedence over From
+	Sender  string `json:"sender,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale      string                  `json:"locale,omitempty"`
+	Params      ResetEmailParams        `json:"params,omitempty"`
+	Attachments []attachment.Attachment `json:"attachments,omitempty"`
+	// Event: calendar event attached as invite
+	Event *template.Event `json:"event,omitempty"`
+	// Link: signed link of the frontend generated in place of params.url
+	Link *link.Request `json:"link,omitempty"`
 }
 
 type ResetEmailParams struct {
@@ -26,44 +36,95 @@ func (b *ResetEmailBody) ValidateBody() error {
 	if b.From == "" {
 		b.From = environment.Get().Sender
 	}
-	if b.To == "" {
-		return errInvalidTo
-	}
-	if b.Subject == "" {
-		return errInvalidSubject
+	var errs validator.FieldErrors
+	localize(&errs, template.ResetEmail, &b.Locale, &b.Subject)
+	validateEnvelope(&errs, &b.From, &b.To, b.Subject)
+	attachment.Validate(&errs, b.Attachments)
+	if b.Event != nil {
+		if err := b.Event.Validate(); err != nil {
+			errs.Add("event", err)
+		}
 	}
 
-	if _, err := url.Parse(b.Params.URL); err != nil {
-		return errInvalidURL
-	}
-	return nil
+	validateLink(&errs, b.Link, b.Params.URL)
+	return errs.Err()
 }
 
+// Process renders the email and sends it using the provider
 func (b *ResetEmailBody) Process(ctx context.Context, m provider.Mailer) error {
-	if err := b.ValidateBody(); err != nil {
+	email, err := b.Email(ctx)
+	if err != nil {
+		failed(ctx, template.ResetEmail, err)
 		return err
 	}
+	return Deliver(ctx, template.ResetEmail, m, email)
+}
 
-	path := template.PathByType(template.ResetEmail)
-	if path == "" {
-		return errTemplateNotFound
+// Email validates the body and renders the email to be sent
+func (b *ResetEmailBody) Email(ctx context.Context) (model.Email, error) {
+	if err := b.ValidateBody(); err != nil {
+		return model.Email{}, err
 	}
 
-	// cache is a singleton, so it is already initialized
-	cache, err := template.NewTemplateCache(nil)
+	identity, to, err := envelope(ctx, template.ResetEmail, b.Sender, b.From, b.To)
 	if err != nil {
-		return err
+		return model.Email{}, err
+	}
+	b.From = identity.From()
+	if err := signLink(b.Link, &b.Params.URL); err != nil {
+		return model.Email{}, err
 	}
-	html := string(cache.Get(path))
-	filledHtml := fmt.Sprintf(html, b.Params.URL, b.Params.URL)
 
-	if err := m.Send(ctx, model.Email{
-		From:     b.From,
-		To:       b.To,
-		Subject:  b.Subject,
-		HtmlBody: template.FillLayout(filledHtml),
-	}); err != nil {
-		return err
+	email, err := timeRender(ctx, template.ResetEmail, b.render)
+	if err != nil {
+		return model.Email{}, err
+	}
+	generated, err := template.Attachments(template.ResetEmail, b.Locale, b.Event, escapeParams(b.Params.URL, b.Params.URL)...)
+	if err != nil {
+		return model.Email{}, err
+	}
+	email.Attachments = append(email.Attachments, generated...)
+	if err := attachment.CheckSize(email.Attachments); err != nil {
+		return model.Email{}, err
+	}
+	email.To = to
+	email.ReplyTo = identity.ReplyTo
+	if err := identify(template.ResetEmail, &email); err != nil {
+		return model.Email{}, err
 	}
-	return nil
+	return email, nil
+}
+
+func (b *ResetEmailBody) params() interface{} {
+	return &b.Params
+}
+
+// render fills the template with body params
+func (b *ResetEmailBody) render() (model.Email, error) {
+	filledHtml, err := fillTemplate(template.ResetEmail, b.Locale, b.Params.URL, b.Params.URL)
+	if err != nil {
+		return model.Email{}, err
+	}
+	attachments, err := attachment.Resolve(b.Attachments)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	htmlBody, err := template.PostProcess(template.ResetEmail, template.FillLayout(filledHtml, b.Locale))
+	if err != nil {
+		return model.Email{}, err
+	}
+	text, err := template.PlainText(htmlBody)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	return model.Email{
+		From:        b.From,
+		To:          b.To,
+		Subject:     b.Subject,
+		HtmlBody:    htmlBody,
+		TextBody:    text,
+		Attachments: attachments,
+	}, nil
 }
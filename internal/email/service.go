@@ -2,10 +2,15 @@ package email
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
 	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/sender"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
 	"github.com/xn3cr0nx/email-service/pkg/validator"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
@@ -13,8 +18,24 @@ import (
 
 // Service interface exports available methods for user service
 type Service interface {
-	Send(context.Context, *WelcomeEmailBody) error
-	// SendBatch() error
+	Send(context.Context, *WelcomeEmailBody) (*Sent, error)
+	SendBatch(context.Context, *BatchEmailBody) (*BatchResult, error)
+	Validate(context.Context, *ValidateBody) ([]RecipientCheck, error)
+	Render(context.Context, string, *RenderBody) (*Rendered, error)
+	Preview(ctx context.Context, taskType, locale string) (*Rendered, error)
+}
+
+// maxValidateAddresses maximum number of addresses checked in a single validate request
+const maxValidateAddresses = 100
+
+// ValidateBody list of recipient addresses to verify
+type ValidateBody struct {
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Sent email, the Message-ID attributes provider and tracking events
+type Sent struct {
+	MessageID string `json:"message_id"`
 }
 
 type service struct {
@@ -45,8 +66,15 @@ func NewService(m provider.Mailer, tracer trace.Tracer, meter metric.Meter) *ser
 //
 // @Param email body WelcomeEmailBody true "welcome email parameters"
 //
-// @Success 200 {string} Ok
+// @Success 200 {object} Sent
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
 // @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 403 {string} string
+// @Failure 422 {string} string
+// @Failure 429 {string} string
 // @Failure 500 {string} string
 func Handler(s Service) func(echo.Context) error {
 	return func(c echo.Context) error {
@@ -55,17 +83,237 @@ func Handler(s Service) func(echo.Context) error {
 			return err
 		}
 
-		err := s.Send(c.Request().Context(), b)
+		sent, err := s.Send(c.Request().Context(), b)
+		if err != nil {
+			return HTTPError(err)
+		}
+
+		return c.JSON(http.StatusOK, sent)
+	}
+}
+
+// batch godoc
+// @ID email-batch
+//
+// @Router /email/batch [post]
+// @Summary Batch email
+// @Description Render an email type for each recipient and send them in batch
+// @Tags email
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param batch body BatchEmailBody true "batch email parameters"
+//
+// @Success 200 {object} BatchResult
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 403 {string} string
+// @Failure 429 {string} string
+// @Failure 500 {string} string
+func BatchHandler(s Service) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(BatchEmailBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		result, err := s.SendBatch(c.Request().Context(), b)
 		if err != nil {
+			return HTTPError(err)
+		}
+
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+// validate godoc
+// @ID validate
+//
+// @Router /validate [post]
+// @Summary Validate recipients
+// @Description Check recipient addresses for syntax errors, disposable domains, domain typos and missing MX records
+// @Tags email
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param validate body ValidateBody true "addresses to validate"
+//
+// @Success 200 {array} RecipientCheck
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 429 {string} string
+// @Failure 500 {string} string
+func ValidateHandler(s Service) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(ValidateBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		checks, err := s.Validate(c.Request().Context(), b)
+		if err != nil {
+			return HTTPError(err)
+		}
+
+		return c.JSON(http.StatusOK, checks)
+	}
+}
+
+// render godoc
+// @ID render-template
+//
+// @Router /templates/{type}/render [post]
+// @Summary Render email
+// @Description Render the email type with the params, returning subject, HTML and text without sending it
+// @Tags templates
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param type path string true "email type, e.g. welcome"
+// @Param render body RenderBody true "email params"
+//
+// @Success 200 {object} Rendered
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func RenderHandler(s Service) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(RenderBody)
+		if err := validator.Struct(&c, b); err != nil {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, "Ok")
+		rendered, err := s.Render(c.Request().Context(), c.Param("type"), b)
+		if err != nil {
+			return HTTPError(err)
+		}
+
+		return c.JSON(http.StatusOK, rendered)
+	}
+}
+
+// preview godoc
+// @ID preview-template
+//
+// @Router /templates/{type}/preview [get]
+// @Summary Preview email
+// @Description Render the email type with the sample data of its template
+// @Tags templates
+//
+// @Produce  html
+//
+// @Param type path string true "email type, e.g. welcome"
+// @Param locale query string false "recipient locale, e.g. it-IT"
+//
+// @Success 200 {string} string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func PreviewHandler(s Service) func(echo.Context) error {
+	return func(c echo.Context) error {
+		rendered, err := s.Preview(c.Request().Context(), c.Param("type"), c.QueryParam("locale"))
+		if err != nil {
+			return HTTPError(err)
+		}
+
+		return c.HTML(http.StatusOK, rendered.HTML)
 	}
 }
 
 // Send processes email request and send using injected email client
-func (s *service) Send(ctx context.Context, body *WelcomeEmailBody) (err error) {
-	err = body.Process(ctx, s.Mailer)
-	return
+func (s *service) Send(ctx context.Context, body *WelcomeEmailBody) (*Sent, error) {
+	id, err := body.Send(ctx, s.Mailer)
+	logger.Info("Email Service", "Processed email request", logger.Params{"client": clientName(ctx), "to": body.To, "from": body.From, "message_id": id, "error": err})
+	if err != nil {
+		return nil, err
+	}
+	return &Sent{MessageID: id}, nil
+}
+
+// SendBatch processes batch email request and send using injected email client
+func (s *service) SendBatch(ctx context.Context, body *BatchEmailBody) (*BatchResult, error) {
+	result, err := body.Process(ctx, s.Mailer)
+	params := logger.Params{"client": clientName(ctx), "type": body.Type, "from": body.From, "recipients": len(body.Recipients), "error": err}
+	if result != nil {
+		params["sent"], params["failed"] = result.Sent, result.Failed
+	}
+	logger.Info("Email Service", "Processed batch email request", params)
+	return result, err
+}
+
+// Validate checks each address using the recipient checker, relying on a
+// checker of the bundled disposable domains if none is configured
+func (s *service) Validate(ctx context.Context, body *ValidateBody) ([]RecipientCheck, error) {
+	var errs validator.FieldErrors
+	if len(body.Addresses) == 0 {
+		errs.Add("addresses", errInvalidRecipients)
+	}
+	if len(body.Addresses) > maxValidateAddresses {
+		errs.Add("addresses", errTooManyAddresses)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	c := GetChecker()
+	if c == nil {
+		var err error
+		if c, err = NewChecker(nil); err != nil {
+			return nil, err
+		}
+	}
+	checks := make([]RecipientCheck, len(body.Addresses))
+	for i, address := range body.Addresses {
+		checks[i] = c.Check(ctx, address)
+	}
+	return checks, nil
+}
+
+// clientName returns the name of the authenticated client, if any
+func clientName(ctx context.Context) string {
+	if c := auth.FromContext(ctx); c != nil {
+		return c.Name
+	}
+	return ""
+}
+
+// HTTPError maps service errors to the corresponding HTTP status
+func HTTPError(err error) error {
+	var fields validator.FieldErrors
+	switch {
+	case errors.As(err, &fields):
+		return echo.NewHTTPError(http.StatusBadRequest, fields)
+	case errors.Is(err, auth.ErrForbidden), errors.Is(err, sender.ErrUnknownSender),
+		errors.Is(err, sender.ErrSenderNotAllowed):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrRecipientOptedOut), errors.Is(err, ErrUndeliverableRecipient):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, auth.ErrQuotaExceeded):
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, provider.ErrRateLimited):
+		// the error handler reads the Retry-After delay of the internal error
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error()).SetInternal(err)
+	case errors.Is(err, errInvalidParams):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, errInvalidType), errors.Is(err, errorx.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return err
 }
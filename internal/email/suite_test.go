@@ -0,0 +1,30 @@
+package email_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(EmailTestSuite))
+}
+
+type EmailTestSuite struct {
+	suite.Suite
+}
+
+func (s *EmailTestSuite) SetupSuite() {
+	logger.Setup()
+	env := environment.New()
+	env.Sender = "noreply@test.com"
+	environment.Set(env)
+
+	// the default templates are used, the directory doesn't exist
+	dir := "templates_test"
+	_, err := template.NewTemplateCache(&dir)
+	s.Nil(err)
+}
@@ -2,72 +2,150 @@ package email
 
 import (
 	"context"
-	"fmt"
-	"net/url"
+	gohtml "html"
+	"strings"
 
+	"github.com/xn3cr0nx/email-service/internal/attachment"
 	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/link"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
 )
 
 type VerificationEmailBody struct {
-	From    string                  `json:"from,omitempty"`
-	To      string                  `json:"to,omitempty"`
-	Subject string                  `json:"subject,omitempty"`
-	Params  VerificationEmailParams `json:"params,omitempty"`
+	// Sender: id of the sender identity, takes precedence over From
+	Sender  string `json:"sender,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale      string                  `json:"locale,omitempty"`
+	Params      VerificationEmailParams `json:"params,omitempty"`
+	Attachments []attachment.Attachment `json:"attachments,omitempty"`
+	// Event: calendar event attached as invite
+	Event *template.Event `json:"event,omitempty"`
+	// Link: signed link of the frontend generated in place of params.url
+	Link *link.Request `json:"link,omitempty"`
 }
 
 type VerificationEmailParams struct {
 	Name string `json:"name,omitempty"`
 	URL  string `json:"url,omitempty"`
+	// Code: verification code shown below the template content
+	Code string `json:"code,omitempty"`
 }
 
 func (b *VerificationEmailBody) ValidateBody() error {
 	if b.From == "" {
 		b.From = environment.Get().Sender
 	}
-	if b.To == "" {
-		return errInvalidTo
-	}
-	if b.Subject == "" {
-		return errInvalidSubject
+	var errs validator.FieldErrors
+	localize(&errs, template.VerificationEmail, &b.Locale, &b.Subject)
+	validateEnvelope(&errs, &b.From, &b.To, b.Subject)
+	attachment.Validate(&errs, b.Attachments)
+	if b.Event != nil {
+		if err := b.Event.Validate(); err != nil {
+			errs.Add("event", err)
+		}
 	}
 
 	if b.Params.Name == "" || len(b.Params.Name) > 200 {
-		return errInvalidName
-	}
-	if _, err := url.Parse(b.Params.URL); err != nil {
-		return errInvalidURL
+		errs.Add("params.name", errInvalidName)
 	}
-	return nil
+	validateLink(&errs, b.Link, b.Params.URL)
+	return errs.Err()
 }
 
+// Process renders the email and sends it using the provider
 func (b *VerificationEmailBody) Process(ctx context.Context, m provider.Mailer) error {
-	if err := b.ValidateBody(); err != nil {
+	email, err := b.Email(ctx)
+	if err != nil {
+		failed(ctx, template.VerificationEmail, err)
 		return err
 	}
+	return Deliver(ctx, template.VerificationEmail, m, email)
+}
 
-	path := template.PathByType(template.VerificationEmail)
-	if path == "" {
-		return errTemplateNotFound
+// Email validates the body and renders the email to be sent
+func (b *VerificationEmailBody) Email(ctx context.Context) (model.Email, error) {
+	if err := b.ValidateBody(); err != nil {
+		return model.Email{}, err
 	}
 
-	// cache is a singleton, so it is already initialized
-	cache, err := template.NewTemplateCache(nil)
+	identity, to, err := envelope(ctx, template.VerificationEmail, b.Sender, b.From, b.To)
 	if err != nil {
-		return err
+		return model.Email{}, err
+	}
+	b.From = identity.From()
+	if err := signLink(b.Link, &b.Params.URL); err != nil {
+		return model.Email{}, err
 	}
-	html := string(cache.Get(path))
-	filledHtml := fmt.Sprintf(html, b.Params.Name, b.Params.URL, b.Params.URL)
 
-	if err := m.Send(ctx, model.Email{
-		From:     b.From,
-		To:       b.To,
-		Subject:  b.Subject,
-		HtmlBody: template.FillLayout(filledHtml),
-	}); err != nil {
-		return err
+	email, err := timeRender(ctx, template.VerificationEmail, b.render)
+	if err != nil {
+		return model.Email{}, err
+	}
+	generated, err := template.Attachments(template.VerificationEmail, b.Locale, b.Event, escapeParams(b.Params.Name, b.Params.URL, b.Params.URL)...)
+	if err != nil {
+		return model.Email{}, err
+	}
+	email.Attachments = append(email.Attachments, generated...)
+	if err := attachment.CheckSize(email.Attachments); err != nil {
+		return model.Email{}, err
+	}
+	email.To = to
+	email.ReplyTo = identity.ReplyTo
+	if err := identify(template.VerificationEmail, &email); err != nil {
+		return model.Email{}, err
+	}
+	return email, nil
+}
+
+func (b *VerificationEmailBody) params() interface{} {
+	return &b.Params
+}
+
+// render fills the template with body params
+func (b *VerificationEmailBody) render() (model.Email, error) {
+	filledHtml, err := fillTemplate(template.VerificationEmail, b.Locale, b.Params.Name, b.Params.URL, b.Params.URL)
+	if err != nil {
+		return model.Email{}, err
+	}
+	if b.Params.Code != "" {
+		filledHtml += codeBlock(b.Params.Code, b.Locale)
+	}
+	attachments, err := attachment.Resolve(b.Attachments)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	htmlBody, err := template.PostProcess(template.VerificationEmail, template.FillLayout(filledHtml, b.Locale))
+	if err != nil {
+		return model.Email{}, err
+	}
+	text, err := template.PlainText(htmlBody)
+	if err != nil {
+		return model.Email{}, err
+	}
+
+	return model.Email{
+		From:        b.From,
+		To:          b.To,
+		Subject:     b.Subject,
+		HtmlBody:    htmlBody,
+		TextBody:    text,
+		Attachments: attachments,
+	}, nil
+}
+
+// codeBlock returns the paragraph showing the code with the verification.code
+// message of the locale, the bare code if missing
+func codeBlock(code, locale string) string {
+	message := template.Message("verification.code", locale)
+	if !strings.Contains(message, "{code}") {
+		message = "{code}"
 	}
-	return nil
+	return `<p style="font-size: 18px"><strong>` + gohtml.EscapeString(strings.ReplaceAll(message, "{code}", code)) + `</strong></p>`
 }
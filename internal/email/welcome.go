@@ -2,19 +2,26 @@ package email
 
 import (
 	"context"
-	"fmt"
-	"net/url"
 
+	"github.com/xn3cr0nx/email-service/internal/attachment"
 	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/internal/template"
 	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
 )
 
 type WelcomeEmailBody struct {
-	From    string                 `json:"from,omitempty"`
-	To      string                 `json:"to,omitempty"`
-	Subject string                 `json:"subject,omitempty"`
-	Params  WelcomeEmailBodyParams `json:"params,omitempty"`
+	// Sender: id of the sender identity, takes precedence over From
+	Sender  string `json:"sender,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale      string                  `json:"locale,omitempty"`
+	Params      WelcomeEmailBodyParams  `json:"params,omitempty"`
+	Attachments []attachment.Attachment `json:"attachments,omitempty"`
+	// Event: calendar event attached as invite
+	Event *template.Event `json:"event,omitempty"`
 }
 
 type WelcomeEmailBodyParams struct {
@@ -23,50 +30,110 @@ type WelcomeEmailBodyParams struct {
 }
 
 func (b *WelcomeEmailBody) ValidateBody() error {
-	if b.From == "" {
-		return errInvalidFrom
+	var errs validator.FieldErrors
+	if b.From == "" && b.Sender == "" {
+		errs.Add("from", errInvalidFrom)
 	}
-	if b.To == "" {
-		return errInvalidTo
-	}
-	if b.Subject == "" {
-		return errInvalidSubject
+	localize(&errs, template.WelcomeEmail, &b.Locale, &b.Subject)
+	validateEnvelope(&errs, &b.From, &b.To, b.Subject)
+	attachment.Validate(&errs, b.Attachments)
+	if b.Event != nil {
+		if err := b.Event.Validate(); err != nil {
+			errs.Add("event", err)
+		}
 	}
 
 	if b.Params.Name == "" || len(b.Params.Name) > 200 {
-		return errInvalidName
+		errs.Add("params.name", errInvalidName)
 	}
-	if _, err := url.Parse(b.Params.URL); err != nil {
-		return errInvalidURL
+	if err := validator.ValidateURL(b.Params.URL); err != nil {
+		errs.Add("params.url", err)
 	}
-	return nil
+	return errs.Err()
 }
 
+// Process renders the email and sends it using the provider
 func (b *WelcomeEmailBody) Process(ctx context.Context, m provider.Mailer) error {
+	_, err := b.Send(ctx, m)
+	return err
+}
+
+// Send renders the email and sends it using the provider, returning its
+// Message-ID
+func (b *WelcomeEmailBody) Send(ctx context.Context, m provider.Mailer) (string, error) {
+	email, err := b.Email(ctx)
+	if err != nil {
+		failed(ctx, template.WelcomeEmail, err)
+		return "", err
+	}
+	if err := Deliver(ctx, template.WelcomeEmail, m, email); err != nil {
+		return "", err
+	}
+	return email.Header("Message-ID"), nil
+}
+
+// Email validates the body and renders the email to be sent
+func (b *WelcomeEmailBody) Email(ctx context.Context) (model.Email, error) {
 	if err := b.ValidateBody(); err != nil {
-		return err
+		return model.Email{}, err
+	}
+
+	identity, to, err := envelope(ctx, template.WelcomeEmail, b.Sender, b.From, b.To)
+	if err != nil {
+		return model.Email{}, err
+	}
+	b.From = identity.From()
+
+	email, err := timeRender(ctx, template.WelcomeEmail, b.render)
+	if err != nil {
+		return model.Email{}, err
+	}
+	generated, err := template.Attachments(template.WelcomeEmail, b.Locale, b.Event, escapeParams(b.Params.Name, b.Params.URL, b.Params.URL)...)
+	if err != nil {
+		return model.Email{}, err
+	}
+	email.Attachments = append(email.Attachments, generated...)
+	if err := attachment.CheckSize(email.Attachments); err != nil {
+		return model.Email{}, err
 	}
+	email.To = to
+	email.ReplyTo = identity.ReplyTo
+	if err := identify(template.WelcomeEmail, &email); err != nil {
+		return model.Email{}, err
+	}
+	return email, nil
+}
 
-	path := template.PathByType(template.WelcomeEmail)
-	if path == "" {
-		return errTemplateNotFound
+func (b *WelcomeEmailBody) params() interface{} {
+	return &b.Params
+}
+
+// render fills the template with body params
+func (b *WelcomeEmailBody) render() (model.Email, error) {
+	filledHtml, err := fillTemplate(template.WelcomeEmail, b.Locale, b.Params.Name, b.Params.URL, b.Params.URL)
+	if err != nil {
+		return model.Email{}, err
+	}
+	attachments, err := attachment.Resolve(b.Attachments)
+	if err != nil {
+		return model.Email{}, err
 	}
 
-	// cache is a singleton, so it is already initialized
-	cache, err := template.NewTemplateCache(nil)
+	htmlBody, err := template.PostProcess(template.WelcomeEmail, template.FillLayout(filledHtml, b.Locale))
+	if err != nil {
+		return model.Email{}, err
+	}
+	text, err := template.PlainText(htmlBody)
 	if err != nil {
-		return err
+		return model.Email{}, err
 	}
-	html := string(cache.Get(path))
-	filledHtml := fmt.Sprintf(html, b.Params.Name, b.Params.URL)
 
-	if err := m.Send(ctx, model.Email{
-		From:     b.From,
-		To:       b.To,
-		Subject:  b.Subject,
-		HtmlBody: template.FillLayout(filledHtml),
-	}); err != nil {
-		return err
-	}
-	return nil
+	return model.Email{
+		From:        b.From,
+		To:          b.To,
+		Subject:     b.Subject,
+		HtmlBody:    htmlBody,
+		TextBody:    text,
+		Attachments: attachments,
+	}, nil
 }
@@ -15,9 +15,16 @@ type Env struct {
 	FrontendHost string
 	Queue        string
 
+	// TemplateWatch reloads templates when files in TemplateDir change
+	TemplateWatch bool
+
 	Provider string
 	Backend  string
 
+	// rate limit related variables
+	RateLimit      float64
+	RateLimitBurst int
+
 	// postmark related variables
 	PostmarkServer  string
 	PostmarkAccount string
@@ -29,6 +36,12 @@ type Env struct {
 	MailgunDomain string
 	MailgunAPIKey string
 
+	// smtp related variables
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
 	// redis related variables
 	RedisHost     string
 	RedisPort     int
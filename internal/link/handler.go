@@ -0,0 +1,50 @@
+package link
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// VerifyBody request body to verify a link token
+type VerifyBody struct {
+	Token string `json:"token" validate:"required"`
+	// Path: path the token must have been issued for, any if empty
+	Path string `json:"path,omitempty"`
+}
+
+// verifyLink godoc
+// @ID verify-link
+//
+// @Router /links/verify [post]
+// @Summary Verify link
+// @Description Verify the token of a signed link, returning its path, claims and expiration
+// @Tags links
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param verify body VerifyBody true "token to verify"
+//
+// @Success 200 {object} Claims
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 422 {string} string
+func VerifyHandler(s *Signer) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(VerifyBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		claims, err := s.Verify(b.Token, b.Path)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+		return c.JSON(http.StatusOK, claims)
+	}
+}
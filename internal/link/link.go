@@ -0,0 +1,289 @@
+package link
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// Signing algorithms of the keys
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+)
+
+// MaxTTL maximum validity of a link
+const MaxTTL = 30 * 24 * time.Hour
+
+// pathClaim claim binding the token to the path of the link
+const pathClaim = "pth"
+
+// reserved claims set by the signer, they can't be set by requests
+var reserved = map[string]bool{pathClaim: true, "exp": true, "iat": true, "nbf": true, "jti": true}
+
+var (
+	errNoKey          = errors.New("no active link signing key")
+	errInvalidPath    = errors.New("invalid link path, expected an absolute path")
+	errReservedClaim  = errors.New("reserved link claim")
+	errInvalidTTL     = errors.New("invalid link ttl")
+	errUnknownKey     = errors.New("unknown link signing key")
+	errUnsupportedAlg = errors.New("unsupported link signing algorithm")
+
+	// ErrDisabled returned when links are requested but no signer is configured
+	ErrDisabled = errors.New("signed links are not enabled")
+	// ErrInvalidToken returned for malformed, expired or not signed tokens
+	ErrInvalidToken = errors.New("invalid link token")
+)
+
+// Config signed links configuration. Multiple keys allow rotation: links
+// are signed with the key with the most recent not_before in the past, tokens
+// of every configured key are verified.
+type Config struct {
+	TTL  time.Duration `mapstructure:"ttl"`
+	Keys []KeyConfig   `mapstructure:"keys"`
+}
+
+// KeyConfig signing key of the links, secret of HS256 keys or PEM PKCS#8
+// private key file path of EdDSA keys. not_before is an RFC 3339 time.
+type KeyConfig struct {
+	ID         string `mapstructure:"id"`
+	Algorithm  string `mapstructure:"algorithm"`
+	Secret     string `mapstructure:"secret"`
+	PrivateKey string `mapstructure:"private_key"`
+	NotBefore  string `mapstructure:"not_before"`
+}
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	sign      interface{}
+	verify    interface{}
+	notBefore time.Time
+}
+
+// Signer generates and verifies signed expiring links of the frontend
+type Signer struct {
+	host *url.URL
+	ttl  time.Duration
+	// keys sorted by most recent not before
+	keys []key
+	byID map[string]*key
+}
+
+var signer *Signer
+
+// Set assign the shared global signer
+func Set(s *Signer) {
+	signer = s
+}
+
+// Get returns the shared global signer, nil if signed links are disabled
+func Get() *Signer {
+	return signer
+}
+
+// New returns a signer of links to the frontend host with the configured keys
+func New(host string, conf Config) (*Signer, error) {
+	base, err := url.Parse(strings.TrimSuffix(host, "/"))
+	if err != nil || !base.IsAbs() || base.Host == "" {
+		return nil, fmt.Errorf("%w: invalid frontend host %s", errorx.ErrConfig, host)
+	}
+	if conf.TTL <= 0 || conf.TTL > MaxTTL {
+		return nil, fmt.Errorf("%w: %v", errorx.ErrConfig, errInvalidTTL)
+	}
+	if len(conf.Keys) == 0 {
+		return nil, fmt.Errorf("%w: %v", errorx.ErrConfig, errNoKey)
+	}
+
+	s := &Signer{host: base, ttl: conf.TTL, byID: make(map[string]*key)}
+	for _, c := range conf.Keys {
+		k, err := loadKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("%w: link key %s: %v", errorx.ErrConfig, c.ID, err)
+		}
+		s.keys = append(s.keys, k)
+	}
+	sort.SliceStable(s.keys, func(i, j int) bool {
+		return s.keys[i].notBefore.After(s.keys[j].notBefore)
+	})
+	for i := range s.keys {
+		if _, ok := s.byID[s.keys[i].id]; ok {
+			return nil, fmt.Errorf("%w: duplicated link key %s", errorx.ErrConfig, s.keys[i].id)
+		}
+		s.byID[s.keys[i].id] = &s.keys[i]
+	}
+	return s, nil
+}
+
+func loadKey(c KeyConfig) (key, error) {
+	k := key{id: c.ID}
+	if c.ID == "" {
+		return k, errors.New("missing id")
+	}
+	if c.NotBefore != "" {
+		var err error
+		if k.notBefore, err = time.Parse(time.RFC3339, c.NotBefore); err != nil {
+			return k, err
+		}
+	}
+
+	switch c.Algorithm {
+	case HS256:
+		if len(c.Secret) < 32 {
+			return k, errors.New("secret must be at least 32 bytes")
+		}
+		k.method, k.sign, k.verify = jwt.SigningMethodHS256, []byte(c.Secret), []byte(c.Secret)
+	case EdDSA:
+		data, err := os.ReadFile(c.PrivateKey)
+		if err != nil {
+			return k, err
+		}
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return k, err
+		}
+		k.method, k.sign, k.verify = jwt.SigningMethodEdDSA, private, private.(ed25519.PrivateKey).Public()
+	default:
+		return k, fmt.Errorf("%w %s", errUnsupportedAlg, c.Algorithm)
+	}
+	return k, nil
+}
+
+// Request link to generate, the path of the frontend with the claims
+// embedded in the token. TTL is in seconds, defaults to the configured ttl.
+type Request struct {
+	Path   string                 `json:"path"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+	TTL    int                    `json:"ttl,omitempty"`
+}
+
+// Validate checks the path is absolute, claims are not reserved and the ttl
+// is within MaxTTL
+func (r *Request) Validate() error {
+	if u, err := url.Parse(r.Path); err != nil || !strings.HasPrefix(r.Path, "/") || strings.HasPrefix(r.Path, "//") || u.Fragment != "" {
+		return errInvalidPath
+	}
+	for name := range r.Claims {
+		if reserved[name] {
+			return fmt.Errorf("%w %s", errReservedClaim, name)
+		}
+	}
+	if r.TTL < 0 || time.Duration(r.TTL)*time.Second > MaxTTL {
+		return errInvalidTTL
+	}
+	return nil
+}
+
+// Claims verified claims of a link
+type Claims struct {
+	Path      string                 `json:"path"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+	ExpiresAt time.Time              `json:"expires_at"`
+}
+
+// URL returns the frontend URL of the request path with the signed token in
+// the token query param
+func (s *Signer) URL(r *Request) (string, error) {
+	return s.URLAt(r, time.Now())
+}
+
+// URLAt returns the URL signed at the given time with the key active at
+// that time
+func (s *Signer) URLAt(r *Request, now time.Time) (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	k := s.key(now)
+	if k == nil {
+		return "", errNoKey
+	}
+	ttl := s.ttl
+	if r.TTL > 0 {
+		ttl = time.Duration(r.TTL) * time.Second
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		pathClaim: r.Path,
+		"iat":     now.Unix(),
+		"exp":     now.Add(ttl).Unix(),
+		"jti":     hex.EncodeToString(id),
+	}
+	for name, value := range r.Claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.id
+	signed, err := token.SignedString(k.sign)
+	if err != nil {
+		return "", err
+	}
+
+	u, _ := url.Parse(s.host.String() + r.Path)
+	query := u.Query()
+	query.Set("token", signed)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// key returns the most recent key active at the given time
+func (s *Signer) key(now time.Time) *key {
+	for i := range s.keys {
+		if !s.keys[i].notBefore.After(now) {
+			return &s.keys[i]
+		}
+	}
+	return nil
+}
+
+// Verify returns the claims of the token, ErrInvalidToken if it is malformed,
+// expired, not signed by a configured key or, if path is not empty, issued
+// for a different path
+func (s *Signer) Verify(token, path string) (*Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := new(jwt.Parser).ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, ok := s.byID[kid]
+		if !ok {
+			return nil, errUnknownKey
+		}
+		if t.Method.Alg() != k.method.Alg() {
+			return nil, errUnsupportedAlg
+		}
+		return k.verify, nil
+	})
+	if err != nil || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrInvalidToken
+	}
+	p, _ := claims[pathClaim].(string)
+	if p == "" || (path != "" && p != path) {
+		return nil, ErrInvalidToken
+	}
+
+	// exp has been verified, so it is a number
+	exp, _ := claims["exp"].(float64)
+	verified := &Claims{Path: p, ExpiresAt: time.Unix(int64(exp), 0).UTC()}
+	for name, value := range claims {
+		if reserved[name] {
+			continue
+		}
+		if verified.Claims == nil {
+			verified.Claims = make(map[string]interface{})
+		}
+		verified.Claims[name] = value
+	}
+	return verified, nil
+}
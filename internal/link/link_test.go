@@ -0,0 +1,142 @@
+package link_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/link"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// token returns the token query param of the link
+func (s *LinkTestSuite) token(link string) string {
+	u, err := url.Parse(link)
+	s.Require().Nil(err)
+	return u.Query().Get("token")
+}
+
+func (s *LinkTestSuite) TestNew() {
+	_, err := link.New("frontend.com", link.Config{TTL: time.Hour, Keys: []link.KeyConfig{{ID: "k1", Algorithm: link.HS256, Secret: secret}}})
+	s.NotNil(err)
+	_, err = link.New("https://frontend.com", link.Config{TTL: time.Hour})
+	s.NotNil(err)
+	_, err = link.New("https://frontend.com", link.Config{TTL: time.Hour, Keys: []link.KeyConfig{{ID: "k1", Algorithm: link.HS256, Secret: "short"}}})
+	s.NotNil(err)
+	_, err = link.New("https://frontend.com", link.Config{TTL: time.Hour, Keys: []link.KeyConfig{{ID: "k1", Algorithm: "RS256", Secret: secret}}})
+	s.NotNil(err)
+	_, err = link.New("https://frontend.com", link.Config{TTL: time.Hour, Keys: []link.KeyConfig{
+		{ID: "k1", Algorithm: link.HS256, Secret: secret},
+		{ID: "k1", Algorithm: link.HS256, Secret: secret, NotBefore: "2022-01-01T00:00:00Z"},
+	}})
+	s.NotNil(err)
+}
+
+func (s *LinkTestSuite) TestURL() {
+	u, err := s.Signer.URL(&link.Request{Path: "/reset?lang=it", Claims: map[string]interface{}{"sub": "user-1"}})
+	s.Nil(err)
+	s.True(strings.HasPrefix(u, "https://frontend.com/reset?"))
+	parsed, _ := url.Parse(u)
+	s.Equal("it", parsed.Query().Get("lang"))
+
+	claims, err := s.Signer.Verify(s.token(u), "/reset?lang=it")
+	s.Nil(err)
+	s.Equal("/reset?lang=it", claims.Path)
+	s.Equal(map[string]interface{}{"sub": "user-1"}, claims.Claims)
+	s.WithinDuration(time.Now().Add(time.Hour), claims.ExpiresAt, 2*time.Second)
+
+	_, err = s.Signer.Verify(s.token(u), "/verify")
+	s.ErrorIs(err, link.ErrInvalidToken)
+	_, err = s.Signer.Verify(s.token(u)+"x", "")
+	s.ErrorIs(err, link.ErrInvalidToken)
+
+	for _, r := range []link.Request{
+		{Path: "reset"},
+		{Path: "//evil.com/reset"},
+		{Path: "/reset", Claims: map[string]interface{}{"exp": 1}},
+		{Path: "/reset", TTL: -1},
+		{Path: "/reset", TTL: int(link.MaxTTL/time.Second) + 1},
+	} {
+		_, err := s.Signer.URL(&r)
+		s.NotNil(err, r)
+	}
+}
+
+func (s *LinkTestSuite) TestExpired() {
+	u, err := s.Signer.URLAt(&link.Request{Path: "/reset", TTL: 60}, time.Now().Add(-2*time.Minute))
+	s.Nil(err)
+	_, err = s.Signer.Verify(s.token(u), "/reset")
+	s.ErrorIs(err, link.ErrInvalidToken)
+}
+
+func (s *LinkTestSuite) TestKeyRotation() {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().Nil(err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	s.Require().Nil(err)
+	path := filepath.Join(s.T().TempDir(), "link.pem")
+	s.Require().Nil(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	rotation := time.Now().Add(-time.Minute)
+	signer, err := link.New("https://frontend.com", link.Config{TTL: 2 * time.Hour, Keys: []link.KeyConfig{
+		{ID: "k1", Algorithm: link.HS256, Secret: secret},
+		{ID: "k2", Algorithm: link.EdDSA, PrivateKey: path, NotBefore: rotation.Format(time.RFC3339)},
+	}})
+	s.Require().Nil(err)
+
+	before, err := signer.URLAt(&link.Request{Path: "/verify"}, rotation.Add(-time.Minute))
+	s.Nil(err)
+	after, err := signer.URL(&link.Request{Path: "/verify"})
+	s.Nil(err)
+
+	// tokens of both keys are verified, the first is signed by the previous one
+	_, err = s.Signer.Verify(s.token(before), "/verify")
+	s.Nil(err)
+	_, err = signer.Verify(s.token(before), "/verify")
+	s.Nil(err)
+	_, err = signer.Verify(s.token(after), "/verify")
+	s.Nil(err)
+	_, err = s.Signer.Verify(s.token(after), "/verify")
+	s.ErrorIs(err, link.ErrInvalidToken)
+}
+
+func (s *LinkTestSuite) TestVerifyHandler() {
+	e := echo.New()
+	e.Validator = validator.NewValidator()
+	e.POST("/links/verify", link.VerifyHandler(s.Signer))
+
+	u, err := s.Signer.URL(&link.Request{Path: "/reset", Claims: map[string]interface{}{"user_id": 42}})
+	s.Nil(err)
+
+	for _, test := range []struct {
+		body string
+		code int
+	}{
+		{`{"token":"` + s.token(u) + `","path":"/reset"}`, http.StatusOK},
+		{`{"token":"` + s.token(u) + `","path":"/verify"}`, http.StatusUnprocessableEntity},
+		{`{"path":"/reset"}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/links/verify", strings.NewReader(test.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		s.Equal(test.code, rec.Code, test.body)
+
+		if test.code == http.StatusOK {
+			var claims link.Claims
+			s.Nil(json.Unmarshal(rec.Body.Bytes(), &claims))
+			s.Equal("/reset", claims.Path)
+			s.Equal(float64(42), claims.Claims["user_id"])
+		}
+	}
+}
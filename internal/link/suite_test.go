@@ -0,0 +1,30 @@
+package link_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/link"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(LinkTestSuite))
+}
+
+type LinkTestSuite struct {
+	suite.Suite
+
+	Signer *link.Signer
+}
+
+func (s *LinkTestSuite) SetupTest() {
+	signer, err := link.New("https://frontend.com/", link.Config{
+		TTL:  time.Hour,
+		Keys: []link.KeyConfig{{ID: "k1", Algorithm: link.HS256, Secret: secret}},
+	})
+	s.Require().Nil(err)
+	s.Signer = signer
+}
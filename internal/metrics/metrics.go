@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/environment"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// Backends processing the emails, used as backend attribute
+const (
+	HTTP  = "http"
+	Asynq = "asynq"
+	Kafka = "kafka"
+	NATS  = "nats"
+)
+
+// Metrics instruments of the emails processed by a backend. Methods of nil
+// metrics are no-ops, so that instrumented code works without a meter.
+type Metrics struct {
+	meter   metric.Meter
+	backend attribute.KeyValue
+	// provider of the configured mailer
+	provider attribute.KeyValue
+
+	sent           syncint64.Counter
+	failed         syncint64.Counter
+	skipped        syncint64.Counter
+	sendDuration   syncfloat64.Histogram
+	renderDuration syncfloat64.Histogram
+	queueLag       asyncint64.Gauge
+}
+
+type contextKey struct{}
+
+// New returns the instruments of the backend from the meter, nil if the
+// meter is nil
+func New(meter metric.Meter, backend string) (*Metrics, error) {
+	if meter == nil {
+		return nil, nil
+	}
+	m := &Metrics{meter: meter, backend: attribute.String("backend", backend), provider: attribute.String("provider", "")}
+	if env := environment.Get(); env != nil {
+		m.provider = attribute.String("provider", env.Provider)
+	}
+
+	var err error
+	if m.sent, err = meter.SyncInt64().Counter("email.sent",
+		instrument.WithDescription("Emails accepted by the provider")); err != nil {
+		return nil, err
+	}
+	if m.failed, err = meter.SyncInt64().Counter("email.failed",
+		instrument.WithDescription("Emails not sent because of invalid requests, rendering or provider errors")); err != nil {
+		return nil, err
+	}
+	if m.skipped, err = meter.SyncInt64().Counter("email.skipped",
+		instrument.WithDescription("Emails not sent because recipients opted out or are undeliverable")); err != nil {
+		return nil, err
+	}
+	if m.sendDuration, err = meter.SyncFloat64().Histogram("email.send.duration",
+		instrument.WithDescription("Latency of the provider sending an email or a batch"), instrument.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, err
+	}
+	if m.renderDuration, err = meter.SyncFloat64().Histogram("email.render.duration",
+		instrument.WithDescription("Duration of rendering an email template"), instrument.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, err
+	}
+	if m.queueLag, err = meter.AsyncInt64().Gauge("email.queue.lag",
+		instrument.WithDescription("Messages waiting in the queue of the backend")); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewContext returns a copy of the context carrying the metrics
+func NewContext(ctx context.Context, m *Metrics) context.Context {
+	if m == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, m)
+}
+
+// FromContext returns the metrics of the context, nil if none
+func FromContext(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(contextKey{}).(*Metrics)
+	return m
+}
+
+// Sent records emails of the type accepted by the provider
+func (m *Metrics) Sent(ctx context.Context, taskType string, n int) {
+	if m == nil || n <= 0 {
+		return
+	}
+	m.sent.Add(ctx, int64(n), attribute.String("type", taskType), m.provider, m.backend)
+}
+
+// SendDuration records the latency of the provider sending an email, or a
+// batch, of the type, whatever the outcome
+func (m *Metrics) SendDuration(ctx context.Context, taskType string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.sendDuration.Record(ctx, milliseconds(d), attribute.String("type", taskType), m.provider, m.backend)
+}
+
+// Failed records emails of the type that have not been sent
+func (m *Metrics) Failed(ctx context.Context, taskType string, n int) {
+	if m == nil || n <= 0 {
+		return
+	}
+	m.failed.Add(ctx, int64(n), attribute.String("type", taskType), m.provider, m.backend)
+}
+
+// Skipped records emails of the type intentionally not sent
+func (m *Metrics) Skipped(ctx context.Context, taskType string) {
+	if m == nil {
+		return
+	}
+	m.skipped.Add(ctx, 1, attribute.String("type", taskType), m.provider, m.backend)
+}
+
+// Rendered records the render duration of an email of the type
+func (m *Metrics) Rendered(ctx context.Context, taskType string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.renderDuration.Record(ctx, milliseconds(d), attribute.String("type", taskType), m.backend)
+}
+
+// ObserveQueue reports the messages waiting in the queue of the backend
+// through the lag function, called on each collection. Negative lags, e.g.
+// not yet known, are not reported.
+func (m *Metrics) ObserveQueue(lag func(context.Context) (int64, error)) error {
+	if m == nil {
+		return nil
+	}
+	return m.meter.RegisterCallback([]instrument.Asynchronous{m.queueLag}, func(ctx context.Context) {
+		if n, err := lag(ctx); err == nil && n >= 0 {
+			m.queueLag.Observe(ctx, n, m.backend)
+		}
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
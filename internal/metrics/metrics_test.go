@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func (s *MetricsTestSuite) attrs(taskType string) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("type", taskType), attribute.String("backend", metrics.Kafka)}
+}
+
+func (s *MetricsTestSuite) TestNilMeter() {
+	m, err := metrics.New(nil, metrics.HTTP)
+	s.Nil(err)
+	s.Nil(m)
+
+	// methods of nil metrics are no-ops
+	ctx := context.Background()
+	m.Sent(ctx, "email:welcome", 1)
+	m.Failed(ctx, "email:welcome", 1)
+	m.Skipped(ctx, "email:welcome")
+	m.SendDuration(ctx, "email:welcome", time.Second)
+	m.Rendered(ctx, "email:welcome", time.Second)
+	s.Nil(m.ObserveQueue(func(context.Context) (int64, error) { return 0, nil }))
+	s.Nil(metrics.FromContext(metrics.NewContext(ctx, m)))
+}
+
+func (s *MetricsTestSuite) TestContext() {
+	ctx := context.Background()
+	s.Nil(metrics.FromContext(ctx))
+	s.Equal(s.Metrics, metrics.FromContext(metrics.NewContext(ctx, s.Metrics)))
+}
+
+func (s *MetricsTestSuite) TestCounters() {
+	ctx := context.Background()
+	s.Metrics.Sent(ctx, "email:welcome", 1)
+	s.Metrics.Sent(ctx, "email:batch", 3)
+	s.Metrics.Failed(ctx, "email:batch", 2)
+	s.Metrics.Failed(ctx, "email:batch", 0)
+	s.Metrics.Skipped(ctx, "email:welcome")
+	s.Nil(s.Exporter.Collect(ctx))
+
+	record, err := s.Exporter.GetByNameAndAttributes("email.sent", s.attrs("email:welcome"))
+	s.Nil(err)
+	s.Equal(int64(1), record.Sum.AsInt64())
+	record, err = s.Exporter.GetByNameAndAttributes("email.sent", s.attrs("email:batch"))
+	s.Nil(err)
+	s.Equal(int64(3), record.Sum.AsInt64())
+	s.Contains(record.Attributes, attribute.String("provider", ""))
+
+	record, err = s.Exporter.GetByNameAndAttributes("email.failed", s.attrs("email:batch"))
+	s.Nil(err)
+	s.Equal(int64(2), record.Sum.AsInt64())
+	_, err = s.Exporter.GetByNameAndAttributes("email.failed", s.attrs("email:welcome"))
+	s.NotNil(err)
+
+	record, err = s.Exporter.GetByNameAndAttributes("email.skipped", s.attrs("email:welcome"))
+	s.Nil(err)
+	s.Equal(int64(1), record.Sum.AsInt64())
+}
+
+func (s *MetricsTestSuite) TestDurations() {
+	ctx := context.Background()
+	s.Metrics.SendDuration(ctx, "email:welcome", 20*time.Millisecond)
+	s.Metrics.SendDuration(ctx, "email:welcome", 30*time.Millisecond)
+	s.Metrics.Rendered(ctx, "email:welcome", 1500*time.Microsecond)
+	s.Nil(s.Exporter.Collect(ctx))
+
+	record, err := s.Exporter.GetByNameAndAttributes("email.send.duration", s.attrs("email:welcome"))
+	s.Nil(err)
+	s.Equal(uint64(2), record.Count)
+	s.Equal(float64(50), record.Sum.AsFloat64())
+
+	record, err = s.Exporter.GetByNameAndAttributes("email.render.duration", s.attrs("email:welcome"))
+	s.Nil(err)
+	s.Equal(uint64(1), record.Count)
+	s.Equal(1.5, record.Sum.AsFloat64())
+}
+
+func (s *MetricsTestSuite) TestObserveQueue() {
+	ctx := context.Background()
+	lag, err := int64(-1), error(nil)
+	s.Nil(s.Metrics.ObserveQueue(func(context.Context) (int64, error) { return lag, err }))
+
+	// unknown lags are not reported
+	s.Nil(s.Exporter.Collect(ctx))
+	_, e := s.Exporter.GetByName("email.queue.lag")
+	s.NotNil(e)
+
+	lag = 12
+	s.Nil(s.Exporter.Collect(ctx))
+	record, e := s.Exporter.GetByNameAndAttributes("email.queue.lag", []attribute.KeyValue{attribute.String("backend", metrics.Kafka)})
+	s.Nil(e)
+	s.Equal(int64(12), record.LastValue.AsInt64())
+
+	lag, err = 3, errors.New("unavailable")
+	s.Nil(s.Exporter.Collect(ctx))
+	_, e = s.Exporter.GetByName("email.queue.lag")
+	s.NotNil(e)
+}
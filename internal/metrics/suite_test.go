@@ -0,0 +1,28 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"go.opentelemetry.io/otel/sdk/metric/metrictest"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+type MetricsTestSuite struct {
+	suite.Suite
+
+	Metrics  *metrics.Metrics
+	Exporter *metrictest.Exporter
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	provider, exporter := metrictest.NewTestMeterProvider()
+	m, err := metrics.New(provider.Meter("test"), metrics.Kafka)
+	s.Require().Nil(err)
+	s.Metrics = m
+	s.Exporter = exporter
+}
@@ -0,0 +1,40 @@
+package preference
+
+import "errors"
+
+// Category groups email types a recipient can express preferences on
+type Category string
+
+// List of email categories.
+const (
+	Security      Category = "security"
+	Transactional Category = "transactional"
+	Product       Category = "product"
+	Marketing     Category = "marketing"
+)
+
+var (
+	errUnknownCategory   = errors.New("unknown email category")
+	errMandatoryCategory = errors.New("cannot opt out of mandatory email category")
+)
+
+// Categories returns the list of all the available categories
+func Categories() []Category {
+	return []Category{Security, Transactional, Product, Marketing}
+}
+
+// Mandatory returns true if emails belonging to the category are always sent,
+// regardless of recipient preferences
+func (c Category) Mandatory() bool {
+	return c == Security || c == Transactional
+}
+
+// Validate returns an error if the category is unknown
+func (c Category) Validate() error {
+	for _, category := range Categories() {
+		if c == category {
+			return nil
+		}
+	}
+	return errUnknownCategory
+}
@@ -0,0 +1,133 @@
+package preference
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// PreferencesBody request body to update recipient preferences
+type PreferencesBody struct {
+	OptOut []Category `json:"opt_out"`
+}
+
+// getPreferences godoc
+// @ID get-preferences
+//
+// @Router /preferences/{recipient} [get]
+// @Summary Get preferences
+// @Description Get email categories the recipient opted out from
+// @Tags preferences
+//
+// @Produce  json
+//
+// @Param recipient path string true "recipient email address"
+//
+// @Success 200 {object} Preferences
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func GetHandler(s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		recipient, err := recipientParam(c)
+		if err != nil {
+			return err
+		}
+		p, err := s.Get(c.Request().Context(), recipient)
+		if err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusOK, p)
+	}
+}
+
+// putPreferences godoc
+// @ID put-preferences
+//
+// @Router /preferences/{recipient} [put]
+// @Summary Set preferences
+// @Description Create or replace email categories the recipient opted out from
+// @Tags preferences
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param recipient path string true "recipient email address"
+// @Param preferences body PreferencesBody true "recipient preferences"
+//
+// @Success 200 {object} Preferences
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 500 {string} string
+func PutHandler(s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		recipient, err := recipientParam(c)
+		if err != nil {
+			return err
+		}
+		b := new(PreferencesBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		p := &Preferences{Recipient: recipient, OptOut: b.OptOut}
+		if err := s.Set(c.Request().Context(), p); err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusOK, p)
+	}
+}
+
+// deletePreferences godoc
+// @ID delete-preferences
+//
+// @Router /preferences/{recipient} [delete]
+// @Summary Delete preferences
+// @Description Delete recipient preferences, restoring all categories
+// @Tags preferences
+//
+// @Param recipient path string true "recipient email address"
+//
+// @Success 204
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func DeleteHandler(s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		recipient, err := recipientParam(c)
+		if err != nil {
+			return err
+		}
+		if err := s.Delete(c.Request().Context(), recipient); err != nil {
+			return httpError(err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// recipientParam returns the address of the recipient path param, 400 if it
+// is not a single valid address
+func recipientParam(c echo.Context) (string, error) {
+	address, err := validator.ParseAddress(c.Param("recipient"))
+	if err != nil {
+		var errs validator.FieldErrors
+		errs.Add("recipient", err)
+		return "", echo.NewHTTPError(http.StatusBadRequest, errs)
+	}
+	return address.Address, nil
+}
+
+func httpError(err error) error {
+	switch {
+	case errors.Is(err, errorx.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, errUnknownCategory), errors.Is(err, errMandatoryCategory):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package preference_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+)
+
+func (s *PreferenceTestSuite) TestInvalidRecipient() {
+	e := echo.New()
+	serve := func(handler echo.HandlerFunc, recipient string) error {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		c.SetParamNames("recipient")
+		c.SetParamValues(recipient)
+		return handler(c)
+	}
+
+	for _, recipient := range []string{"invalid", "a@test.com, b@test.com", ""} {
+		for _, handler := range []echo.HandlerFunc{preference.GetHandler(s.Store), preference.PutHandler(s.Store), preference.DeleteHandler(s.Store)} {
+			err := serve(handler, recipient)
+			s.Require().NotNil(err)
+			s.Equal(http.StatusBadRequest, err.(*echo.HTTPError).Code)
+		}
+	}
+
+	err := serve(preference.GetHandler(s.Store), "user@test.com")
+	s.Require().NotNil(err)
+	s.Equal(http.StatusNotFound, err.(*echo.HTTPError).Code)
+}
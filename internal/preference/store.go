@@ -0,0 +1,115 @@
+package preference
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// Preferences describes the email categories a recipient opted out from
+type Preferences struct {
+	Recipient string     `json:"recipient"`
+	OptOut    []Category `json:"opt_out"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Allows returns true if the recipient accepts emails belonging to the category
+func (p *Preferences) Allows(c Category) bool {
+	if p == nil || c.Mandatory() {
+		return true
+	}
+	for _, category := range p.OptOut {
+		if category == c {
+			return false
+		}
+	}
+	return true
+}
+
+// Store interface exports methods to persist recipient preferences
+type Store interface {
+	Get(context.Context, string) (*Preferences, error)
+	Set(context.Context, *Preferences) error
+	Delete(context.Context, string) error
+}
+
+var store Store
+
+// Set assign the shared global preferences store
+func Set(s Store) {
+	store = s
+}
+
+// Get returns the shared global preferences store
+func Get() Store {
+	return store
+}
+
+// Normalize returns the key used to index recipient preferences
+func Normalize(recipient string) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+// MemoryStore in memory implementation of Store
+type MemoryStore struct {
+	lock        *sync.RWMutex
+	preferences map[string]Preferences
+}
+
+// NewMemoryStore returns a new in memory preferences store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lock:        new(sync.RWMutex),
+		preferences: make(map[string]Preferences),
+	}
+}
+
+// Get returns preferences of the recipient, errorx.ErrNotFound if not stored
+func (s *MemoryStore) Get(ctx context.Context, recipient string) (*Preferences, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	p, ok := s.preferences[Normalize(recipient)]
+	if !ok {
+		return nil, errorx.ErrNotFound
+	}
+	p.OptOut = append([]Category(nil), p.OptOut...)
+	return &p, nil
+}
+
+// Set creates or replaces recipient preferences
+func (s *MemoryStore) Set(ctx context.Context, p *Preferences) error {
+	for _, category := range p.OptOut {
+		if err := category.Validate(); err != nil {
+			return err
+		}
+		if category.Mandatory() {
+			return errMandatoryCategory
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p.Recipient = Normalize(p.Recipient)
+	p.UpdatedAt = time.Now().UTC()
+	s.preferences[p.Recipient] = Preferences{
+		Recipient: p.Recipient,
+		OptOut:    append([]Category(nil), p.OptOut...),
+		UpdatedAt: p.UpdatedAt,
+	}
+	return nil
+}
+
+// Delete removes recipient preferences, errorx.ErrNotFound if not stored
+func (s *MemoryStore) Delete(ctx context.Context, recipient string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := Normalize(recipient)
+	if _, ok := s.preferences[key]; !ok {
+		return errorx.ErrNotFound
+	}
+	delete(s.preferences, key)
+	return nil
+}
@@ -0,0 +1,44 @@
+package preference_test
+
+import (
+	"context"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+)
+
+func (s *PreferenceTestSuite) TestSetGet() {
+	ctx := context.Background()
+	err := s.Store.Set(ctx, &preference.Preferences{Recipient: " User@Test.com", OptOut: []preference.Category{preference.Product}})
+	s.Nil(err)
+
+	p, err := s.Store.Get(ctx, "user@test.com")
+	s.Nil(err)
+	s.Equal("user@test.com", p.Recipient)
+	s.False(p.Allows(preference.Product))
+	s.True(p.Allows(preference.Marketing))
+	s.True(p.Allows(preference.Security))
+}
+
+func (s *PreferenceTestSuite) TestSetMandatory() {
+	err := s.Store.Set(context.Background(), &preference.Preferences{Recipient: "user@test.com", OptOut: []preference.Category{preference.Security}})
+	s.NotNil(err)
+
+	err = s.Store.Set(context.Background(), &preference.Preferences{Recipient: "user@test.com", OptOut: []preference.Category{"unknown"}})
+	s.NotNil(err)
+}
+
+func (s *PreferenceTestSuite) TestDelete() {
+	ctx := context.Background()
+	s.ErrorIs(s.Store.Delete(ctx, "user@test.com"), errorx.ErrNotFound)
+
+	s.Nil(s.Store.Set(ctx, &preference.Preferences{Recipient: "user@test.com"}))
+	s.Nil(s.Store.Delete(ctx, "user@test.com"))
+	_, err := s.Store.Get(ctx, "user@test.com")
+	s.ErrorIs(err, errorx.ErrNotFound)
+}
+
+func (s *PreferenceTestSuite) TestNilPreferences() {
+	var p *preference.Preferences
+	s.True(p.Allows(preference.Marketing))
+}
@@ -0,0 +1,22 @@
+package preference_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/preference"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(PreferenceTestSuite))
+}
+
+type PreferenceTestSuite struct {
+	suite.Suite
+
+	Store *preference.MemoryStore
+}
+
+func (s *PreferenceTestSuite) SetupTest() {
+	s.Store = preference.NewMemoryStore()
+}
@@ -0,0 +1,11 @@
+package mailgun
+
+import (
+	"github.com/mailgun/mailgun-go/v4"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+// ModelToEmail exports modelToEmail to tests
+func (m *MailgunClient) ModelToEmail(email model.Email, to ...string) (*mailgun.Message, error) {
+	return m.modelToEmail(email, to...)
+}
@@ -1,9 +1,16 @@
 package mailgun
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"strings"
 
 	"github.com/mailgun/mailgun-go/v4"
+	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/pkg/model"
 )
 
@@ -16,22 +23,96 @@ func NewClient(domain, apiKey string) *MailgunClient {
 	return &MailgunClient{client: c}
 }
 
-func (m *MailgunClient) modelToEmail(email model.Email) *mailgun.Message {
-	msg := m.client.NewMessage(email.From, email.Subject, email.TextBody, email.To)
+func (m *MailgunClient) modelToEmail(email model.Email, to ...string) (*mailgun.Message, error) {
+	msg := m.client.NewMessage(email.From, email.Subject, email.TextBody, to...)
 	msg.SetHtml(email.HtmlBody)
-	return msg
+	if email.ReplyTo != "" {
+		msg.SetReplyTo(email.ReplyTo)
+	}
+	for _, a := range email.Attachments {
+		content, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", model.ErrInvalidAttachment, a.Name)
+		}
+		// Mailgun references inline attachments by file name
+		if a.ContentID != "" {
+			msg.AddReaderInline(a.ContentID, io.NopCloser(bytes.NewReader(content)))
+		} else {
+			msg.AddBufferAttachment(a.Name, content)
+		}
+	}
+	for _, h := range email.Headers {
+		msg.AddHeader(h.Name, h.Value)
+	}
+	return msg, nil
 }
 
 func (m *MailgunClient) Send(ctx context.Context, email model.Email) error {
-	_, _, err := m.client.Send(ctx, m.modelToEmail(email))
+	msg, err := m.modelToEmail(email, email.To)
+	if err != nil {
+		return err
+	}
+	_, _, err = m.client.Send(ctx, msg)
 	return err
 }
 
-func (m *MailgunClient) SendBatch(ctx context.Context, email model.Email, recipients []string) error {
-	msg := m.client.NewMessage(email.From, email.Subject, email.TextBody)
+// SendBatch sends the email as a batch message, in chunks of
+// mailgun.MaxNumberOfRecipients, replacing placeholders with recipient
+// variables. Recipient variables are always set, so that Mailgun delivers an
+// individual email to each recipient.
+func (m *MailgunClient) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	results := make([]model.SendResult, 0, len(recipients))
+	for _, chunk := range provider.Chunk(recipients, mailgun.MaxNumberOfRecipients) {
+		msg, err := m.modelToEmail(recipientVariables(email, chunk))
+		if err != nil {
+			return nil, err
+		}
+		for _, recipient := range chunk {
+			vars := make(map[string]interface{}, 2*len(recipient.Params))
+			for key, value := range recipient.Params {
+				vars[key] = value
+				vars[model.HTMLKey(key)] = html.EscapeString(value)
+			}
+			if err = msg.AddRecipientAndVariables(recipient.Address(), vars); err != nil {
+				break
+			}
+		}
+
+		var id string
+		if err == nil {
+			_, id, err = m.client.Send(ctx, msg)
+		}
+		for _, recipient := range chunk {
+			result := model.SendResult{To: recipient.To, MessageID: id}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// recipientVariables replaces model placeholders with the corresponding
+// Mailgun recipient variables, HTML escaped ones in the HTML body
+func recipientVariables(email model.Email, recipients []model.Recipient) model.Email {
+	var keys, pairs []string
+	seen := make(map[string]bool)
 	for _, recipient := range recipients {
-		msg.AddRecipient(recipient)
+		for key := range recipient.Params {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+				pairs = append(pairs,
+					model.Placeholder(key), fmt.Sprintf("%%recipient.%s%%", key),
+					model.Placeholder(model.HTMLKey(key)), fmt.Sprintf("%%recipient.%s%%", model.HTMLKey(key)))
+			}
+		}
 	}
-	_, _, err := m.client.Send(ctx, m.modelToEmail(email))
-	return err
+	email = model.HTMLPlaceholders(email, keys)
+	replacer := strings.NewReplacer(pairs...)
+	email.Subject = replacer.Replace(email.Subject)
+	email.HtmlBody = replacer.Replace(email.HtmlBody)
+	email.TextBody = replacer.Replace(email.TextBody)
+	return email
 }
@@ -0,0 +1,17 @@
+package mailgun_test
+
+import (
+	"github.com/xn3cr0nx/email-service/internal/provider/mailgun"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *MailgunTestSuite) TestModelToEmail() {
+	client := mailgun.NewClient("test.com", "key")
+	message, err := client.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", ReplyTo: "Support <support@test.com>", Subject: "Welcome"}, "user@test.com")
+	s.Require().Nil(err)
+	s.Equal("Support <support@test.com>", message.GetHeaders()["Reply-To"])
+
+	message, err = client.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", Subject: "Welcome"}, "user@test.com")
+	s.Require().Nil(err)
+	s.NotContains(message.GetHeaders(), "Reply-To")
+}
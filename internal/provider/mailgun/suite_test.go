@@ -0,0 +1,15 @@
+package mailgun_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(MailgunTestSuite))
+}
+
+type MailgunTestSuite struct {
+	suite.Suite
+}
@@ -0,0 +1,4 @@
+package postmark
+
+// ModelToEmail exports modelToEmail to tests
+var ModelToEmail = modelToEmail
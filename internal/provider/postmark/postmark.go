@@ -4,9 +4,13 @@ import (
 	"context"
 
 	client "github.com/keighl/postmark"
+	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/pkg/model"
 )
 
+// MaxBatchSize is the maximum number of messages Postmark accepts in a single batch call
+const MaxBatchSize = 500
+
 type PostmarkClient struct {
 	*client.Client
 }
@@ -24,27 +28,63 @@ func (p *PostmarkClient) Send(ctx context.Context, email model.Email) error {
 	return nil
 }
 
-func (p *PostmarkClient) SendBatch(ctx context.Context, email model.Email, recipients []string) error {
-	clientEmails := make([]client.Email, len(recipients))
-	for i, recipient := range recipients {
-		model := modelToEmail(email)
-		model.To = recipient
-		clientEmails[i] = model
-	}
-	_, err := p.SendEmailBatch(clientEmails)
-	if err != nil {
-		return err
+// SendBatch renders the email for each recipient and sends them through the
+// batch API, in chunks of MaxBatchSize messages
+func (p *PostmarkClient) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	results := make([]model.SendResult, 0, len(recipients))
+	for _, chunk := range provider.Chunk(recipients, MaxBatchSize) {
+		if err := ctx.Err(); err != nil {
+			results = append(results, provider.Failed(chunk, err)...)
+			continue
+		}
+
+		clientEmails := make([]client.Email, len(chunk))
+		for i, recipient := range chunk {
+			clientEmails[i] = modelToEmail(model.Personalize(email, recipient))
+		}
+		res, err := p.SendEmailBatch(clientEmails)
+		if err != nil {
+			results = append(results, provider.Failed(chunk, err)...)
+			continue
+		}
+		// responses are returned in the same order of the submitted messages
+		for i, recipient := range chunk {
+			result := model.SendResult{To: recipient.To}
+			if i >= len(res) {
+				result.Error = "missing response for message"
+			} else if res[i].ErrorCode != 0 {
+				result.Error = res[i].Message
+			} else {
+				result.MessageID = res[i].MessageID
+			}
+			results = append(results, result)
+		}
 	}
-	return nil
+	return results, nil
 }
 
 func modelToEmail(email model.Email) client.Email {
+	attachments := make([]client.Attachment, len(email.Attachments))
+	for i, a := range email.Attachments {
+		contentID := a.ContentID
+		if contentID != "" {
+			contentID = "cid:" + contentID
+		}
+		attachments[i] = client.Attachment{Name: a.Name, Content: a.Content, ContentType: a.ContentType, ContentID: contentID}
+	}
+	headers := make([]client.Header, len(email.Headers))
+	for i, h := range email.Headers {
+		headers[i] = client.Header{Name: h.Name, Value: h.Value}
+	}
 	return client.Email{
-		From:     email.From,
-		To:       email.To,
-		Subject:  email.Subject,
-		HtmlBody: email.HtmlBody,
-		TextBody: email.TextBody,
-		Tag:      email.Tag,
+		From:        email.From,
+		To:          email.To,
+		ReplyTo:     email.ReplyTo,
+		Subject:     email.Subject,
+		HtmlBody:    email.HtmlBody,
+		TextBody:    email.TextBody,
+		Tag:         email.Tag,
+		Headers:     headers,
+		Attachments: attachments,
 	}
 }
@@ -0,0 +1,16 @@
+package postmark_test
+
+import (
+	"github.com/xn3cr0nx/email-service/internal/provider/postmark"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *PostmarkTestSuite) TestModelToEmail() {
+	email := postmark.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", ReplyTo: "Support <support@test.com>", Subject: "Welcome"})
+	s.Equal("noreply@test.com", email.From)
+	s.Equal("user@test.com", email.To)
+	s.Equal("Support <support@test.com>", email.ReplyTo)
+
+	email = postmark.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", Subject: "Welcome"})
+	s.Empty(email.ReplyTo)
+}
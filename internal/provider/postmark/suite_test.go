@@ -0,0 +1,15 @@
+package postmark_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(PostmarkTestSuite))
+}
+
+type PostmarkTestSuite struct {
+	suite.Suite
+}
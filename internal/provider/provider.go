@@ -6,7 +6,45 @@ import (
 	"github.com/xn3cr0nx/email-service/pkg/model"
 )
 
+// maxRecipients maximum number of recipients of a single email accepted by the providers
+var maxRecipients = map[string]int{
+	"postmark": 50,
+	"sendgrid": 1000,
+	"mailgun":  1000,
+	"smtp":     100,
+}
+
+// MaxRecipients returns the maximum number of recipients of a single email
+// accepted by the provider, zero if unknown
+func MaxRecipients(name string) int {
+	return maxRecipients[name]
+}
+
 type Mailer interface {
 	Send(context.Context, model.Email) error
-	SendBatch(context.Context, model.Email, []string) error
+	// SendBatch personalizes the email for each recipient, replacing model
+	// placeholders with recipient params, relying on the provider native batch
+	// API. Returns the outcome for each recipient.
+	SendBatch(context.Context, model.Email, []model.Recipient) ([]model.SendResult, error)
+}
+
+// Chunk splits the list of recipients in chunks of at most size elements
+func Chunk(recipients []model.Recipient, size int) [][]model.Recipient {
+	var chunks [][]model.Recipient
+	for size < len(recipients) {
+		recipients, chunks = recipients[size:], append(chunks, recipients[0:size:size])
+	}
+	if len(recipients) > 0 {
+		chunks = append(chunks, recipients)
+	}
+	return chunks
+}
+
+// Failed returns results reporting the error for each recipient
+func Failed(recipients []model.Recipient, err error) []model.SendResult {
+	results := make([]model.SendResult, len(recipients))
+	for i, recipient := range recipients {
+		results[i] = model.SendResult{To: recipient.To, Error: err.Error()}
+	}
+	return results
 }
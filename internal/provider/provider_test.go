@@ -0,0 +1,24 @@
+package provider_test
+
+import (
+	"errors"
+
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *ProviderTestSuite) TestChunk() {
+	recipients := make([]model.Recipient, 7)
+	chunks := provider.Chunk(recipients, 3)
+	s.Len(chunks, 3)
+	s.Len(chunks[0], 3)
+	s.Len(chunks[2], 1)
+
+	s.Len(provider.Chunk(recipients, 7), 1)
+	s.Len(provider.Chunk(nil, 3), 0)
+}
+
+func (s *ProviderTestSuite) TestFailed() {
+	results := provider.Failed([]model.Recipient{{To: "a@test.com"}}, errors.New("failure"))
+	s.Equal([]model.SendResult{{To: "a@test.com", Error: "failure"}}, results)
+}
@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited returned when the sending budget is exhausted and the request
+// cannot wait for it
+var ErrRateLimited = errors.New("sending rate limit exceeded")
+
+// RateLimitError reports after how long the sending budget of a rejected
+// request is available
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+type noWaitKey struct{}
+
+// NoWait marks the context of requests that must not wait for the sending
+// budget, e.g. HTTP requests, so that they are rejected with RateLimitError
+func NoWait(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noWaitKey{}, true)
+}
+
+func noWait(ctx context.Context) bool {
+	v, _ := ctx.Value(noWaitKey{}).(bool)
+	return v
+}
+
+// Throttler is implemented by mailers able to signal an exhausted sending
+// budget, so that consumers can pause fetching messages instead of failing
+type Throttler interface {
+	// Wait blocks until the mailer has budget to send an email
+	Wait(context.Context) error
+}
+
+// Limit token bucket configuration. Rate is expressed in emails per second,
+// zero means unlimited. Burst defaults to the rate rounded up.
+type Limit struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// RateLimitConfig configures the budget of the provider and of specific
+// recipient domains
+type RateLimitConfig struct {
+	Provider Limit
+	Domains  map[string]Limit
+}
+
+// RateLimitedMailer wraps a Mailer, waiting for provider and recipient domain
+// budget before sending each email, or rejecting it if the context is marked
+// with NoWait
+type RateLimitedMailer struct {
+	Mailer
+	limiter *rate.Limiter
+	domains map[string]*rate.Limiter
+}
+
+// NewRateLimitedMailer wraps the mailer with token bucket rate limiters
+func NewRateLimitedMailer(m Mailer, conf *RateLimitConfig) *RateLimitedMailer {
+	domains := make(map[string]*rate.Limiter, len(conf.Domains))
+	for domain, limit := range conf.Domains {
+		domains[strings.ToLower(domain)] = newLimiter(limit)
+	}
+	return &RateLimitedMailer{
+		Mailer:  m,
+		limiter: newLimiter(conf.Provider),
+		domains: domains,
+	}
+}
+
+func newLimiter(limit Limit) *rate.Limiter {
+	if limit.Rate <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(limit.Rate))
+	}
+	return rate.NewLimiter(rate.Limit(limit.Rate), burst)
+}
+
+// Wait blocks until the provider budget allows to send an email, without
+// consuming it
+func (r *RateLimitedMailer) Wait(ctx context.Context) error {
+	// cancelling at the same instant of the reservation restores the tokens
+	now := time.Now()
+	reservation := r.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *RateLimitedMailer) Send(ctx context.Context, email model.Email) error {
+	if err := r.wait(ctx, email.To); err != nil {
+		return err
+	}
+	return r.Mailer.Send(ctx, email)
+}
+
+// SendBatch reserves the budget of every recipient before sending the batch,
+// failing the whole batch if the context is done before the budget is
+// available
+func (r *RateLimitedMailer) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	to := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		to[i] = recipient.To
+	}
+	if err := r.wait(ctx, to...); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			return nil, err
+		}
+		return Failed(recipients, err), nil
+	}
+	return r.Mailer.SendBatch(ctx, email, recipients)
+}
+
+// wait reserves together the provider budget and the budget of each limited
+// domain of the emails, each addressed to comma separated recipients, waiting
+// until all of them are available. The reservations are cancelled if the
+// context is done first, or returned as RateLimitError without waiting if the
+// context is marked with NoWait.
+func (r *RateLimitedMailer) wait(ctx context.Context, to ...string) error {
+	now := time.Now()
+	var reservations []*rate.Reservation
+	var delay time.Duration
+	reserve := func(limiter *rate.Limiter) {
+		reservation := limiter.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if d := reservation.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	// cancelling in reverse order restores every reserved token
+	cancel := func() {
+		for i := len(reservations) - 1; i >= 0; i-- {
+			reservations[i].CancelAt(now)
+		}
+	}
+	for _, recipients := range to {
+		reserve(r.limiter)
+		for _, domain := range domains(recipients) {
+			if limiter, ok := r.domains[domain]; ok {
+				reserve(limiter)
+			}
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	if noWait(ctx) {
+		cancel()
+		return &RateLimitError{RetryAfter: delay}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
+
+// domains returns the lower case domains of the comma separated recipients
+func domains(to string) []string {
+	addresses, err := mail.ParseAddressList(to)
+	if err != nil {
+		return nil
+	}
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if at := strings.LastIndex(address.Address, "@"); at != -1 {
+			result = append(result, strings.ToLower(address.Address[at+1:]))
+		}
+	}
+	return result
+}
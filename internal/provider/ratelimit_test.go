@@ -0,0 +1,107 @@
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+type mockMailer struct {
+	sent []string
+}
+
+func (m *mockMailer) Send(ctx context.Context, email model.Email) error {
+	m.sent = append(m.sent, email.To)
+	return nil
+}
+
+func (m *mockMailer) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	results := make([]model.SendResult, len(recipients))
+	for i, recipient := range recipients {
+		m.sent = append(m.sent, recipient.To)
+		results[i] = model.SendResult{To: recipient.To}
+	}
+	return results, nil
+}
+
+func (s *ProviderTestSuite) TestRateLimitDomain() {
+	mock := new(mockMailer)
+	mailer := provider.NewRateLimitedMailer(mock, &provider.RateLimitConfig{
+		Domains: map[string]provider.Limit{"Outlook.com": {Rate: 1, Burst: 1}},
+	})
+
+	ctx := context.Background()
+	s.Nil(mailer.Send(ctx, model.Email{To: "a@test.com"}))
+	s.Nil(mailer.Send(ctx, model.Email{To: "User <b@outlook.com>"}))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s.NotNil(mailer.Send(timeout, model.Email{To: "c@outlook.com"}))
+	s.Nil(mailer.Send(timeout, model.Email{To: "d@test.com"}))
+	s.Equal([]string{"a@test.com", "User <b@outlook.com>", "d@test.com"}, mock.sent)
+}
+
+func (s *ProviderTestSuite) TestRateLimitBatch() {
+	mock := new(mockMailer)
+	mailer := provider.NewRateLimitedMailer(mock, &provider.RateLimitConfig{
+		Provider: provider.Limit{Rate: 1, Burst: 2},
+	})
+	recipients := []model.Recipient{{To: "a@test.com"}, {To: "b@test.com"}, {To: "c@test.com"}}
+
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	results, err := mailer.SendBatch(timeout, model.Email{}, recipients)
+	s.Nil(err)
+	s.Len(results, 3)
+	for _, result := range results {
+		s.NotEmpty(result.Error)
+	}
+	s.Empty(mock.sent)
+
+	// the reservations of the failed batch have been cancelled
+	ctx := provider.NoWait(context.Background())
+	results, err = mailer.SendBatch(ctx, model.Email{}, recipients[:2])
+	s.Nil(err)
+	s.Len(results, 2)
+	s.Equal([]string{"a@test.com", "b@test.com"}, mock.sent)
+}
+
+func (s *ProviderTestSuite) TestRateLimitNoWait() {
+	mock := new(mockMailer)
+	mailer := provider.NewRateLimitedMailer(mock, &provider.RateLimitConfig{
+		Provider: provider.Limit{Rate: 1, Burst: 2},
+		Domains:  map[string]provider.Limit{"outlook.com": {Rate: 1, Burst: 1}},
+	})
+
+	ctx := provider.NoWait(context.Background())
+	_, err := mailer.SendBatch(ctx, model.Email{}, []model.Recipient{{To: "a@test.com"}, {To: "b@test.com"}, {To: "c@test.com"}})
+	var limited *provider.RateLimitError
+	s.Require().ErrorAs(err, &limited)
+	s.ErrorIs(err, provider.ErrRateLimited)
+	s.InDelta(time.Second, limited.RetryAfter, float64(100*time.Millisecond))
+	s.Empty(mock.sent)
+
+	s.Nil(mailer.Send(ctx, model.Email{To: "a@outlook.com"}))
+	// the provider budget is not consumed when the domain budget is exhausted
+	s.ErrorIs(mailer.Send(ctx, model.Email{To: "b@outlook.com"}), provider.ErrRateLimited)
+	s.Nil(mailer.Send(ctx, model.Email{To: "c@test.com"}))
+	s.ErrorIs(mailer.Send(ctx, model.Email{To: "d@test.com"}), provider.ErrRateLimited)
+	s.Equal([]string{"a@outlook.com", "c@test.com"}, mock.sent)
+}
+
+func (s *ProviderTestSuite) TestRateLimitWait() {
+	mailer := provider.NewRateLimitedMailer(new(mockMailer), &provider.RateLimitConfig{
+		Provider: provider.Limit{Rate: 1, Burst: 1},
+	})
+
+	ctx := context.Background()
+	// waiting does not consume the budget
+	s.Nil(mailer.Wait(ctx))
+	s.Nil(mailer.Send(ctx, model.Email{To: "a@test.com"}))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s.NotNil(mailer.Wait(timeout))
+}
@@ -0,0 +1,4 @@
+package sendgrid
+
+// ModelToEmail exports modelToEmail to tests
+var ModelToEmail = modelToEmail
@@ -0,0 +1,16 @@
+package sendgrid_test
+
+import (
+	"github.com/xn3cr0nx/email-service/internal/provider/sendgrid"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *SendgridTestSuite) TestModelToEmail() {
+	message := sendgrid.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", ReplyTo: "Support <support@test.com>", Subject: "Welcome"})
+	s.Require().NotNil(message.ReplyTo)
+	s.Equal("Support", message.ReplyTo.Name)
+	s.Equal("support@test.com", message.ReplyTo.Address)
+
+	message = sendgrid.ModelToEmail(model.Email{From: "noreply@test.com", To: "user@test.com", Subject: "Welcome"})
+	s.Nil(message.ReplyTo)
+}
@@ -2,14 +2,19 @@ package sendgrid
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"html"
 
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
-	"github.com/xn3cr0nx/email-service/pkg/logger"
+	"github.com/xn3cr0nx/email-service/internal/provider"
 	"github.com/xn3cr0nx/email-service/pkg/model"
 )
 
+// MaxBatchSize is the maximum number of personalizations SendGrid accepts in a single message
+const MaxBatchSize = 1000
+
 type SendgridClient struct {
 	client *sendgrid.Client
 }
@@ -20,24 +25,98 @@ func NewClient(apiKey string) *SendgridClient {
 }
 
 func (p *SendgridClient) Send(ctx context.Context, email model.Email) error {
-	_, err := p.client.SendWithContext(ctx, modelToEmail(email))
+	_, err := p.send(ctx, modelToEmail(email))
 	return err
 }
 
-func (p *SendgridClient) SendBatch(ctx context.Context, email model.Email, recipients []string) error {
-	for _, recipient := range recipients {
-		email.To = recipient
-		if err := p.Send(ctx, email); err != nil {
-			logger.Error("Mailgun provider", err, logger.Params{})
-			continue
+// SendBatch sends the email as a single message with a personalization for
+// every recipient, in chunks of MaxBatchSize, relying on substitutions to
+// replace placeholders
+func (p *SendgridClient) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	results := make([]model.SendResult, 0, len(recipients))
+	for _, chunk := range provider.Chunk(recipients, MaxBatchSize) {
+		id, err := p.send(ctx, batchToEmail(email, chunk))
+		for _, recipient := range chunk {
+			result := model.SendResult{To: recipient.To, MessageID: id}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
-	return nil
+	return results, nil
+}
+
+// send sends the message returning the message id assigned by SendGrid
+func (p *SendgridClient) send(ctx context.Context, message *mail.SGMailV3) (string, error) {
+	res, err := p.client.SendWithContext(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("sendgrid responded with status %d: %s", res.StatusCode, res.Body)
+	}
+	return messageID(res), nil
+}
+
+func messageID(res *rest.Response) string {
+	if ids := res.Headers["X-Message-Id"]; len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
 }
 
 func modelToEmail(email model.Email) *mail.SGMailV3 {
 	from := mail.NewEmail("From", email.From)
 	to := mail.NewEmail("To", email.To)
-	return mail.NewSingleEmail(from, email.Subject, to, email.TextBody, email.HtmlBody)
+	message := mail.NewSingleEmail(from, email.Subject, to, email.TextBody, email.HtmlBody)
+	if email.ReplyTo != "" {
+		replyTo, err := mail.ParseEmail(email.ReplyTo)
+		if err != nil {
+			// the address has already been validated, it is sent as is
+			replyTo = mail.NewEmail("", email.ReplyTo)
+		}
+		message.SetReplyTo(replyTo)
+	}
+	for _, a := range email.Attachments {
+		attachment := mail.NewAttachment().SetFilename(a.Name).SetContent(a.Content).SetType(a.ContentType)
+		if a.ContentID != "" {
+			attachment.SetDisposition("inline").SetContentID(a.ContentID)
+		} else {
+			attachment.SetDisposition("attachment")
+		}
+		message.AddAttachment(attachment)
+	}
+	for _, h := range email.Headers {
+		message.SetHeader(h.Name, h.Value)
+	}
+	return message
+}
+
+// batchToEmail builds a message adding a personalization for each recipient,
+// so that recipients do not see each other. Substitutions apply to every part
+// of the message, so the HTML body refers to the HTML escaped params.
+func batchToEmail(email model.Email, recipients []model.Recipient) *mail.SGMailV3 {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, recipient := range recipients {
+		for key := range recipient.Params {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	message := modelToEmail(model.HTMLPlaceholders(email, keys))
+	message.Personalizations = nil
+	for _, recipient := range recipients {
+		p := mail.NewPersonalization()
+		p.AddTos(mail.NewEmail(recipient.Name, recipient.To))
+		for key, value := range recipient.Params {
+			p.SetSubstitution(model.Placeholder(key), value)
+			p.SetSubstitution(model.Placeholder(model.HTMLKey(key)), html.EscapeString(value))
+		}
+		message.AddPersonalizations(p)
+	}
+	return message
 }
@@ -0,0 +1,15 @@
+package sendgrid_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(SendgridTestSuite))
+}
+
+type SendgridTestSuite struct {
+	suite.Suite
+}
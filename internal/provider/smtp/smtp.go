@@ -0,0 +1,177 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/pkg/dkim"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+// Config SMTP relay configuration, authentication is used when Username is set
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// SignerLookup returns the DKIM signer of the from address, nil if the
+// message should not be signed
+type SignerLookup func(from string) *dkim.Signer
+
+// SMTPClient delivers emails through an SMTP relay, such as an owned MTA,
+// signing them with the DKIM key of the sender
+type SMTPClient struct {
+	conf   Config
+	signer SignerLookup
+	dialer *net.Dialer
+}
+
+func NewClient(conf Config, signer SignerLookup) *SMTPClient {
+	return &SMTPClient{
+		conf:   conf,
+		signer: signer,
+		dialer: &net.Dialer{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *SMTPClient) Send(ctx context.Context, email model.Email) error {
+	client, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if _, err := c.deliver(client, email); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// SendBatch renders the email for each recipient and delivers them in
+// separate transactions over the same connection
+func (c *SMTPClient) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	client, err := c.connect(ctx)
+	if err != nil {
+		return provider.Failed(recipients, err), nil
+	}
+	defer client.Close()
+
+	results := make([]model.SendResult, len(recipients))
+	for i, recipient := range recipients {
+		results[i].To = recipient.To
+		if err := ctx.Err(); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		id, err := c.deliver(client, model.Personalize(email, recipient))
+		if err != nil {
+			results[i].Error = err.Error()
+			// abort the failed transaction to go on with the next one
+			if err := client.Reset(); err != nil {
+				return results, err
+			}
+			continue
+		}
+		results[i].MessageID = id
+	}
+	return results, client.Quit()
+}
+
+// connect opens a connection to the relay, upgrading it to TLS and
+// authenticating when supported
+func (c *SMTPClient) connect(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(c.conf.Host, strconv.Itoa(c.conf.Port))
+	conn, err := c.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, c.conf.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.conf.Host}); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	if c.conf.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.conf.Username, c.conf.Password, c.conf.Host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// deliver sends the email in a single transaction, returning its message id
+func (c *SMTPClient) deliver(client *smtp.Client, email model.Email) (string, error) {
+	from, err := mail.ParseAddress(email.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid from address: %w", err)
+	}
+	var rcpt []*mail.Address
+	for _, list := range []string{email.To, email.Cc, email.Bcc} {
+		if list == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(list)
+		if err != nil {
+			return "", fmt.Errorf("invalid recipient address: %w", err)
+		}
+		rcpt = append(rcpt, addresses...)
+	}
+
+	// emails can carry their Message-ID, e.g. to attribute tracking events
+	id := email.Header("Message-ID")
+	if id == "" {
+		if id, err = model.NewMessageID(from.Address[strings.LastIndex(from.Address, "@")+1:]); err != nil {
+			return "", err
+		}
+		email.Headers = append([]model.Header{{Name: "Message-ID", Value: id}}, email.Headers...)
+	}
+	data, err := model.BuildMIME(email)
+	if err != nil {
+		return "", err
+	}
+	if c.signer != nil {
+		if signer := c.signer(from.Address); signer != nil {
+			if data, err = signer.Sign(data); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return "", err
+	}
+	for _, address := range rcpt {
+		if err := client.Rcpt(address.Address); err != nil {
+			return "", err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return "", err
+	}
+	return id, w.Close()
+}
@@ -0,0 +1,15 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(ProviderTestSuite))
+}
+
+type ProviderTestSuite struct {
+	suite.Suite
+}
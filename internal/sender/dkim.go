@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/pkg/dkim"
+)
+
+// DKIMConfig DKIM signing configuration of a sender identity. Domain defaults
+// to the domain of the identity address. Multiple keys allow rotation: the
+// key with the most recent not_before in the past is used.
+type DKIMConfig struct {
+	Domain string    `mapstructure:"domain"`
+	Keys   []DKIMKey `mapstructure:"keys"`
+}
+
+// DKIMKey private key file of a DKIM selector, not_before is an RFC 3339 time
+type DKIMKey struct {
+	Selector   string `mapstructure:"selector"`
+	PrivateKey string `mapstructure:"private_key"`
+	NotBefore  string `mapstructure:"not_before"`
+}
+
+// loadSigner loads the keys of the identity DKIM configuration
+func (i *Identity) loadSigner() error {
+	if i.DKIM == nil {
+		return nil
+	}
+	domain := i.DKIM.Domain
+	if domain == "" {
+		domain = i.Address[strings.LastIndex(i.Address, "@")+1:]
+	}
+
+	keys := make([]dkim.Key, len(i.DKIM.Keys))
+	for k, conf := range i.DKIM.Keys {
+		if conf.Selector == "" || conf.PrivateKey == "" {
+			return fmt.Errorf("%w: DKIM key of sender identity %s requires selector and private_key", errorx.ErrConfig, i.ID)
+		}
+		data, err := os.ReadFile(conf.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("%w: cannot read DKIM key %s: %v", errorx.ErrConfig, conf.Selector, err)
+		}
+		signer, err := dkim.ParsePrivateKey(data)
+		if err != nil {
+			return fmt.Errorf("%w: DKIM key %s: %v", errorx.ErrConfig, conf.Selector, err)
+		}
+		keys[k] = dkim.Key{Selector: conf.Selector, Signer: signer}
+		if conf.NotBefore != "" {
+			if keys[k].NotBefore, err = time.Parse(time.RFC3339, conf.NotBefore); err != nil {
+				return fmt.Errorf("%w: DKIM key %s: %v", errorx.ErrConfig, conf.Selector, err)
+			}
+		}
+	}
+
+	signer, err := dkim.NewSigner(domain, keys)
+	if err != nil {
+		return fmt.Errorf("%w: sender identity %s: %v", errorx.ErrConfig, i.ID, err)
+	}
+	i.signer = signer
+	return nil
+}
+
+// Signer returns the DKIM signer of the sender identity with the from address,
+// nil if the identity is unknown or has no DKIM configuration
+func Signer(from string) *dkim.Signer {
+	if registry == nil {
+		return nil
+	}
+	if identity, ok := registry.byAddress[normalize(from)]; ok {
+		return identity.signer
+	}
+	return nil
+}
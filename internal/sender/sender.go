@@ -0,0 +1,142 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/pkg/dkim"
+)
+
+var (
+	// ErrUnknownSender returned when the requested sender is not in the allow-list
+	ErrUnknownSender = errors.New("unknown sender identity")
+	// ErrSenderNotAllowed returned when the sender cannot be used for the email type or by the client
+	ErrSenderNotAllowed = errors.New("sender identity not allowed")
+)
+
+// Identity verified sender address. Empty Types or Clients lists mean the
+// identity can be used for any email type or by any client.
+type Identity struct {
+	ID      string   `json:"id" mapstructure:"id"`
+	Address string   `json:"address" mapstructure:"address"`
+	Name    string   `json:"name,omitempty" mapstructure:"name"`
+	ReplyTo string   `json:"reply_to,omitempty" mapstructure:"reply_to"`
+	Types   []string `json:"types,omitempty" mapstructure:"types"`
+	Clients []string `json:"clients,omitempty" mapstructure:"clients"`
+	// DKIM: signing configuration used when delivering through SMTP
+	DKIM *DKIMConfig `json:"-" mapstructure:"dkim"`
+
+	signer *dkim.Signer
+}
+
+// From returns the identity address, including the display name if present
+func (i *Identity) From() string {
+	if i.Name == "" {
+		return i.Address
+	}
+	return (&mail.Address{Name: i.Name, Address: i.Address}).String()
+}
+
+func (i *Identity) allows(taskType string, client *auth.Client) bool {
+	if len(i.Types) > 0 && !contains(i.Types, taskType) {
+		return false
+	}
+	// unauthenticated requests come from trusted queue backends
+	if len(i.Clients) > 0 && client != nil && !contains(i.Clients, client.Name) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry allow-list of sender identities, indexed by id and address
+type Registry struct {
+	byID      map[string]*Identity
+	byAddress map[string]*Identity
+}
+
+// NewRegistry returns a registry of the identities, checking ids and addresses are unique
+func NewRegistry(identities []Identity) (*Registry, error) {
+	r := &Registry{
+		byID:      make(map[string]*Identity, len(identities)),
+		byAddress: make(map[string]*Identity, len(identities)),
+	}
+	for i := range identities {
+		identity := &identities[i]
+		if identity.ID == "" || identity.Address == "" {
+			return nil, fmt.Errorf("%w: sender identity requires id and address", errorx.ErrConfig)
+		}
+		address := normalize(identity.Address)
+		if _, ok := r.byID[identity.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicated sender identity %s", errorx.ErrConfig, identity.ID)
+		}
+		if _, ok := r.byAddress[address]; ok {
+			return nil, fmt.Errorf("%w: duplicated sender address %s", errorx.ErrConfig, identity.Address)
+		}
+		if err := identity.loadSigner(); err != nil {
+			return nil, err
+		}
+		r.byID[identity.ID], r.byAddress[address] = identity, identity
+	}
+	return r, nil
+}
+
+var registry *Registry
+
+// Set assign the shared global sender registry
+func Set(r *Registry) {
+	registry = r
+}
+
+// Get returns the shared global sender registry
+func Get() *Registry {
+	return registry
+}
+
+// Resolve returns the sender identity referenced by id or, if no id is given,
+// by from address. Without a configured registry any from address is accepted.
+func Resolve(ctx context.Context, taskType, id, from string) (*Identity, error) {
+	if registry == nil {
+		return &Identity{Address: from}, nil
+	}
+	return registry.Resolve(ctx, taskType, id, from)
+}
+
+// Resolve returns the sender identity referenced by id or, if no id is given,
+// by from address, checking it can be used for the email type by the client
+func (r *Registry) Resolve(ctx context.Context, taskType, id, from string) (*Identity, error) {
+	var identity *Identity
+	var ok bool
+	if id != "" {
+		identity, ok = r.byID[id]
+	} else {
+		identity, ok = r.byAddress[normalize(from)]
+	}
+	if !ok {
+		return nil, ErrUnknownSender
+	}
+	if !identity.allows(taskType, auth.FromContext(ctx)) {
+		return nil, ErrSenderNotAllowed
+	}
+	return identity, nil
+}
+
+func normalize(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
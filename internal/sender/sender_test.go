@@ -0,0 +1,45 @@
+package sender_test
+
+import (
+	"context"
+
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/sender"
+)
+
+func (s *SenderTestSuite) TestResolve() {
+	ctx := context.Background()
+	identity, err := s.Registry.Resolve(ctx, "email:welcome", "info", "")
+	s.Nil(err)
+	s.Equal("\"Test\" <info@test.com>", identity.From())
+
+	identity, err = s.Registry.Resolve(ctx, "email:welcome", "", "Someone <INFO@test.com>")
+	s.Nil(err)
+	s.Equal("info", identity.ID)
+
+	_, err = s.Registry.Resolve(ctx, "email:welcome", "", "spoofed@test.com")
+	s.ErrorIs(err, sender.ErrUnknownSender)
+	_, err = s.Registry.Resolve(ctx, "email:welcome", "unknown", "")
+	s.ErrorIs(err, sender.ErrUnknownSender)
+}
+
+func (s *SenderTestSuite) TestResolveRestricted() {
+	ctx := context.Background()
+	_, err := s.Registry.Resolve(ctx, "email:welcome", "security", "")
+	s.ErrorIs(err, sender.ErrSenderNotAllowed)
+
+	_, err = s.Registry.Resolve(ctx, "email:reset", "security", "")
+	s.Nil(err)
+
+	_, err = s.Registry.Resolve(auth.WithClient(ctx, &auth.Client{Name: "billing"}), "email:reset", "security", "")
+	s.ErrorIs(err, sender.ErrSenderNotAllowed)
+	_, err = s.Registry.Resolve(auth.WithClient(ctx, &auth.Client{Name: "auth-service"}), "email:reset", "security", "")
+	s.Nil(err)
+}
+
+func (s *SenderTestSuite) TestNewRegistry() {
+	_, err := sender.NewRegistry([]sender.Identity{{ID: "a", Address: "a@test.com"}, {ID: "b", Address: "A@test.com"}})
+	s.NotNil(err)
+	_, err = sender.NewRegistry([]sender.Identity{{ID: "a"}})
+	s.NotNil(err)
+}
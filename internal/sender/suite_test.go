@@ -0,0 +1,27 @@
+package sender_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/sender"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(SenderTestSuite))
+}
+
+type SenderTestSuite struct {
+	suite.Suite
+
+	Registry *sender.Registry
+}
+
+func (s *SenderTestSuite) SetupSuite() {
+	registry, err := sender.NewRegistry([]sender.Identity{
+		{ID: "info", Address: "info@test.com", Name: "Test", ReplyTo: "support@test.com"},
+		{ID: "security", Address: "security@test.com", Types: []string{"email:reset"}, Clients: []string{"auth-service"}},
+	})
+	s.Nil(err)
+	s.Registry = registry
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"golang.org/x/time/rate"
+)
+
+// APIKeyHeader request header carrying the API key
+const APIKeyHeader = "X-API-Key"
+
+var errRateLimited = errors.New("api key rate limit exceeded")
+
+// usage tracks rate limit and daily quota consumption of an API key
+type usage struct {
+	limiter *rate.Limiter
+	day     string
+	count   int
+}
+
+type apiKeyAuth struct {
+	store auth.KeyStore
+
+	lock  *sync.Mutex
+	usage map[string]*usage
+
+	requests syncint64.Counter
+}
+
+// APIKeyMiddleware authenticates requests through the API key header, looking
+// up its hash in the store. Each key rate limit is enforced per request, its
+// daily quota is charged per recipient by the handlers sending emails through
+// auth.Charge. Usage is reported in response headers and in the api.requests
+// metric.
+func APIKeyMiddleware(store auth.KeyStore, meter metric.Meter, skipper middleware.Skipper) (echo.MiddlewareFunc, error) {
+	a := &apiKeyAuth{
+		store: store,
+		lock:  new(sync.Mutex),
+		usage: make(map[string]*usage),
+	}
+	if meter != nil {
+		var err error
+		a.requests, err = meter.SyncInt64().Counter("api.requests")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			key := c.Request().Header.Get(APIKeyHeader)
+			if key == "" {
+				a.count(c, "", "unauthorized")
+				return echo.NewHTTPError(http.StatusUnauthorized, auth.ErrUnauthorized.Error())
+			}
+			apiKey, err := a.store.Lookup(c.Request().Context(), auth.HashKey(key))
+			if err != nil {
+				a.count(c, "", "unauthorized")
+				if errors.Is(err, errorx.ErrNotFound) {
+					return echo.NewHTTPError(http.StatusUnauthorized, auth.ErrUnauthorized.Error())
+				}
+				return err
+			}
+
+			q, err := a.consume(c, apiKey)
+			if err != nil {
+				return err
+			}
+
+			authenticate(c, &apiKey.Client)
+			c.SetRequest(c.Request().WithContext(auth.WithQuota(c.Request().Context(), q)))
+			err = next(c)
+			if q.exceeded {
+				a.count(c, apiKey.Name, "quota_exceeded")
+			} else {
+				a.count(c, apiKey.Name, "accepted")
+			}
+			return err
+		}
+	}, nil
+}
+
+// consume takes a token from the key rate limiter, setting usage headers on
+// the response, and returns the daily quota of the key
+func (a *apiKeyAuth) consume(c echo.Context, key *auth.APIKey) (*quota, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	u, ok := a.usage[key.Hash]
+	if !ok {
+		u = &usage{limiter: rate.NewLimiter(rate.Inf, 0)}
+		if key.RateLimit > 0 {
+			burst := key.Burst
+			if burst <= 0 {
+				burst = int(math.Ceil(key.RateLimit))
+			}
+			u.limiter = rate.NewLimiter(rate.Limit(key.RateLimit), burst)
+		}
+		a.usage[key.Hash] = u
+	}
+
+	now := time.Now().UTC()
+	header := c.Response().Header()
+	if key.RateLimit > 0 {
+		header.Set("X-RateLimit-Limit", strconv.FormatFloat(key.RateLimit, 'f', -1, 64))
+	}
+	if key.DailyQuota > 0 {
+		reset := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		header.Set("X-Quota-Limit", strconv.Itoa(key.DailyQuota))
+		header.Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+		header.Set("X-Quota-Remaining", strconv.Itoa(key.DailyQuota-u.used(now)))
+	}
+
+	if reservation := u.limiter.ReserveN(now, 1); !reservation.OK() || reservation.DelayFrom(now) > 0 {
+		if reservation.OK() {
+			header.Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(reservation.DelayFrom(now).Seconds())))
+			reservation.CancelAt(now)
+		}
+		a.count(c, key.Name, "rate_limited")
+		return nil, echo.NewHTTPError(http.StatusTooManyRequests, errRateLimited.Error())
+	}
+	return &quota{a: a, key: key, usage: u, header: header}, nil
+}
+
+// used returns the quota used in the day of the time, resetting it on a new
+// day
+func (u *usage) used(now time.Time) int {
+	if day := now.Format("2006-01-02"); u.day != day {
+		u.day, u.count = day, 0
+	}
+	return u.count
+}
+
+// quota daily quota of an API key charged by a request
+type quota struct {
+	a      *apiKeyAuth
+	key    *auth.APIKey
+	usage  *usage
+	header http.Header
+	// exceeded is set when the request has been rejected by the quota
+	exceeded bool
+}
+
+// Charge consumes n units of the daily quota of the key, if any, updating
+// the remaining quota header of the response
+func (q *quota) Charge(n int) error {
+	if q.key.DailyQuota <= 0 {
+		return nil
+	}
+	q.a.lock.Lock()
+	defer q.a.lock.Unlock()
+
+	used := q.usage.used(time.Now().UTC())
+	if used+n > q.key.DailyQuota {
+		q.exceeded = true
+		q.header.Set("X-Quota-Remaining", strconv.Itoa(q.key.DailyQuota-used))
+		return auth.ErrQuotaExceeded
+	}
+	q.usage.count += n
+	q.header.Set("X-Quota-Remaining", strconv.Itoa(q.key.DailyQuota-q.usage.count))
+	return nil
+}
+
+func (a *apiKeyAuth) count(c echo.Context, client, outcome string) {
+	if a.requests == nil {
+		return
+	}
+	a.requests.Add(c.Request().Context(), 1, attribute.String("client", client), attribute.String("outcome", outcome))
+}
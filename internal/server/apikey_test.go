@@ -0,0 +1,61 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/server"
+)
+
+func (s *ServerTestSuite) TestAPIKeyMiddleware() {
+	store := auth.NewMemoryKeyStore([]auth.APIKey{{
+		Client:     auth.Client{Name: "test"},
+		Hash:       auth.HashKey("secret"),
+		DailyQuota: 3,
+	}})
+	mw, err := server.APIKeyMiddleware(store, nil, nil)
+	s.Nil(err)
+
+	e := echo.New()
+	// POST requests send two emails, GET ones none
+	handler := mw(func(c echo.Context) error {
+		if c.Request().Method == http.MethodPost {
+			if err := auth.Charge(c.Request().Context(), 2); err != nil {
+				return email.HTTPError(err)
+			}
+		}
+		client := auth.FromContext(c.Request().Context())
+		return c.String(http.StatusOK, client.Name)
+	})
+	serve := func(method, key string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(method, "/email", nil)
+		if key != "" {
+			req.Header.Set(server.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		return rec, handler(e.NewContext(req, rec))
+	}
+
+	_, err = serve(http.MethodPost, "")
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+	_, err = serve(http.MethodPost, "wrong")
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+
+	rec, err := serve(http.MethodPost, "secret")
+	s.Nil(err)
+	s.Equal("test", rec.Body.String())
+	s.Equal("3", rec.Header().Get("X-Quota-Limit"))
+	s.Equal("1", rec.Header().Get("X-Quota-Remaining"))
+
+	// requests not sending emails don't consume the quota
+	rec, err = serve(http.MethodGet, "secret")
+	s.Nil(err)
+	s.Equal("1", rec.Header().Get("X-Quota-Remaining"))
+
+	rec, err = serve(http.MethodPost, "secret")
+	s.Equal(http.StatusTooManyRequests, err.(*echo.HTTPError).Code)
+	s.Equal("1", rec.Header().Get("X-Quota-Remaining"))
+}
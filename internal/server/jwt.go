@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// JWTConfig configures JWT authentication. JWKS is a file path or URL of the
+// key set of the identity provider. Claims listing allowed email types,
+// sender addresses and permissions can be either arrays or space separated
+// strings, missing types and senders claims allow any value, missing
+// permissions claims grant none.
+type JWTConfig struct {
+	JWKS             string `mapstructure:"jwks"`
+	Issuer           string `mapstructure:"issuer"`
+	Audience         string `mapstructure:"audience"`
+	NameClaim        string `mapstructure:"name_claim"`
+	TypesClaim       string `mapstructure:"types_claim"`
+	SendersClaim     string `mapstructure:"senders_claim"`
+	PermissionsClaim string `mapstructure:"permissions_claim"`
+}
+
+// JWTMiddleware authenticates requests through a bearer JWT in the
+// Authorization header, signed by a key of the key set, mapping its claims to
+// the client identity
+func JWTMiddleware(jwks *auth.JWKS, conf *JWTConfig, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if conf.NameClaim == "" {
+		conf.NameClaim = "sub"
+	}
+	if conf.TypesClaim == "" {
+		conf.TypesClaim = "email_types"
+	}
+	if conf.SendersClaim == "" {
+		conf.SendersClaim = "email_senders"
+	}
+	if conf.PermissionsClaim == "" {
+		conf.PermissionsClaim = "email_permissions"
+	}
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if !strings.HasPrefix(header, "Bearer ") {
+				return echo.NewHTTPError(http.StatusUnauthorized, auth.ErrUnauthorized.Error())
+			}
+
+			ctx := c.Request().Context()
+			claims := jwt.MapClaims{}
+			_, err := parser.ParseWithClaims(strings.TrimPrefix(header, "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+				kid, _ := token.Header["kid"].(string)
+				return jwks.Key(ctx, kid)
+			})
+			if err != nil || !claims.VerifyExpiresAt(time.Now().Unix(), true) ||
+				(conf.Issuer != "" && !claims.VerifyIssuer(conf.Issuer, true)) ||
+				(conf.Audience != "" && !claims.VerifyAudience(conf.Audience, true)) {
+				return echo.NewHTTPError(http.StatusUnauthorized, auth.ErrUnauthorized.Error())
+			}
+
+			name, _ := claims[conf.NameClaim].(string)
+			authenticate(c, &auth.Client{
+				Name:        name,
+				Types:       claimList(claims[conf.TypesClaim]),
+				Senders:     claimList(claims[conf.SendersClaim]),
+				Permissions: claimList(claims[conf.PermissionsClaim]),
+			})
+			return next(c)
+		}
+	}
+}
+
+// claimList returns the values of an array or space separated string claim
+func claimList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// authenticate propagates the client identity to the request context, the
+// current span and the request logs
+func authenticate(c echo.Context, client *auth.Client) {
+	ctx := auth.WithClient(c.Request().Context(), client)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("client.name", client.Name))
+	c.Set("client", client.Name)
+	c.SetRequest(c.Request().WithContext(ctx))
+}
+
+// RequirePermission rejects with 403 requests of clients lacking the
+// permission
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := auth.Permit(c.Request().Context(), permission); err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// authenticated skips requests already authenticated by another middleware
+func authenticated(c echo.Context) bool {
+	return auth.FromContext(c.Request().Context()) != nil
+}
@@ -0,0 +1,90 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/server"
+)
+
+func (s *ServerTestSuite) TestJWTMiddleware() {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	s.Nil(err)
+
+	// local stub of the identity provider key set endpoint
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"test","use":"sig","x":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(public))
+	}))
+	defer idp.Close()
+
+	jwks, err := auth.NewJWKS(context.Background(), idp.URL)
+	s.Nil(err)
+	mw := server.JWTMiddleware(jwks, &server.JWTConfig{Issuer: "https://idp.test.com", Audience: "mailer"}, nil)
+
+	e := echo.New()
+	var permissions []string
+	handler := mw(func(c echo.Context) error {
+		client := auth.FromContext(c.Request().Context())
+		permissions = client.Permissions
+		s.Equal([]string{"email:welcome", "email:reset"}, client.Types)
+		s.Equal([]string{"info@test.com"}, client.Senders)
+		return c.String(http.StatusOK, client.Name)
+	})
+	serve := func(claims jwt.MapClaims) (*httptest.ResponseRecorder, error) {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(private)
+		s.Nil(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/email", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+signed)
+		rec := httptest.NewRecorder()
+		return rec, handler(e.NewContext(req, rec))
+	}
+
+	claims := jwt.MapClaims{
+		"sub":           "billing-service",
+		"iss":           "https://idp.test.com",
+		"aud":           []string{"mailer"},
+		"exp":           time.Now().Add(time.Minute).Unix(),
+		"email_types":   "email:welcome email:reset",
+		"email_senders": []string{"info@test.com"},
+	}
+	rec, err := serve(claims)
+	s.Nil(err)
+	s.Equal("billing-service", rec.Body.String())
+	s.Empty(permissions)
+
+	claims["email_permissions"] = []string{auth.TemplatesPermission}
+	_, err = serve(claims)
+	s.Nil(err)
+	s.Equal([]string{auth.TemplatesPermission}, permissions)
+
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = serve(claims)
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+
+	// tokens without expiration are rejected
+	delete(claims, "exp")
+	_, err = serve(claims)
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+
+	claims["exp"] = time.Now().Add(time.Minute).Unix()
+	claims["aud"] = "other"
+	_, err = serve(claims)
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/email", nil)
+	err = handler(e.NewContext(req, httptest.NewRecorder()))
+	s.Equal(http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+}
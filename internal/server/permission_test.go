@@ -0,0 +1,30 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/server"
+)
+
+func (s *ServerTestSuite) TestRequirePermission() {
+	e := echo.New()
+	handler := server.RequirePermission(auth.TemplatesPermission)(func(c echo.Context) error {
+		return c.NoContent(http.StatusCreated)
+	})
+	serve := func(client *auth.Client) error {
+		req := httptest.NewRequest(http.MethodPost, "/templates/reset.html/versions", nil)
+		if client != nil {
+			req = req.WithContext(auth.WithClient(req.Context(), client))
+		}
+		return handler(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	err := serve(&auth.Client{Name: "billing", Types: []string{"email:welcome"}})
+	s.Require().NotNil(err)
+	s.Equal(http.StatusForbidden, err.(*echo.HTTPError).Code)
+	s.Nil(serve(&auth.Client{Name: "designer", Permissions: []string{auth.TemplatesPermission}}))
+	s.Nil(serve(nil))
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
@@ -20,8 +22,15 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/viper"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"github.com/xn3cr0nx/email-service/internal/auth"
 	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/link"
+	"github.com/xn3cr0nx/email-service/internal/metrics"
+	"github.com/xn3cr0nx/email-service/internal/preference"
 	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+	"github.com/xn3cr0nx/email-service/internal/verify"
 	"github.com/xn3cr0nx/email-service/pkg/pprof"
 	"github.com/xn3cr0nx/email-service/pkg/tracer"
 	"github.com/xn3cr0nx/email-service/pkg/validator"
@@ -85,17 +94,107 @@ func (s *Server) Listen() {
 
 	s.router.Use(middleware.RequestID())
 
+	// emails sent by the handlers are recorded with the http backend, and
+	// rejected instead of waiting for the rate limited sending budget
+	instruments, err := metrics.New(s.meter, metrics.HTTP)
+	if err != nil {
+		s.router.Logger.Fatal(err)
+	}
+	s.router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := metrics.NewContext(c.Request().Context(), instruments)
+			c.SetRequest(c.Request().WithContext(provider.NoWait(ctx)))
+			return next(c)
+		}
+	})
+
 	if s.tracer != nil {
 		// instrument echo with tracer middleware
 		mw := tracer.Middleware()
 		s.router.Use(mw)
 	}
 
+	// API keys are configured as list of objects with name, hash, senders,
+	// types, rate_limit, burst and daily_quota fields
+	var keys []auth.APIKey
+	if err := viper.UnmarshalKey("auth.api_keys", &keys); err != nil {
+		s.router.Logger.Fatal(err)
+	}
+	var jwtConf JWTConfig
+	if err := viper.UnmarshalKey("auth.jwt", &jwtConf); err != nil {
+		s.router.Logger.Fatal(err)
+	}
+	if len(keys) == 0 && jwtConf.JWKS == "" && !viper.GetBool("auth.disabled") {
+		// requests without a client are allowed everything
+		s.router.Logger.Fatal("REST API requires auth.api_keys or auth.jwt.jwks, set auth.disabled to expose it without authentication")
+	}
+
+	if jwtConf.JWKS != "" {
+		jwks, err := auth.NewJWKS(context.Background(), jwtConf.JWKS)
+		if err != nil {
+			s.router.Logger.Fatal(err)
+		}
+		skipper := skipPublic
+		if len(keys) > 0 {
+			// requests carrying an API key are authenticated by the API key middleware
+			skipper = func(c echo.Context) bool {
+				return skipPublic(c) || c.Request().Header.Get(APIKeyHeader) != ""
+			}
+		}
+		s.router.Use(JWTMiddleware(jwks, &jwtConf, skipper))
+	}
+	if len(keys) > 0 {
+		mw, err := APIKeyMiddleware(auth.NewMemoryKeyStore(keys), s.meter, func(c echo.Context) bool {
+			return skipPublic(c) || authenticated(c)
+		})
+		if err != nil {
+			s.router.Logger.Fatal(err)
+		}
+		s.router.Use(mw)
+	}
+
 	s.router.GET("/swagger/*", echoSwagger.WrapHandler)
 	s.router.GET("/status", handleStatus())
 
 	emailService := email.NewService(s.mailer, s.tracer, s.meter)
 	s.router.POST("/email", email.Handler(emailService))
+	s.router.POST("/email/batch", email.BatchHandler(emailService))
+	s.router.POST("/validate", email.ValidateHandler(emailService))
+	s.router.POST("/templates/:type/render", email.RenderHandler(emailService))
+	s.router.GET("/templates/:type/preview", email.PreviewHandler(emailService))
+
+	if store := preference.Get(); store != nil {
+		// preferences of any recipient can be read and changed
+		manage := RequirePermission(auth.PreferencesPermission)
+		s.router.GET("/preferences/:recipient", preference.GetHandler(store), manage)
+		s.router.PUT("/preferences/:recipient", preference.PutHandler(store), manage)
+		s.router.DELETE("/preferences/:recipient", preference.DeleteHandler(store), manage)
+	}
+
+	if store := template.GetStore(); store != nil {
+		// published templates render every email, whatever the client types
+		admin := RequirePermission(auth.TemplatesPermission)
+		s.router.GET("/templates/:name/versions", template.VersionsHandler(store), admin)
+		s.router.POST("/templates/:name/versions", template.CreateVersionHandler(store), admin)
+		s.router.POST("/templates/:name/versions/:version/publish", template.PublishHandler(store), admin)
+		s.router.POST("/templates/:name/rollback", template.RollbackHandler(store), admin)
+	}
+
+	if signer := link.Get(); signer != nil {
+		s.router.POST("/links/verify", link.VerifyHandler(signer))
+	}
+
+	if v := verify.Get(); v != nil {
+		s.router.POST("/verify/start", verify.StartHandler(v, s.mailer))
+		s.router.POST("/verify/check", verify.CheckHandler(v))
+	}
+
+	if t := tracking.GetTracker(); t != nil {
+		store := tracking.GetStore()
+		s.router.GET("/t/c/:token", tracking.ClickHandler(t, store))
+		s.router.GET("/t/o/:token", tracking.OpenHandler(t, store))
+		s.router.GET("/tracking/events", tracking.EventsHandler(store))
+	}
 
 	log.Printf(
 		"mailer (PID: %d) is starting on %s\n=> Ctrl-C to shutdown server\n",
@@ -108,7 +207,7 @@ func (s *Server) Listen() {
 		}
 	}()
 
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt)
 	<-ch
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -119,6 +218,12 @@ func (s *Server) Listen() {
 	}
 }
 
+// skipPublic skips authentication for public endpoints
+func skipPublic(c echo.Context) bool {
+	path := c.Request().URL.Path
+	return path == "/status" || strings.HasPrefix(path, "/swagger") || strings.HasPrefix(path, "/t/")
+}
+
 func timeout() time.Duration {
 	timeoutMillis, err := strconv.Atoi(os.Getenv("TIMEOUT_MILLIS"))
 	if err != nil {
@@ -140,12 +245,15 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 
 	code := http.StatusInternalServerError
 	m := ""
+	var fields validator.FieldErrors
 
 	if e, ok := err.(*echo.HTTPError); ok {
 		code = e.Code
 		if httpError, ok := e.Message.(*echo.HTTPError); ok {
 			m = httpError.Message.(string)
 		} else if _, ok := e.Message.(v.ValidationErrors); ok {
+		} else if fields, ok = e.Message.(validator.FieldErrors); ok {
+			m = "invalid request fields"
 		} else {
 			if stringError, ok := e.Message.(string); ok {
 				m = stringError
@@ -159,5 +267,12 @@ func customHTTPErrorHandler(err error, c echo.Context) {
 	if m != "" && m != message["error"] {
 		message["type"] = m
 	}
+	if len(fields) > 0 {
+		message["fields"] = fields
+	}
+	var limited *provider.RateLimitError
+	if errors.As(err, &limited) {
+		c.Response().Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(limited.RetryAfter.Seconds())))
+	}
 	c.JSON(code, message)
 }
@@ -0,0 +1,20 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+type ServerTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServerTestSuite) SetupSuite() {
+	logger.Setup()
+}
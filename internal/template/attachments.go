@@ -0,0 +1,57 @@
+package template
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+// Generated attachments names and content types
+const (
+	ReceiptName     = "receipt.pdf"
+	InviteName      = "invite.ics"
+	pdfContentType  = "application/pdf"
+	icsContentType  = "text/calendar; method=REQUEST; charset=utf-8"
+	receiptTemplate = ".receipt.html"
+)
+
+// ReceiptPath returns the path of the receipt template of the email type,
+// e.g. welcome.receipt.html for the welcome email, localized as templates
+func ReceiptPath(taskType, locale string) string {
+	return cache.localized(filepath.Join(cache.Dir, strings.TrimPrefix(taskType, "email:")+receiptTemplate), locale)
+}
+
+// Attachments generates the attachments of the email type: a PDF receipt,
+// when a receipt template of the type exists, filled with the same args of
+// the email template and translated in the locale, and the calendar invite
+// of the event, if not nil
+func Attachments(taskType, locale string, event *Event, args ...interface{}) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	if receipt := cache.Get(ReceiptPath(taskType, locale)); receipt != nil {
+		content, err := RenderPDF(Fill(string(receipt), locale, args...))
+		if err != nil {
+			return nil, fmt.Errorf("cannot render receipt: %w", err)
+		}
+		attachments = append(attachments, model.Attachment{
+			Name:        ReceiptName,
+			Content:     base64.StdEncoding.EncodeToString(content),
+			ContentType: pdfContentType,
+		})
+	}
+
+	if event != nil {
+		content, err := event.ICS()
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, model.Attachment{
+			Name:        InviteName,
+			Content:     base64.StdEncoding.EncodeToString(content),
+			ContentType: icsContentType,
+		})
+	}
+	return attachments, nil
+}
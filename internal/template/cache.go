@@ -1,53 +1,329 @@
 package template
 
 import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/xn3cr0nx/email-service/pkg/logger"
 )
 
+// reloadDelay time waited after a change before reloading, so that editors
+// writing multiple events for a save trigger a single reload
+const reloadDelay = 100 * time.Millisecond
+
+var errMissingTemplate = errors.New("missing template")
+
+// defaults templates of every email type, with their sample data, and message
+// catalogs, overridden by the files with the same name in the templates
+// directory
+//
+//go:embed defaults/*.html defaults/*.sample.json defaults/locales/*.json
+var defaults embed.FS
+
+// TemplateCache html templates of a directory, indexed by path. Templates
+// are safe for concurrent reads while they are reloaded by Watch.
 type TemplateCache struct {
-	Dir   string
-	Cache map[string][]byte
+	Dir string
+
+	mu        sync.RWMutex
+	templates map[string][]byte
+	catalogs  map[string]map[string]string
 }
 
 var cache *TemplateCache
 
+// NewTemplateCache initializes the shared global cache with the templates
+// of the directory, returning the existing one if already initialized
 func NewTemplateCache(templateDir *string) (*TemplateCache, error) {
 	if cache != nil {
 		return cache, nil
 	}
-	cache = &TemplateCache{Dir: *templateDir}
-	cache.Cache = make(map[string][]byte)
-	addToCache := func(path string, f fs.DirEntry, err error) error {
+	c, err := LoadTemplates(*templateDir)
+	if err != nil {
+		return nil, err
+	}
+	cache = c
+	return cache, nil
+}
+
+// LoadTemplates reads and validates the default templates and the ones of
+// the directory in a new cache, failing on the first invalid template
+func LoadTemplates(templateDir string) (*TemplateCache, error) {
+	c := &TemplateCache{Dir: templateDir}
+	templates, err := c.load(func(path string, err error) error { return fmt.Errorf("%s: %w", path, err) })
+	if err != nil {
+		logger.Error("Cache", err, logger.Params{"template dir": templateDir})
+		return nil, err
+	}
+	c.templates, c.catalogs = templates, c.parseCatalogs(templates)
+	if _, err := os.Stat(templateDir); err != nil {
+		logger.Warn("Cache", "Templates directory not available, using default templates", logger.Params{"base_dir": templateDir, "error": err.Error()})
+	}
+
+	logger.Info("Cache", "Initialized", logger.Params{"templates": len(templates), "base_dir": c.Dir})
+	return c, nil
+}
+
+func (c *TemplateCache) Get(path string) []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.templates[path]
+}
+
+// load reads the default html templates, overlaid by the ones of the
+// directory when it exists and by the published versions of the template
+// store, if configured. Invalid templates are passed to onInvalid, which
+// either returns an error stopping the walk or nil skipping the template.
+func (c *TemplateCache) load(onInvalid func(path string, err error) error) (map[string][]byte, error) {
+	templates := make(map[string][]byte)
+	embedded, err := fs.Sub(defaults, "defaults")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.read(embedded, templates, onInvalid); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(c.Dir); !errors.Is(err, fs.ErrNotExist) {
+		if err := c.read(os.DirFS(c.Dir), templates, onInvalid); err != nil {
+			return nil, err
+		}
+	}
+	if store == nil {
+		return templates, nil
+	}
+
+	published, err := store.Published(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("cannot read published templates: %w", err)
+	}
+	for _, v := range published {
+		key := filepath.Join(c.Dir, v.Name)
+		if err := Validate(key, []byte(v.Content)); err != nil {
+			if err := onInvalid(key, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		templates[key] = []byte(v.Content)
+	}
+	return templates, nil
+}
+
+// read walks the file system adding its html templates, compiled component
+// templates, sample data and message catalogs, indexed by their path in the
+// templates directory
+func (c *TemplateCache) read(fsys fs.FS, templates map[string][]byte, onInvalid func(path string, err error) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, f fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-
-		if filepath.Ext(path) != ".html" || f.IsDir() {
+		catalog, sample, components := isCatalog(name), isSample(name), isComponent(name)
+		if (path.Ext(name) != ".html" && !catalog && !sample && !components) || !f.Type().IsRegular() {
 			return nil
 		}
 
-		if f.Type().IsRegular() {
-			b, err := os.ReadFile(path)
-			if err != nil {
-				return err
+		key := filepath.Join(c.Dir, filepath.FromSlash(name))
+		var b []byte
+		if components {
+			// compiled templates replace the html template with the same name
+			key = strings.TrimSuffix(key, componentExt) + ".html"
+			if b, err = compileComponents(fsys, name); err != nil {
+				return onInvalid(key, err)
 			}
-			cache.Cache[path] = b
+		} else if b, err = fs.ReadFile(fsys, name); err != nil {
+			return err
 		}
+		validate := Validate
+		switch {
+		case catalog:
+			validate = func(_ string, content []byte) error { return validateCatalog(content) }
+		case sample:
+			validate = func(_ string, content []byte) error { return validateSample(content) }
+		}
+		if err := validate(key, b); err != nil {
+			return onInvalid(key, err)
+		}
+		templates[key] = b
 		return nil
+	})
+}
+
+// Check verifies that every email type of the schema has a valid template
+func (c *TemplateCache) Check() error {
+	types := make([]string, 0, len(Schema))
+	for taskType := range Schema {
+		types = append(types, taskType)
 	}
-	if err := filepath.WalkDir(*templateDir, addToCache); err != nil {
-		logger.Error("Cache", err, logger.Params{"template dir": templateDir})
-		return nil, err
+	sort.Strings(types)
+
+	for _, taskType := range types {
+		path := c.path(taskType)
+		content := c.Get(path)
+		if content == nil {
+			return fmt.Errorf("%w: %s (%s)", errMissingTemplate, taskType, path)
+		}
+		if err := Validate(path, content); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
 	}
+	return nil
+}
 
-	logger.Info("Cache", "Initialized", logger.Params{"templates": len(cache.Cache), "base_dir": cache.Dir})
-	return cache, nil
+// Reload reads again the templates directory, swapping the cached templates
+// at once. Invalid templates are logged and their last valid version is kept.
+func (c *TemplateCache) Reload() error {
+	c.mu.RLock()
+	previous := c.templates
+	c.mu.RUnlock()
+
+	var kept []string
+	templates, err := c.load(func(path string, err error) error {
+		logger.Error("Cache", err, logger.Params{"template": path})
+		kept = append(kept, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range kept {
+		if b, ok := previous[path]; ok {
+			templates[path] = b
+		}
+	}
+
+	catalogs := c.parseCatalogs(templates)
+	c.mu.Lock()
+	c.templates, c.catalogs = templates, catalogs
+	c.mu.Unlock()
+	logger.Info("Cache", "Reloaded", logger.Params{"templates": len(templates), "invalid": len(kept), "base_dir": c.Dir})
+	return nil
 }
 
-func (c *TemplateCache) Get(path string) []byte {
-	return c.Cache[path]
+// Watch reloads the templates whenever files of the directory are created,
+// changed or removed, until the context is done
+func (c *TemplateCache) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watchDirs(watcher, c.Dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		timer := time.NewTimer(reloadDelay)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// new subdirectories have to be watched too
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := watchDirs(watcher, event.Name); err != nil {
+							logger.Error("Cache", err, logger.Params{"dir": event.Name})
+						}
+					}
+				}
+				timer.Reset(reloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("Cache", err, logger.Params{"base_dir": c.Dir})
+			case <-timer.C:
+				if err := c.Reload(); err != nil {
+					logger.Error("Cache", err, logger.Params{"base_dir": c.Dir})
+				}
+			}
+		}
+	}()
+	logger.Info("Cache", "Watching templates", logger.Params{"base_dir": c.Dir})
+	return nil
+}
+
+// Poll reloads the templates whenever the versions published in the template
+// store change, checking every interval until the context is done, so that
+// publications through other instances are applied
+func (c *TemplateCache) Poll(ctx context.Context, interval time.Duration) error {
+	if store == nil {
+		return nil
+	}
+	last, err := publishedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := publishedVersions(ctx)
+				if err != nil {
+					logger.Error("Cache", err, logger.Params{"base_dir": c.Dir})
+					continue
+				}
+				if current == last {
+					continue
+				}
+				if err := c.Reload(); err != nil {
+					logger.Error("Cache", err, logger.Params{"base_dir": c.Dir})
+					continue
+				}
+				last = current
+			}
+		}
+	}()
+	logger.Info("Cache", "Polling published templates", logger.Params{"base_dir": c.Dir, "interval": interval.String()})
+	return nil
+}
+
+// publishedVersions returns the published version of every template of the
+// store, sorted, e.g. reset.html:2,welcome.html:5
+func publishedVersions(ctx context.Context) (string, error) {
+	published, err := store.Published(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot read published templates: %w", err)
+	}
+	versions := make([]string, len(published))
+	for i, v := range published {
+		versions[i] = fmt.Sprintf("%s:%d", v.Name, v.Version)
+	}
+	sort.Strings(versions)
+	return strings.Join(versions, ","), nil
+}
+
+// watchDirs adds the directory and its subdirectories to the watcher
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, f fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,96 @@
+package template_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+const (
+	validWelcome   = "<h2>Welcome %s</h2><a href=\"%s\">%s</a>"
+	invalidWelcome = "<h2>Welcome %s</h2><a href=\"%s\">Confirm</a>"
+)
+
+func (s *TemplateTestSuite) TestLoadTemplatesInvalid() {
+	dir := s.T().TempDir()
+	s.Nil(os.WriteFile(filepath.Join(dir, "welcome.html"), []byte(invalidWelcome), 0o644))
+
+	_, err := template.LoadTemplates(dir)
+	s.NotNil(err)
+}
+
+func (s *TemplateTestSuite) TestLoadTemplatesDefaults() {
+	dir := filepath.Join(s.T().TempDir(), "missing")
+	cache, err := template.LoadTemplates(dir)
+	s.Nil(err)
+	s.Nil(cache.Check())
+	s.NotNil(cache.Get(filepath.Join(dir, "welcome.html")))
+	s.NotNil(cache.Get(filepath.Join(dir, "layout.html")))
+}
+
+func (s *TemplateTestSuite) TestLoadTemplatesOverride() {
+	dir := s.T().TempDir()
+	welcome := filepath.Join(dir, "welcome.html")
+	s.Nil(os.WriteFile(welcome, []byte(validWelcome), 0o644))
+
+	cache, err := template.LoadTemplates(dir)
+	s.Nil(err)
+	s.Nil(cache.Check())
+	s.Equal(validWelcome, string(cache.Get(welcome)))
+	s.NotNil(cache.Get(filepath.Join(dir, "reset.html")))
+}
+
+func (s *TemplateTestSuite) TestValidate() {
+	s.Nil(template.Validate("welcome.html", []byte(validWelcome)))
+	s.Nil(template.Validate("layout.html", []byte("<div style=\"width: 100%%\">%s</div>")))
+	s.Nil(template.Validate("custom.html", []byte("%s %s")))
+	s.NotNil(template.Validate("welcome.html", []byte(invalidWelcome)))
+	s.NotNil(template.Validate("layout.html", []byte("<div style=\"width: 100%\">%s</div>")))
+	s.NotNil(template.Validate("reset.html", []byte("%s %d")))
+}
+
+func (s *TemplateTestSuite) TestWatch() {
+	dir := s.T().TempDir()
+	welcome := filepath.Join(dir, "welcome.html")
+	reset := filepath.Join(dir, "reset.html")
+	s.Nil(os.WriteFile(welcome, []byte(validWelcome), 0o644))
+
+	cache, err := template.LoadTemplates(dir)
+	s.Nil(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Nil(cache.Watch(ctx))
+
+	// concurrent readers during the reloads
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			s.NotNil(cache.Get(welcome))
+		}
+	}()
+
+	updated := "<h1>Hi %s</h1><a href=\"%s\">%s</a>"
+	s.Nil(os.WriteFile(welcome, []byte(updated), 0o644))
+	s.Eventually(func() bool { return string(cache.Get(welcome)) == updated }, 2*time.Second, 10*time.Millisecond)
+
+	// invalid changes keep the last valid version
+	s.Nil(os.WriteFile(welcome, []byte(invalidWelcome), 0o644))
+	custom := "<a href=\"%s\">%s</a>"
+	s.Nil(os.WriteFile(reset, []byte(custom), 0o644))
+	s.Eventually(func() bool { return string(cache.Get(reset)) == custom }, 2*time.Second, 10*time.Millisecond)
+	s.Equal(updated, string(cache.Get(welcome)))
+
+	// removed templates fall back to the default ones
+	s.Nil(os.Remove(reset))
+	s.Eventually(func() bool { return string(cache.Get(reset)) != custom }, 2*time.Second, 10*time.Millisecond)
+	s.NotNil(cache.Get(reset))
+
+	cancel()
+	wg.Wait()
+}
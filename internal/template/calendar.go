@@ -0,0 +1,207 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	icsDateTime = "20060102T150405"
+	// icsLineLength max length in octets of content lines, longer ones are folded
+	icsLineLength = 75
+)
+
+var errInvalidEvent = errors.New("invalid calendar event, requires uid, summary, start, end and organizer")
+
+// Attendee participant of a calendar event
+type Attendee struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// Event calendar invite rendered as iCalendar (RFC 5545) VEVENT. Start and
+// end are expressed in TimeZone, an IANA time zone name, defaulting to UTC.
+type Event struct {
+	UID         string     `json:"uid"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	Start       time.Time  `json:"start"`
+	End         time.Time  `json:"end"`
+	TimeZone    string     `json:"timezone,omitempty"`
+	Organizer   Attendee   `json:"organizer"`
+	Attendees   []Attendee `json:"attendees,omitempty"`
+	// Sequence: revision of the event, increased when it is updated
+	Sequence int `json:"sequence,omitempty"`
+}
+
+// Validate checks the event required fields, time zone and participants
+func (e *Event) Validate() error {
+	if e.UID == "" || e.Summary == "" || e.Start.IsZero() || e.End.Before(e.Start) || e.Organizer.Email == "" {
+		return errInvalidEvent
+	}
+	if _, err := e.location(); err != nil {
+		return err
+	}
+	for _, participant := range append([]Attendee{e.Organizer}, e.Attendees...) {
+		if _, err := mail.ParseAddress(participant.Email); err != nil {
+			return fmt.Errorf("invalid event participant %s: %w", participant.Email, err)
+		}
+	}
+	return nil
+}
+
+// location returns the event time zone, UTC if not set
+func (e *Event) location() (*time.Location, error) {
+	if e.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event time zone %s: %w", e.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// ICS renders the event as iCalendar REQUEST, including the VTIMEZONE
+// definition of the event time zone
+func (e *Event) ICS() ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	loc, _ := e.location()
+	tz := loc.String()
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(fold(fmt.Sprintf(format, args...)))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//email-service//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:REQUEST")
+	vtimezone(&b, loc, e.Start.In(loc).Year())
+
+	line("BEGIN:VEVENT")
+	line("UID:%s", escape(e.UID))
+	line("SEQUENCE:%d", e.Sequence)
+	line("DTSTAMP:%sZ", time.Now().UTC().Format(icsDateTime))
+	line("DTSTART;TZID=%s:%s", tz, e.Start.In(loc).Format(icsDateTime))
+	line("DTEND;TZID=%s:%s", tz, e.End.In(loc).Format(icsDateTime))
+	line("SUMMARY:%s", escape(e.Summary))
+	if e.Description != "" {
+		line("DESCRIPTION:%s", escape(e.Description))
+	}
+	if e.Location != "" {
+		line("LOCATION:%s", escape(e.Location))
+	}
+	line("ORGANIZER%s:mailto:%s", commonName(e.Organizer.Name), e.Organizer.Email)
+	for _, attendee := range e.Attendees {
+		line("ATTENDEE%s;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:%s", commonName(attendee.Name), attendee.Email)
+	}
+	line("STATUS:CONFIRMED")
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return []byte(b.String()), nil
+}
+
+// vtimezone writes the VTIMEZONE component of the location, with the
+// standard and daylight saving time observances of the year
+func vtimezone(b *strings.Builder, loc *time.Location, year int) {
+	b.WriteString(fold("BEGIN:VTIMEZONE"))
+	b.WriteString(fold("TZID:" + loc.String()))
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	transitions := 0
+	for day := start; day.Year() == year; day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if offset(day) == offset(next) {
+			continue
+		}
+		// binary search of the transition instant within the day
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Minute {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if offset(mid) == offset(lo) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		transition := hi.Truncate(time.Minute)
+		_, from := lo.Zone()
+		name, to := transition.Zone()
+		observance(b, name, transition.Add(time.Duration(from)*time.Second).UTC(), from, to, to > from)
+		transitions++
+	}
+	if transitions == 0 {
+		name, off := start.Zone()
+		observance(b, name, time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC), off, off, false)
+	}
+	b.WriteString(fold("END:VTIMEZONE"))
+}
+
+// observance writes a STANDARD or DAYLIGHT component starting at the local
+// time before the transition
+func observance(b *strings.Builder, name string, start time.Time, from, to int, daylight bool) {
+	component := "STANDARD"
+	if daylight {
+		component = "DAYLIGHT"
+	}
+	b.WriteString(fold("BEGIN:" + component))
+	b.WriteString(fold("DTSTART:" + start.Format(icsDateTime)))
+	b.WriteString(fold("TZOFFSETFROM:" + utcOffset(from)))
+	b.WriteString(fold("TZOFFSETTO:" + utcOffset(to)))
+	b.WriteString(fold("TZNAME:" + escape(name)))
+	b.WriteString(fold("END:" + component))
+}
+
+func offset(t time.Time) int {
+	_, off := t.Zone()
+	return off
+}
+
+// utcOffset formats the offset in seconds as [+-]hhmm
+func utcOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// commonName returns the CN parameter of the name, if any
+func commonName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(";CN=\"%s\"", strings.NewReplacer("\"", "'", "\r", "", "\n", " ").Replace(name))
+}
+
+// escape escapes TEXT values as defined by RFC 5545 section 3.3.11
+func escape(text string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n").Replace(text)
+}
+
+// fold splits the content line in lines of at most 75 octets, without
+// breaking UTF-8 sequences, terminating it with CRLF
+func fold(line string) string {
+	var b strings.Builder
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space
+		limit = icsLineLength - 1
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
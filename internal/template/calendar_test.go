@@ -0,0 +1,74 @@
+package template_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) event() *template.Event {
+	return &template.Event{
+		UID:       "booking-42@test.com",
+		Summary:   "Consultation, room 3",
+		Location:  "Via Roma 1; Milano",
+		Start:     time.Date(2023, time.March, 30, 10, 0, 0, 0, time.UTC),
+		End:       time.Date(2023, time.March, 30, 11, 0, 0, 0, time.UTC),
+		TimeZone:  "Europe/Rome",
+		Organizer: template.Attendee{Name: "Clinic", Email: "booking@test.com"},
+		Attendees: []template.Attendee{{Name: "Mario Rossi", Email: "mario@test.com"}},
+	}
+}
+
+func (s *TemplateTestSuite) TestEventICS() {
+	ics, err := s.event().ICS()
+	s.Nil(err)
+	content := string(ics)
+
+	s.True(strings.HasPrefix(content, "BEGIN:VCALENDAR\r\n"))
+	s.True(strings.HasSuffix(content, "END:VCALENDAR\r\n"))
+	s.Contains(content, "METHOD:REQUEST\r\n")
+	s.Contains(content, "TZID:Europe/Rome\r\n")
+	// Europe/Rome switches to daylight saving time on the last Sunday of March
+	s.Contains(content, "BEGIN:DAYLIGHT\r\nDTSTART:20230326T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\n")
+	s.Contains(content, "BEGIN:STANDARD\r\nDTSTART:20231029T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\n")
+	s.Contains(content, "DTSTART;TZID=Europe/Rome:20230330T120000\r\n")
+	s.Contains(content, "DTEND;TZID=Europe/Rome:20230330T130000\r\n")
+	s.Contains(content, "SUMMARY:Consultation\\, room 3\r\n")
+	s.Contains(content, "LOCATION:Via Roma 1\\; Milano\r\n")
+	s.Contains(content, "ORGANIZER;CN=\"Clinic\":mailto:booking@test.com\r\n")
+	s.Contains(strings.ReplaceAll(content, "\r\n ", ""), "ATTENDEE;CN=\"Mario Rossi\";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:mario@test.com\r\n")
+
+	for _, line := range strings.Split(content, "\r\n") {
+		s.LessOrEqual(len(line), 75)
+	}
+}
+
+func (s *TemplateTestSuite) TestEventICSFolding() {
+	event := s.event()
+	event.Description = strings.Repeat("à", 100)
+	ics, err := event.ICS()
+	s.Nil(err)
+
+	unfolded := strings.ReplaceAll(string(ics), "\r\n ", "")
+	s.Contains(unfolded, "DESCRIPTION:"+event.Description+"\r\n")
+	for _, line := range strings.Split(string(ics), "\r\n") {
+		s.LessOrEqual(len(line), 75)
+	}
+}
+
+func (s *TemplateTestSuite) TestEventValidate() {
+	s.Nil(s.event().Validate())
+
+	event := s.event()
+	event.End = event.Start.Add(-time.Hour)
+	s.NotNil(event.Validate())
+
+	event = s.event()
+	event.TimeZone = "Mars/Olympus"
+	s.NotNil(event.Validate())
+
+	event = s.event()
+	event.Attendees = append(event.Attendees, template.Attendee{Email: "invalid"})
+	s.NotNil(event.Validate())
+}
@@ -0,0 +1,460 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// componentExt extension of the component templates, compiled to html
+// templates with the same name when the templates are loaded
+const componentExt = ".mjml"
+
+// component defaults
+const (
+	defaultBodyWidth  = 600
+	defaultPadding    = "10px 25px"
+	defaultFontFamily = "Ubuntu, Helvetica, Arial, sans-serif"
+	defaultFontSize   = "13px"
+	// maxIncludeDepth prevents include cycles
+	maxIncludeDepth = 10
+)
+
+var (
+	errUnknownComponent = errors.New("unknown component")
+	errInvalidNesting   = errors.New("invalid component nesting")
+	errUnbalancedTag    = errors.New("unbalanced component tag")
+	errUnexpectedText   = errors.New("unexpected text outside of content components")
+	errIncludeDepth     = errors.New("too many nested includes")
+)
+
+// contentComponents components whose content is raw html
+var contentComponents = map[string]bool{
+	"mj-text": true, "mj-button": true, "mj-raw": true, "mj-title": true, "mj-preview": true, "mj-style": true,
+}
+
+// children components allowed in each component, the empty name is the root
+// of a file, either a full document or a fragment of sections
+var children = map[string]map[string]bool{
+	"":        {"mjml": true, "mj-section": true, "mj-raw": true},
+	"mjml":    {"mj-head": true, "mj-body": true},
+	"mj-head": {"mj-title": true, "mj-preview": true, "mj-style": true},
+	"mj-body": {"mj-section": true, "mj-raw": true},
+	"mj-section": {
+		"mj-column": true, "mj-raw": true,
+	},
+	"mj-column": {
+		"mj-text": true, "mj-button": true, "mj-image": true, "mj-divider": true, "mj-spacer": true, "mj-raw": true,
+	},
+}
+
+// component node of a component template
+type component struct {
+	name     string
+	attrs    map[string]string
+	children []*component
+	content  string
+}
+
+// attr returns the attribute of the component, def if not set
+func (c *component) attr(name, def string) string {
+	if v, ok := c.attrs[name]; ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// isComponent returns true if the file is a component template. Component
+// files in subdirectories, e.g. blocks/footer.mjml, are reusable blocks only
+// compiled when included.
+func isComponent(name string) bool {
+	return path.Dir(name) == "." && path.Ext(name) == componentExt
+}
+
+// compileComponents compiles a component template of the file system to a
+// responsive html template. Templates are either a full <mjml> document,
+// with <mj-head> and <mj-body>, or a fragment of sections to be placed in a
+// layout with <mj-raw>%s</mj-raw>. Sections contain columns, stacked on
+// small screens, made of text, button, image, divider, spacer and raw
+// components. Blocks are included with <mj-include path="..." />.
+func compileComponents(fsys fs.FS, name string) ([]byte, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	root := &component{}
+	if err := parseComponents(fsys, string(b), root, 0); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	c := &compiler{width: defaultBodyWidth, columns: make(map[string]string)}
+	var out strings.Builder
+	if len(root.children) == 1 && root.children[0].name == "mjml" {
+		c.document(&out, root.children[0])
+	} else {
+		c.sections(&out, root.children, c.width)
+	}
+	return []byte(out.String()), nil
+}
+
+// parseComponents parses the components of the source in the parent,
+// resolving includes from the file system
+func parseComponents(fsys fs.FS, src string, parent *component, depth int) error {
+	if depth > maxIncludeDepth {
+		return errIncludeDepth
+	}
+	stack := []*component{parent}
+	var content *component
+	var raw strings.Builder
+
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+			if content != nil || len(stack) > 1 {
+				return fmt.Errorf("%w: %s not closed", errUnbalancedTag, stack[len(stack)-1].name)
+			}
+			return nil
+		}
+
+		// content components keep their raw html up to their end tag
+		if content != nil {
+			if name, _ := z.TagName(); tt == html.EndTagToken && string(name) == content.name {
+				content.content, content = strings.TrimSpace(raw.String()), nil
+				raw.Reset()
+				continue
+			}
+			raw.Write(z.Raw())
+			continue
+		}
+
+		current := stack[len(stack)-1]
+		switch tt {
+		case html.TextToken:
+			if strings.TrimSpace(string(z.Text())) != "" {
+				return fmt.Errorf("%w: %q", errUnexpectedText, strings.TrimSpace(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			n, hasAttr := z.TagName()
+			c := &component{name: string(n), attrs: make(map[string]string)}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				c.attrs[string(key)] = string(value)
+			}
+
+			if c.name == "mj-include" {
+				b, err := fs.ReadFile(fsys, path.Clean(c.attrs["path"]))
+				if err != nil {
+					return fmt.Errorf("cannot include %s: %w", c.attrs["path"], err)
+				}
+				if err := parseComponents(fsys, string(b), current, depth+1); err != nil {
+					return fmt.Errorf("%s: %w", c.attrs["path"], err)
+				}
+				continue
+			}
+			if _, ok := children[c.name]; !ok && !contentComponents[c.name] && !isLeaf(c.name) {
+				return fmt.Errorf("%w: %s", errUnknownComponent, c.name)
+			}
+			if !children[current.name][c.name] {
+				return fmt.Errorf("%w: %s in %s", errInvalidNesting, c.name, nameOrRoot(current.name))
+			}
+			current.children = append(current.children, c)
+			if tt == html.SelfClosingTagToken {
+				continue
+			}
+			if contentComponents[c.name] {
+				content = c
+				continue
+			}
+			if !isLeaf(c.name) {
+				stack = append(stack, c)
+			}
+		case html.EndTagToken:
+			n, _ := z.TagName()
+			if isLeaf(string(n)) || string(n) == "mj-include" {
+				continue
+			}
+			if string(n) != current.name {
+				return fmt.Errorf("%w: </%s> closing %s", errUnbalancedTag, n, nameOrRoot(current.name))
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// isLeaf returns true for components without children nor content
+func isLeaf(name string) bool {
+	return name == "mj-image" || name == "mj-divider" || name == "mj-spacer"
+}
+
+func nameOrRoot(name string) string {
+	if name == "" {
+		return "template root"
+	}
+	return name
+}
+
+// compiler state of the compilation of a template
+type compiler struct {
+	width   int
+	title   string
+	preview string
+	styles  []string
+	// columns responsive classes of the columns, with their desktop width
+	columns map[string]string
+}
+
+// document writes the html document of the <mjml> component
+func (c *compiler) document(out *strings.Builder, root *component) {
+	var body *component
+	for _, child := range root.children {
+		switch child.name {
+		case "mj-head":
+			for _, h := range child.children {
+				switch h.name {
+				case "mj-title":
+					c.title = h.content
+				case "mj-preview":
+					c.preview = h.content
+				case "mj-style":
+					c.styles = append(c.styles, h.content)
+				}
+			}
+		case "mj-body":
+			body = child
+		}
+	}
+	if body == nil {
+		body = &component{}
+	}
+	if width := pixels(body.attr("width", "")); width > 0 {
+		c.width = width
+	}
+	background := body.attr("background-color", "#ffffff")
+
+	var content strings.Builder
+	fmt.Fprintf(&content, `<div style="background-color:%s">`, attrValue(background))
+	c.sections(&content, body.children, c.width)
+	content.WriteString(`</div>`)
+
+	out.WriteString(`<!doctype html><html xmlns="http://www.w3.org/1999/xhtml" xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office"><head>`)
+	fmt.Fprintf(out, `<title>%s</title>`, c.title)
+	out.WriteString(`<meta http-equiv="X-UA-Compatible" content="IE=edge"><meta http-equiv="Content-Type" content="text/html; charset=UTF-8"><meta name="viewport" content="width=device-width, initial-scale=1">`)
+	out.WriteString(`<style data-embed>#outlook a { padding:0; } body { margin:0; padding:0; -webkit-text-size-adjust:100%%; -ms-text-size-adjust:100%%; } table, td { border-collapse:collapse; mso-table-lspace:0pt; mso-table-rspace:0pt; } img { border:0; height:auto; line-height:100%%; outline:none; text-decoration:none; -ms-interpolation-mode:bicubic; }</style>`)
+	out.WriteString(`<!--[if mso]><noscript><xml><o:OfficeDocumentSettings><o:AllowPNG/><o:PixelsPerInch>96</o:PixelsPerInch></o:OfficeDocumentSettings></xml></noscript><![endif]-->`)
+	if len(c.columns) > 0 {
+		classes := make([]string, 0, len(c.columns))
+		for class := range c.columns {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		out.WriteString(`<style data-embed>@media only screen and (min-width:480px) {`)
+		for _, class := range classes {
+			fmt.Fprintf(out, ` .%s { width:%s !important; max-width:%s; }`, class, c.columns[class], c.columns[class])
+		}
+		out.WriteString(` }</style>`)
+	}
+	for _, style := range c.styles {
+		fmt.Fprintf(out, `<style>%s</style>`, style)
+	}
+	fmt.Fprintf(out, `</head><body style="word-spacing:normal;background-color:%s">`, attrValue(background))
+	if c.preview != "" {
+		fmt.Fprintf(out, `<div style="display:none;font-size:1px;color:#ffffff;line-height:1px;max-height:0px;max-width:0px;opacity:0;overflow:hidden">%s</div>`, c.preview)
+	}
+	out.WriteString(content.String())
+	out.WriteString(`</body></html>`)
+}
+
+// sections writes the sections, centered in a box of the width, with ghost
+// tables for Outlook, which ignores max-width
+func (c *compiler) sections(out *strings.Builder, sections []*component, width int) {
+	for _, section := range sections {
+		if section.name == "mj-raw" {
+			out.WriteString(section.content)
+			continue
+		}
+		background := section.attr("background-color", "")
+		fmt.Fprintf(out, `<!--[if mso | IE]><table align="center" border="0" cellpadding="0" cellspacing="0" role="presentation" style="width:%dpx" width="%d"><tr><td style="line-height:0px;font-size:0px;mso-line-height-rule:exactly"><![endif]-->`, width, width)
+		fmt.Fprintf(out, `<div%s style="margin:0px auto;max-width:%dpx%s">`, classAttr(section), width, optional("background-color", background))
+		fmt.Fprintf(out, `<table align="center" border="0" cellpadding="0" cellspacing="0" role="presentation" style="width:100%%%%%s"><tbody><tr>`, optional("background-color", background))
+		fmt.Fprintf(out, `<td style="direction:ltr;font-size:0px;padding:%s;text-align:%s">`, attrValue(section.attr("padding", "20px 0")), attrValue(section.attr("text-align", "center")))
+		c.columnsOf(out, section, width-horizontalPadding(section.attr("padding", "20px 0")))
+		out.WriteString(`</td></tr></tbody></table></div><!--[if mso | IE]></td></tr></table><![endif]-->`)
+	}
+}
+
+// columnsOf writes the columns of the section, inline blocks stacked on small
+// screens, sharing equally the width not set explicitly
+func (c *compiler) columnsOf(out *strings.Builder, section *component, width int) {
+	fixed, unsized := 0.0, 0
+	for _, child := range section.children {
+		if child.name != "mj-column" {
+			continue
+		}
+		if w := child.attr("width", ""); w != "" {
+			fixed += percentage(w, width)
+		} else {
+			unsized++
+		}
+	}
+
+	out.WriteString(`<!--[if mso | IE]><table role="presentation" border="0" cellpadding="0" cellspacing="0"><tr><![endif]-->`)
+	for _, child := range section.children {
+		if child.name == "mj-raw" {
+			out.WriteString(child.content)
+			continue
+		}
+		per := 100.0
+		if w := child.attr("width", ""); w != "" {
+			per = percentage(w, width)
+		} else if unsized > 0 {
+			per = (100 - fixed) / float64(unsized)
+		}
+		px := int(float64(width) * per / 100)
+		label := strings.ReplaceAll(strconv.FormatFloat(per, 'f', -1, 64), ".", "-")
+		if len(label) > 8 {
+			label = label[:8]
+		}
+		class := "mj-column-per-" + label
+		c.columns[class] = strconv.FormatFloat(per, 'f', -1, 64) + "%%"
+
+		fmt.Fprintf(out, `<!--[if mso | IE]><td style="vertical-align:%s;width:%dpx"><![endif]-->`, attrValue(child.attr("vertical-align", "top")), px)
+		classes := class
+		if extra := child.attr("css-class", ""); extra != "" {
+			classes += " " + attrValue(extra)
+		}
+		fmt.Fprintf(out, `<div class="%s" style="font-size:0px;text-align:left;direction:ltr;display:inline-block;vertical-align:%s;width:100%%%%">`, classes, attrValue(child.attr("vertical-align", "top")))
+		fmt.Fprintf(out, `<table border="0" cellpadding="0" cellspacing="0" role="presentation" style="vertical-align:%s%s" width="100%%%%"><tbody>`, attrValue(child.attr("vertical-align", "top")), optional("background-color", child.attr("background-color", "")))
+		for _, content := range child.children {
+			c.content(out, content, px)
+		}
+		out.WriteString(`</tbody></table></div><!--[if mso | IE]></td><![endif]-->`)
+	}
+	out.WriteString(`<!--[if mso | IE]></tr></table><![endif]-->`)
+}
+
+// content writes a row of the column with the content component
+func (c *compiler) content(out *strings.Builder, n *component, width int) {
+	if n.name == "mj-raw" {
+		fmt.Fprintf(out, `<tr><td>%s</td></tr>`, n.content)
+		return
+	}
+	padding := n.attr("padding", defaultPadding)
+	align := n.attr("align", "left")
+	if n.name == "mj-button" || n.name == "mj-image" || n.name == "mj-divider" {
+		align = n.attr("align", "center")
+	}
+	fmt.Fprintf(out, `<tr><td align="%s"%s style="font-size:0px;padding:%s;word-break:break-word">`, attrValue(align), classAttr(n), attrValue(padding))
+
+	switch n.name {
+	case "mj-text":
+		fmt.Fprintf(out, `<div style="font-family:%s;font-size:%s;line-height:%s;text-align:%s;color:%s">%s</div>`,
+			attrValue(n.attr("font-family", defaultFontFamily)), attrValue(n.attr("font-size", defaultFontSize)),
+			attrValue(n.attr("line-height", "1")), attrValue(align), attrValue(n.attr("color", "#000000")), n.content)
+	case "mj-button":
+		background, color := attrValue(n.attr("background-color", "#414141")), attrValue(n.attr("color", "#ffffff"))
+		radius := attrValue(n.attr("border-radius", "3px"))
+		fmt.Fprintf(out, `<table border="0" cellpadding="0" cellspacing="0" role="presentation" style="border-collapse:separate;line-height:100%%%%">`)
+		fmt.Fprintf(out, `<tr><td align="center" bgcolor="%s" role="presentation" style="border:none;border-radius:%s;cursor:auto;mso-padding-alt:%s;background:%s" valign="middle">`,
+			background, radius, attrValue(n.attr("inner-padding", "10px 25px")), background)
+		fmt.Fprintf(out, `<a href="%s" style="display:inline-block;background:%s;color:%s;font-family:%s;font-size:%s;font-weight:%s;line-height:120%%%%;margin:0;text-decoration:none;text-transform:none;padding:%s;mso-padding-alt:0px;border-radius:%s" target="_blank">%s</a>`,
+			attrValue(n.attr("href", "#")), background, color, attrValue(n.attr("font-family", defaultFontFamily)), attrValue(n.attr("font-size", defaultFontSize)),
+			attrValue(n.attr("font-weight", "normal")), attrValue(n.attr("inner-padding", "10px 25px")), radius, n.content)
+		out.WriteString(`</td></tr></table>`)
+	case "mj-image":
+		imageWidth := width - horizontalPadding(padding)
+		if w := pixels(n.attr("width", "")); w > 0 && w < imageWidth {
+			imageWidth = w
+		}
+		img := fmt.Sprintf(`<img alt="%s" src="%s" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%%%%;font-size:13px" width="%d" height="auto">`,
+			attrValue(n.attr("alt", "")), attrValue(n.attr("src", "")), imageWidth)
+		if href := n.attr("href", ""); href != "" {
+			img = fmt.Sprintf(`<a href="%s" target="_blank">%s</a>`, attrValue(href), img)
+		}
+		fmt.Fprintf(out, `<table border="0" cellpadding="0" cellspacing="0" role="presentation" style="border-collapse:collapse;border-spacing:0px"><tbody><tr><td style="width:%dpx">%s</td></tr></tbody></table>`, imageWidth, img)
+	case "mj-divider":
+		border := fmt.Sprintf("%s %s %s", attrValue(n.attr("border-style", "solid")), attrValue(n.attr("border-width", "4px")), attrValue(n.attr("border-color", "#000000")))
+		fmt.Fprintf(out, `<p style="border-top:%s;font-size:1px;margin:0px auto;width:100%%%%"></p>`, border)
+		fmt.Fprintf(out, `<!--[if mso | IE]><table align="center" border="0" cellpadding="0" cellspacing="0" style="border-top:%s;font-size:1px;margin:0px auto;width:%dpx" role="presentation" width="%dpx"><tr><td style="height:0;line-height:0">&nbsp;</td></tr></table><![endif]-->`,
+			border, width-horizontalPadding(padding), width-horizontalPadding(padding))
+	case "mj-spacer":
+		height := attrValue(n.attr("height", "20px"))
+		fmt.Fprintf(out, `<div style="height:%s;line-height:%s">&#8202;</div>`, height, height)
+	}
+	out.WriteString(`</td></tr>`)
+}
+
+// escape escapes the attribute value, keeping the formatting verbs and the
+// placeholders of the template
+func attrValue(value string) string {
+	return html.EscapeString(value)
+}
+
+// classAttr returns the class attribute of the css-class of the component
+func classAttr(n *component) string {
+	if class := n.attr("css-class", ""); class != "" {
+		return ` class="` + attrValue(class) + `"`
+	}
+	return ""
+}
+
+// optional returns the css declaration prefixed by a semicolon, empty if the
+// value is not set
+func optional(property, value string) string {
+	if value == "" {
+		return ""
+	}
+	return ";" + property + ":" + attrValue(value)
+}
+
+// pixels returns the pixels of a px length, 0 if not a px length
+func pixels(length string) int {
+	px, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(length), "px"))
+	if err != nil || px < 0 {
+		return 0
+	}
+	return px
+}
+
+// percentage returns the percentage of the width of a length, either a px
+// length or a percentage, optionally escaped as in templates, e.g. 50%%
+func percentage(length string, width int) float64 {
+	length = strings.TrimSpace(length)
+	if strings.HasSuffix(length, "%") {
+		per, err := strconv.ParseFloat(strings.TrimRight(length, "%"), 64)
+		if err != nil {
+			return 0
+		}
+		return per
+	}
+	if width == 0 {
+		return 0
+	}
+	return float64(pixels(length)) * 100 / float64(width)
+}
+
+// horizontalPadding returns the sum of the left and right px padding of the
+// padding shorthand
+func horizontalPadding(padding string) int {
+	fields := strings.Fields(padding)
+	switch len(fields) {
+	case 1:
+		return 2 * pixels(fields[0])
+	case 2, 3:
+		return 2 * pixels(fields[1])
+	case 4:
+		return pixels(fields[1]) + pixels(fields[3])
+	}
+	return 0
+}
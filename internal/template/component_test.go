@@ -0,0 +1,99 @@
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+const (
+	componentLayout = `<mjml>
+  <mj-head>
+    <mj-title>{{t:welcome.subject}}</mj-title>
+    <mj-style>.footer a { color: #999999 }</mj-style>
+  </mj-head>
+  <mj-body width="500px" background-color="#f0f3f5">
+    <mj-section background-color="#01010d">
+      <mj-column>
+        <mj-image src="logo.png" alt="Logo" width="200px" />
+      </mj-column>
+    </mj-section>
+    <mj-raw>%s</mj-raw>
+    <mj-include path="blocks/footer.mjml" />
+  </mj-body>
+</mjml>`
+	componentFooter = `<mj-section>
+  <mj-column width="50%%"><mj-text css-class="footer">{{t:layout.footer}}</mj-text></mj-column>
+  <mj-column><mj-divider border-width="1px" /><mj-spacer height="10px" /></mj-column>
+</mj-section>`
+	componentWelcome = `<mj-section>
+  <mj-column>
+    <mj-text font-size="20px">Welcome <b>%s</b></mj-text>
+    <mj-button href="%s" background-color="#222222">Confirm</mj-button>
+    <mj-text>%s</mj-text>
+  </mj-column>
+</mj-section>`
+)
+
+func (s *TemplateTestSuite) writeComponents(files map[string]string) string {
+	dir := s.T().TempDir()
+	for name, content := range files {
+		s.Nil(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		s.Nil(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func (s *TemplateTestSuite) TestLoadComponents() {
+	dir := s.writeComponents(map[string]string{
+		"layout.mjml":        componentLayout,
+		"blocks/footer.mjml": componentFooter,
+		"welcome.mjml":       componentWelcome,
+	})
+	cache, err := template.LoadTemplates(dir)
+	s.Nil(err)
+	s.Nil(cache.Check())
+	s.Nil(cache.Get(filepath.Join(dir, "blocks", "footer.html")))
+
+	layout := string(cache.Get(filepath.Join(dir, "layout.html")))
+	s.True(strings.HasPrefix(layout, "<!doctype html>"))
+	s.Contains(layout, "<title>{{t:welcome.subject}}</title>")
+	s.Contains(layout, "<style>.footer a { color: #999999 }</style>")
+	s.Contains(layout, `style="width:500px" width="500"`)
+	s.Contains(layout, `<img alt="Logo" src="logo.png"`)
+	s.Contains(layout, `width="200"`)
+	s.Contains(layout, "%s")
+	s.Contains(layout, ".mj-column-per-50 { width:50%% !important; max-width:50%%; }")
+	s.Contains(layout, "{{t:layout.footer}}")
+	s.Contains(layout, `<td align="left" class="footer"`)
+	s.Contains(layout, "border-top:solid 1px #000000")
+	s.Contains(layout, "<!--[if mso | IE]>")
+
+	welcome := string(cache.Get(filepath.Join(dir, "welcome.html")))
+	s.Contains(welcome, `<a href="%s" style="display:inline-block;background:#222222`)
+	s.Contains(welcome, "font-size:20px")
+	s.Contains(welcome, "Welcome <b>%s</b>")
+	s.NotContains(welcome, "<!doctype html>")
+}
+
+func (s *TemplateTestSuite) TestLoadComponentsInvalid() {
+	invalid := []string{
+		`<mj-section><mj-column><mj-unknown /></mj-column></mj-section>`,
+		`<mj-section><mj-text>Hi</mj-text></mj-section>`,
+		`<mj-section><mj-column></mj-section>`,
+		`<mj-section>Hi</mj-section>`,
+		`<mj-section><mj-column><mj-text>%s</mj-text></mj-column></mj-section>`,
+		`<mj-include path="blocks/missing.mjml" />`,
+	}
+	for _, content := range invalid {
+		dir := s.writeComponents(map[string]string{"welcome.mjml": content})
+		_, err := template.LoadTemplates(dir)
+		s.NotNil(err, content)
+	}
+
+	dir := s.writeComponents(map[string]string{"blocks/loop.mjml": `<mj-include path="blocks/loop.mjml" />`, "welcome.mjml": `<mj-include path="blocks/loop.mjml" />`})
+	_, err := template.LoadTemplates(dir)
+	s.NotNil(err)
+}
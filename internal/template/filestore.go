@@ -0,0 +1,200 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// publishedFile name of the file storing the published version number
+const publishedFile = "published"
+
+// FileStore filesystem implementation of TemplateStore. Each template is a
+// directory with a JSON file per version and a file with the published
+// version number.
+type FileStore struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// NewFileStore returns a template store persisting versions in the directory
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Versions returns the versions of the template sorted by number
+func (s *FileStore) Versions(ctx context.Context, name string) ([]Version, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.versions(name)
+}
+
+func (s *FileStore) versions(name string) ([]Version, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: template %s", errorx.ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	published, err := s.published(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, name, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var v Version
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("invalid version file %s: %w", entry.Name(), err)
+		}
+		v.Published = v.Version == published
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: template %s", errorx.ErrNotFound, name)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// published returns the published version number of the template, 0 if none
+func (s *FileStore) published(name string) (int, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, name, publishedFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// Create writes the next version of the template, versions files are never
+// overwritten
+func (s *FileStore) Create(ctx context.Context, name, content, author string) (*Version, error) {
+	if err := validateVersion(name, content); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	versions, err := s.versions(name)
+	if err != nil && !errors.Is(err, errorx.ErrNotFound) {
+		return nil, err
+	}
+	number := 1
+	if len(versions) > 0 {
+		number = versions[len(versions)-1].Version + 1
+	}
+	v := &Version{
+		Name:      name,
+		Version:   number,
+		Content:   content,
+		Author:    author,
+		CreatedAt: time.Now().UTC(),
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, name), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, name, fmt.Sprintf("%d.json", v.Version)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%w: version %d of %s", errorx.ErrAlreadyExists, v.Version, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return v, f.Close()
+}
+
+// Publish replaces the published version number of the template
+func (s *FileStore) Publish(ctx context.Context, name string, version int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	versions, err := s.versions(name)
+	if err != nil {
+		return err
+	}
+	if _, err := findVersion(versions, version); err != nil {
+		return err
+	}
+
+	// the pointer is replaced atomically renaming a temporary file
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, name), publishedFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strconv.Itoa(version)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name, publishedFile))
+}
+
+// Published returns the published version of every template
+func (s *FileStore) Published(ctx context.Context) ([]Version, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var published []Version
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		versions, err := s.versions(entry.Name())
+		if errors.Is(err, errorx.ErrNotFound) || errors.Is(err, errInvalidName) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if v.Published {
+				published = append(published, v)
+			}
+		}
+	}
+	return published, nil
+}
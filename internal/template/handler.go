@@ -0,0 +1,177 @@
+package template
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/auth"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// VersionBody request body to create a template version
+type VersionBody struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// getVersions godoc
+// @ID get-template-versions
+//
+// @Router /templates/{name}/versions [get]
+// @Summary List template versions
+// @Description List the versions of the template, flagging the published one
+// @Tags templates
+//
+// @Produce  json
+//
+// @Param name path string true "template file name, e.g. welcome.html"
+//
+// @Success 200 {array} Version
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func VersionsHandler(s TemplateStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+		versions, err := s.Versions(c.Request().Context(), c.Param("name"))
+		if err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusOK, versions)
+	}
+}
+
+// postVersion godoc
+// @ID post-template-version
+//
+// @Router /templates/{name}/versions [post]
+// @Summary Create template version
+// @Description Create a new version of the template, without publishing it
+// @Tags templates
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param name path string true "template file name, e.g. welcome.html"
+// @Param version body VersionBody true "template content"
+//
+// @Success 201 {object} Version
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 409 {string} string
+// @Failure 500 {string} string
+func CreateVersionHandler(s TemplateStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(VersionBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		ctx := c.Request().Context()
+		var author string
+		if client := auth.FromContext(ctx); client != nil {
+			author = client.Name
+		}
+		v, err := s.Create(ctx, c.Param("name"), b.Content, author)
+		if err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusCreated, v)
+	}
+}
+
+// publishVersion godoc
+// @ID publish-template-version
+//
+// @Router /templates/{name}/versions/{version}/publish [post]
+// @Summary Publish template version
+// @Description Publish the template version, replacing the template used to render emails
+// @Tags templates
+//
+// @Produce  json
+//
+// @Param name path string true "template file name, e.g. welcome.html"
+// @Param version path int true "version number"
+//
+// @Success 200 {object} Version
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+func PublishHandler(s TemplateStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid version number")
+		}
+		ctx := c.Request().Context()
+		name := c.Param("name")
+		if err := s.Publish(ctx, name, version); err != nil {
+			return httpError(err)
+		}
+		versions, err := s.Versions(ctx, name)
+		if err != nil {
+			return httpError(err)
+		}
+		v, err := findVersion(versions, version)
+		if err != nil {
+			return httpError(err)
+		}
+		if err := invalidate(); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, v)
+	}
+}
+
+// rollbackTemplate godoc
+// @ID rollback-template
+//
+// @Router /templates/{name}/rollback [post]
+// @Summary Roll back template
+// @Description Publish the version preceding the published one
+// @Tags templates
+//
+// @Produce  json
+//
+// @Param name path string true "template file name, e.g. welcome.html"
+//
+// @Success 200 {object} Version
+// @Failure 403 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Failure 500 {string} string
+func RollbackHandler(s TemplateStore) func(echo.Context) error {
+	return func(c echo.Context) error {
+		v, err := Rollback(c.Request().Context(), s, c.Param("name"))
+		if err != nil {
+			return httpError(err)
+		}
+		if err := invalidate(); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, v)
+	}
+}
+
+// invalidate reloads the shared template cache after a publication
+func invalidate() error {
+	if cache == nil {
+		return nil
+	}
+	return cache.Reload()
+}
+
+func httpError(err error) error {
+	switch {
+	case errors.Is(err, errorx.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, errInvalidName), errors.Is(err, errInvalidTemplate):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, errNoRollback), errors.Is(err, errorx.ErrAlreadyExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,496 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ClipSize size of the HTML after which Gmail clips the message, hiding the
+// rest of the content behind a link
+const ClipSize = 102 * 1024
+
+var errInvalidAssetBase = errors.New("invalid asset base, expected an absolute URL")
+
+var (
+	cssComments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	spaces      = regexp.MustCompile(`\s+`)
+	// selectorToken compound selector of a type selector, classes and id
+	selectorToken  = regexp.MustCompile(`^(\*|[a-zA-Z][a-zA-Z0-9-]*)?((?:[.#][a-zA-Z0-9_-]+)*)$`)
+	selectorSimple = regexp.MustCompile(`[.#][a-zA-Z0-9_-]+`)
+)
+
+// preserved elements whose whitespace is significant
+var preserved = map[atom.Atom]bool{atom.Pre: true, atom.Textarea: true, atom.Script: true, atom.Style: true}
+
+var assetBase *url.URL
+
+// SetAssetBase assign the base URL relative image sources are resolved
+// against, relative sources are kept if empty
+func SetAssetBase(base string) error {
+	if base == "" {
+		assetBase = nil
+		return nil
+	}
+	u, err := url.Parse(base)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%w: %s", errInvalidAssetBase, base)
+	}
+	assetBase = u
+	return nil
+}
+
+// cssRule declarations of a selector in a style block
+type cssRule struct {
+	selector    []selectorPart
+	specificity int
+	order       int
+	decls       []cssDecl
+}
+
+// cssDecl property declaration of a rule
+type cssDecl struct {
+	property  string
+	value     string
+	important bool
+}
+
+// selectorPart compound selector, e.g. td.cell, preceded by its combinator
+// with the previous part, either descendant " " or child ">"
+type selectorPart struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+}
+
+// PostProcess prepares the rendered HTML for email clients: CSS rules of
+// style blocks are inlined in the style attribute of the matching elements,
+// whitespace is collapsed, relative image sources are resolved against the
+// asset base and a warning is logged when the HTML exceeds ClipSize.
+//
+// Rules that can't be inlined, such as media queries and pseudo classes, are
+// kept in their style block.
+func PostProcess(taskType, content string) (string, error) {
+	processed, err := postProcess(content)
+	if err != nil {
+		return "", err
+	}
+	if len(processed) > ClipSize {
+		logger.Warn("Template", "Rendered HTML exceeds the Gmail clipping size", logger.Params{"type": taskType, "size": len(processed), "max": ClipSize})
+	}
+	return processed, nil
+}
+
+func postProcess(content string) (string, error) {
+	doc, err := parseHTML(content)
+	if err != nil {
+		return "", err
+	}
+
+	rules := extractStyles(doc)
+	walk(doc, func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			inlineRules(n, rules)
+			resolveAsset(n)
+		}
+	})
+	minify(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseHTML parses full documents, or fragments in the context of a body
+// appended to an empty document
+func parseHTML(content string) (*html.Node, error) {
+	if strings.Contains(strings.ToLower(content), "<html") {
+		return html.Parse(strings.NewReader(content))
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return nil, err
+	}
+	doc := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+		doc.AppendChild(n)
+	}
+	return doc, nil
+}
+
+// walk calls fn on the node and its descendants, in document order
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		walk(c, fn)
+		c = next
+	}
+}
+
+// extractStyles returns the inlinable rules of the style blocks of the
+// document, keeping in the blocks the rules that can't be inlined and
+// removing the emptied blocks. Blocks with the data-embed attribute are not
+// inlined.
+func extractStyles(n *html.Node) []cssRule {
+	var rules []cssRule
+	var styles []*html.Node
+	walk(n, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style {
+			styles = append(styles, n)
+		}
+	})
+	for _, style := range styles {
+		// embedded styles, such as client resets, are kept as they are
+		if hasAttr(style, "data-embed") {
+			removeAttr(style, "data-embed")
+			continue
+		}
+		var css strings.Builder
+		for c := style.FirstChild; c != nil; c = c.NextSibling {
+			css.WriteString(c.Data)
+		}
+		var kept string
+		rules, kept = parseCSS(css.String(), rules)
+		for style.FirstChild != nil {
+			style.RemoveChild(style.FirstChild)
+		}
+		if kept == "" {
+			style.Parent.RemoveChild(style)
+			continue
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: kept})
+	}
+	return rules
+}
+
+// parseCSS appends the inlinable rules of the stylesheet, returning the
+// at-rules and the rules with unsupported selectors to keep
+func parseCSS(css string, rules []cssRule) ([]cssRule, string) {
+	css = cssComments.ReplaceAllString(css, "")
+	var kept []string
+	for len(strings.TrimSpace(css)) > 0 {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[:open])
+		// the block ends at the matching brace, at-rules can nest blocks
+		end, depth := len(css), 0
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		block := css[open+1 : end]
+		if end < len(css) {
+			css = css[end+1:]
+		} else {
+			css = ""
+		}
+
+		if strings.HasPrefix(prelude, "@") {
+			kept = append(kept, prelude+"{"+strings.TrimSpace(block)+"}")
+			continue
+		}
+		decls := parseDeclarations(block)
+		var unsupported []string
+		for _, selector := range strings.Split(prelude, ",") {
+			selector = strings.TrimSpace(selector)
+			parts, specificity, ok := parseSelector(selector)
+			if !ok {
+				unsupported = append(unsupported, selector)
+				continue
+			}
+			rules = append(rules, cssRule{selector: parts, specificity: specificity, order: len(rules), decls: decls})
+		}
+		if len(unsupported) > 0 {
+			kept = append(kept, strings.Join(unsupported, ",")+"{"+strings.TrimSpace(block)+"}")
+		}
+	}
+	return rules, strings.Join(kept, "")
+}
+
+// parseDeclarations parses the declarations of a rule or style attribute
+func parseDeclarations(block string) []cssDecl {
+	var decls []cssDecl
+	for _, declaration := range strings.Split(block, ";") {
+		i := strings.Index(declaration, ":")
+		if i < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(declaration[:i]))
+		value := spaces.ReplaceAllString(strings.TrimSpace(declaration[i+1:]), " ")
+		important := false
+		if lower := strings.ToLower(value); strings.HasSuffix(lower, "!important") {
+			value, important = strings.TrimSpace(value[:len(value)-len("!important")]), true
+		}
+		if property == "" || value == "" {
+			continue
+		}
+		decls = append(decls, cssDecl{property: property, value: value, important: important})
+	}
+	return decls
+}
+
+// parseSelector parses selectors made of type, class and id selectors
+// combined by descendant and child combinators, returning false for any
+// other selector
+func parseSelector(selector string) ([]selectorPart, int, bool) {
+	fields := strings.Fields(strings.ReplaceAll(selector, ">", " > "))
+	var parts []selectorPart
+	specificity := 0
+	combinator := byte(' ')
+	for _, field := range fields {
+		if field == ">" {
+			if len(parts) == 0 || combinator == '>' {
+				return nil, 0, false
+			}
+			combinator = '>'
+			continue
+		}
+		match := selectorToken.FindStringSubmatch(field)
+		if match == nil {
+			return nil, 0, false
+		}
+		part := selectorPart{combinator: combinator, tag: strings.ToLower(match[1])}
+		if part.tag == "*" {
+			part.tag = ""
+		} else if part.tag != "" {
+			specificity++
+		}
+		for _, token := range selectorSimple.FindAllString(match[2], -1) {
+			if token[0] == '#' {
+				part.id = token[1:]
+				specificity += 10000
+			} else {
+				part.classes = append(part.classes, token[1:])
+				specificity += 100
+			}
+		}
+		parts = append(parts, part)
+		combinator = ' '
+	}
+	if len(parts) == 0 || combinator == '>' {
+		return nil, 0, false
+	}
+	return parts, specificity, true
+}
+
+// matches returns true if the element matches the selector parts up to i,
+// checking the ancestors for combinators
+func matches(n *html.Node, parts []selectorPart, i int) bool {
+	if !parts[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matches(p, parts, i-1) {
+			return true
+		}
+		if parts[i].combinator == '>' {
+			return false
+		}
+	}
+	return false
+}
+
+func (p selectorPart) matches(n *html.Node) bool {
+	if p.tag != "" && p.tag != n.Data {
+		return false
+	}
+	if p.id != "" && attr(n, "id") != p.id {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, class := range p.classes {
+		found := false
+		for _, c := range classes {
+			found = found || c == class
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineRules merges the declarations of the matching rules in the style
+// attribute of the element, following the cascade: important declarations
+// first, then the style attribute, then specificity and source order
+func inlineRules(n *html.Node, rules []cssRule) {
+	type applied struct {
+		cssDecl
+		rank, specificity, order int
+	}
+	var decls []applied
+	for _, rule := range rules {
+		if !matches(n, rule.selector, len(rule.selector)-1) {
+			continue
+		}
+		for _, d := range rule.decls {
+			rank := 0
+			if d.important {
+				rank = 2
+			}
+			decls = append(decls, applied{d, rank, rule.specificity, rule.order})
+		}
+	}
+	if len(decls) == 0 {
+		if style := attr(n, "style"); style != "" {
+			setAttr(n, "style", strings.TrimSpace(spaces.ReplaceAllString(style, " ")))
+		}
+		return
+	}
+	for _, d := range parseDeclarations(attr(n, "style")) {
+		rank := 1
+		if d.important {
+			rank = 3
+		}
+		decls = append(decls, applied{d, rank, 0, 0})
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		if decls[i].rank != decls[j].rank {
+			return decls[i].rank < decls[j].rank
+		}
+		if decls[i].specificity != decls[j].specificity {
+			return decls[i].specificity < decls[j].specificity
+		}
+		return decls[i].order < decls[j].order
+	})
+
+	var properties []string
+	values := make(map[string]string)
+	for _, d := range decls {
+		if _, ok := values[d.property]; !ok {
+			properties = append(properties, d.property)
+		}
+		values[d.property] = d.value
+		if d.important {
+			values[d.property] += " !important"
+		}
+	}
+	style := make([]string, len(properties))
+	for i, property := range properties {
+		style[i] = property + ": " + values[property]
+	}
+	setAttr(n, "style", strings.Join(style, "; "))
+}
+
+// resolveAsset resolves relative image sources against the asset base
+func resolveAsset(n *html.Node) {
+	if assetBase == nil {
+		return
+	}
+	key := ""
+	switch n.DataAtom {
+	case atom.Img:
+		key = "src"
+	case atom.Table, atom.Td, atom.Th, atom.Body:
+		key = "background"
+	default:
+		return
+	}
+	src := strings.TrimSpace(attr(n, key))
+	u, err := url.Parse(src)
+	if src == "" || err != nil || u.IsAbs() || strings.HasPrefix(src, "//") || strings.HasPrefix(src, "{{") {
+		return
+	}
+	setAttr(n, key, assetBase.ResolveReference(u).String())
+}
+
+// minify collapses whitespace of text nodes, removing whitespace between
+// block elements, and removes comments other than conditional comments
+func minify(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode && !strings.HasPrefix(strings.TrimSpace(c.Data), "[if"):
+			n.RemoveChild(c)
+		case c.Type == html.TextNode && !preserved[n.DataAtom]:
+			c.Data = spaces.ReplaceAllString(c.Data, " ")
+			if strings.TrimSpace(c.Data) == "" && isBlock(c.PrevSibling) && isBlock(c.NextSibling) {
+				n.RemoveChild(c)
+			}
+		case c.Type == html.ElementNode && !preserved[c.DataAtom]:
+			minify(c)
+		}
+		c = next
+	}
+}
+
+// isBlock returns true for missing siblings and block elements, whose
+// surrounding whitespace isn't rendered
+func isBlock(n *html.Node) bool {
+	if n == nil {
+		return true
+	}
+	if n.Type != html.ElementNode {
+		return n.Type == html.CommentNode || n.Type == html.DoctypeNode
+	}
+	switch n.DataAtom {
+	case atom.Td, atom.Th, atom.Tbody, atom.Thead, atom.Tfoot, atom.Head, atom.Body, atom.Meta, atom.Link, atom.Title, atom.Style, atom.Center, atom.Pre:
+		return true
+	}
+	return blockElements[n.Data]
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func removeAttr(n *html.Node, key string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
+
+func setAttr(n *html.Node, key, value string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
@@ -0,0 +1,67 @@
+package template_test
+
+import (
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestPostProcessInline() {
+	html, err := template.PostProcess(template.WelcomeEmail, `<style>
+  /* button */
+  p { color: #222; margin: 0 }
+  .button { color: #fff; padding: 12px }
+  td > a.button { padding: 8px !important }
+  #footer p { font-size: 12px }
+  a:hover { color: red }
+  @media (max-width: 600px) { p { margin: 4px } }
+</style>
+<p style="margin: 2px">Hi</p>
+<table><tr><td><a class="button" href="https://example.com" style="padding: 20px">Go</a></td></tr></table>
+<div id="footer"><p>Bye</p></div>`)
+	s.Nil(err)
+	s.Contains(html, `<p style="color: #222; margin: 2px">Hi</p>`)
+	s.Contains(html, `style="color: #fff; padding: 8px !important"`)
+	s.Contains(html, `<p style="color: #222; margin: 0; font-size: 12px">Bye</p>`)
+	s.Contains(html, `<style>a:hover{color: red}@media (max-width: 600px){p { margin: 4px }}</style>`)
+	s.NotContains(html, "/* button */")
+
+	html, err = template.PostProcess(template.WelcomeEmail, `<style data-embed>p { color: red }</style><p>Hi</p>`)
+	s.Nil(err)
+	s.Equal(`<style>p { color: red }</style><p>Hi</p>`, html)
+}
+
+func (s *TemplateTestSuite) TestPostProcessMinify() {
+	html, err := template.PostProcess(template.WelcomeEmail, `<html><head><style>p { color: red }</style></head>
+<body>
+  <!-- comment -->
+  <!--[if mso]><table><tr><td><![endif]-->
+  <div>
+    <p>Hello
+       <b>Jane</b>  Doe</p>
+    <pre>a
+  b</pre>
+  </div>
+</body></html>`)
+	s.Nil(err)
+	s.Equal(`<html><head></head><body><!--[if mso]><table><tr><td><![endif]--><div><p style="color: red">Hello <b>Jane</b> Doe</p><pre>a
+  b</pre></div></body></html>`, html)
+}
+
+func (s *TemplateTestSuite) TestPostProcessAssets() {
+	s.NotNil(template.SetAssetBase("assets/"))
+	s.Nil(template.SetAssetBase("https://cdn.example.com/assets/"))
+	defer template.SetAssetBase("")
+
+	html, err := template.PostProcess(template.WelcomeEmail, `<img src="logo.png" alt="Logo"><img src="/img/a.png">`+
+		`<img src="https://example.com/b.png"><img src="cid:logo"><img src="{{avatar}}"><td background="bg.jpg"></td>`)
+	s.Nil(err)
+	s.Contains(html, `src="https://cdn.example.com/assets/logo.png"`)
+	s.Contains(html, `src="https://cdn.example.com/img/a.png"`)
+	s.Contains(html, `src="https://example.com/b.png"`)
+	s.Contains(html, `src="cid:logo"`)
+	s.Contains(html, `src="{{avatar}}"`)
+
+	_, err = template.PostProcess(template.WelcomeEmail, "<p>"+strings.Repeat("a", template.ClipSize)+"</p>")
+	s.Nil(err)
+}
@@ -0,0 +1,250 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Lint issues severities, warnings fail the lint only in strict mode
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var errMissingDir = errors.New("templates directory not found")
+
+// voidElements elements without end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEnd elements whose end tag can be omitted
+var optionalEnd = map[string]bool{
+	"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true, "th": true,
+	"thead": true, "tbody": true, "tfoot": true, "option": true, "html": true, "head": true, "body": true,
+}
+
+// Issue problem found linting a template
+type Issue struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", i.Path, i.Line, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Severity, i.Message)
+}
+
+// Lint checks the templates of the directory: params of the type schema,
+// message catalogs and sample data are validated as when loading them,
+// translated messages must exist in the default locale, html must be
+// balanced, images must have alt text, links must use HTTPS and the rendered
+// email, filled with the sample data, must not exceed ClipSize. Issues are
+// sorted by path and line.
+func Lint(dir string) ([]Issue, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", errMissingDir, dir)
+	}
+
+	var issues []Issue
+	c := &TemplateCache{Dir: dir}
+	templates, err := c.load(func(path string, err error) error {
+		issues = append(issues, Issue{Path: path, Severity: SeverityError, Message: err.Error()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	catalogs := c.parseCatalogs(templates)
+
+	var paths []string
+	err = fs.WalkDir(os.DirFS(dir), ".", func(name string, f fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case isComponent(name):
+			paths = append(paths, filepath.Join(dir, strings.TrimSuffix(filepath.FromSlash(name), componentExt)+".html"))
+		case path.Ext(name) == ".html" && f.Type().IsRegular():
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		content, ok := templates[p]
+		if !ok {
+			// invalid templates have already been reported
+			continue
+		}
+		issues = append(issues, lintHTML(p, string(content))...)
+		missing := make(map[string]bool)
+		for _, key := range messageRegexp.FindAllStringSubmatch(string(content), -1) {
+			if _, ok := catalogs[DefaultLocale][key[1]]; !ok && !missing[key[1]] {
+				missing[key[1]] = true
+				issues = append(issues, Issue{Path: p, Line: line(string(content), key[0]), Severity: SeverityError,
+					Message: fmt.Sprintf("message %s is missing in the %s catalog", key[1], DefaultLocale)})
+			}
+		}
+		if issue := c.lintSize(templates, p); issue != nil {
+			issues = append(issues, *issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// lintHTML checks the html is balanced, images have alt text and links use
+// HTTPS
+func lintHTML(path, content string) []Issue {
+	var issues []Issue
+	add := func(offset int, severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{Path: path, Line: strings.Count(content[:offset], "\n") + 1, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	type open struct {
+		tag    string
+		offset int
+	}
+	var stack []open
+	offset := 0
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		start := offset
+		offset += len(z.Raw())
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				add(start, SeverityError, "invalid html: %s", z.Err())
+				return issues
+			}
+			for _, o := range stack {
+				if !optionalEnd[o.tag] {
+					add(o.offset, SeverityError, "unclosed <%s>", o.tag)
+				}
+			}
+			return issues
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			if _, ok := attrs["alt"]; tag == "img" && !ok {
+				add(start, SeverityWarning, "image %s without alt text", attrs["src"])
+			}
+			for _, key := range []string{"href", "src"} {
+				if strings.HasPrefix(strings.ToLower(strings.TrimSpace(attrs[key])), "http://") {
+					add(start, SeverityWarning, "non HTTPS link %s", attrs[key])
+				}
+			}
+			if tt == html.StartTagToken && !voidElements[tag] {
+				stack = append(stack, open{tag: tag, offset: start})
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			i := len(stack) - 1
+			for i >= 0 && stack[i].tag != tag {
+				i--
+			}
+			if i < 0 {
+				if !voidElements[tag] {
+					add(start, SeverityError, "unexpected </%s>", tag)
+				}
+				continue
+			}
+			for _, o := range stack[i+1:] {
+				if !optionalEnd[o.tag] {
+					add(o.offset, SeverityError, "unclosed <%s> before </%s>", o.tag, tag)
+				}
+			}
+			stack = stack[:i]
+		}
+	}
+}
+
+// lintSize renders the email of a type template with the layout of its
+// locale and the sample data, returning an issue if it exceeds ClipSize
+func (c *TemplateCache) lintSize(templates map[string][]byte, p string) *Issue {
+	taskType := TypeByPath(p)
+	name, ok := files[taskType]
+	if !ok || taskType == Layout {
+		return nil
+	}
+	// only the templates of the type and their translations, e.g. welcome.it.html
+	locale := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), strings.TrimSuffix(name, ".html")), ".html")
+	if locale = strings.TrimPrefix(locale, "."); locale != "" {
+		if _, err := NormalizeLocale(locale); err != nil {
+			return nil
+		}
+	}
+
+	layout := templates[c.path(Layout)]
+	for _, l := range Locales(locale) {
+		if localized, ok := templates[localize(c.path(Layout), l)]; ok {
+			layout = localized
+			break
+		}
+	}
+	sample := make(map[string]string)
+	if b, ok := templates[filepath.Join(c.Dir, strings.TrimSuffix(name, ".html")+sampleExt)]; ok {
+		// sample data has already been validated
+		_ = json.Unmarshal(b, &sample)
+	}
+	args := make([]interface{}, len(Schema[taskType]))
+	for i, param := range Schema[taskType] {
+		args[i] = sample[param]
+		if sample[param] == "" {
+			args[i] = "{{" + param + "}}"
+		}
+	}
+
+	rendered, err := postProcess(fmt.Sprintf(string(layout), fmt.Sprintf(string(templates[p]), args...)))
+	if err != nil {
+		return &Issue{Path: p, Severity: SeverityError, Message: err.Error()}
+	}
+	if len(rendered) > ClipSize {
+		return &Issue{Path: p, Severity: SeverityError,
+			Message: fmt.Sprintf("rendered email is %d bytes, over the %d bytes Gmail clipping size", len(rendered), ClipSize)}
+	}
+	return nil
+}
+
+// line returns the line of the first occurrence of the substring
+func line(content, substr string) int {
+	i := strings.Index(content, substr)
+	if i < 0 {
+		return 0
+	}
+	return strings.Count(content[:i], "\n") + 1
+}
@@ -0,0 +1,51 @@
+package template_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestLint() {
+	dir := s.T().TempDir()
+	files := map[string]string{
+		// wrong number of params
+		"reset.html": `<a href="%s">Reset</a>`,
+		// unbalanced, missing alt, non HTTPS link and missing message
+		"welcome.html": "<h2>{{t:welcome.greeting}} %s</h2>\n<div><img src=\"https://example.com/logo.png\">\n" +
+			"<a href=\"%s\">{{t:welcome.missing}}</a>\n<a href=\"http://example.com\">%s</a></span>",
+		// oversized once rendered
+		"verification.html": "<h2>%s</h2><a href=\"%s\">%s</a><p>" + strings.Repeat("verify ", template.ClipSize/7) + "</p>",
+	}
+	for name, content := range files {
+		s.Nil(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	issues, err := template.Lint(dir)
+	s.Nil(err)
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	s.Len(issues, 7, strings.Join(lines, "\n"))
+
+	s.Equal(filepath.Join(dir, "reset.html"), issues[0].Path)
+	s.Equal(template.SeverityError, issues[0].Severity)
+	s.Contains(issues[1].Message, "Gmail clipping size")
+	s.Equal(filepath.Join(dir, "welcome.html"), issues[2].Path)
+	s.Equal(2, issues[2].Line)
+	s.Contains(issues[2].Message, "alt text")
+	s.Equal(template.SeverityWarning, issues[2].Severity)
+	s.Equal(2, issues[3].Line)
+	s.Contains(issues[3].Message, "unclosed <div>")
+	s.Contains(issues[4].Message, "welcome.missing")
+	s.Contains(issues[5].Message, "non HTTPS link")
+	s.Equal(4, issues[6].Line)
+	s.Contains(issues[6].Message, "unexpected </span>")
+	s.Contains(issues[6].String(), "welcome.html:4: error:")
+
+	_, err = template.Lint(filepath.Join(dir, "missing"))
+	s.NotNil(err)
+}
@@ -0,0 +1,223 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocale locale of the messages used when the requested locale, and
+// its parent, have no translation
+const DefaultLocale = "en"
+
+// catalogsDir directory of the message catalogs, a <locale>.json file per
+// locale with a flat object of messages indexed by key
+const catalogsDir = "locales"
+
+var (
+	errInvalidLocale = errors.New("invalid locale, expected a language code optionally followed by a region, e.g. it-IT")
+
+	localeRegexp = regexp.MustCompile(`^([a-zA-Z]{2,3})(?:[-_]([a-zA-Z]{2}|[0-9]{3}))?$`)
+	// messageRegexp placeholders of catalog messages in templates, e.g. {{t:layout.footer}}
+	messageRegexp = regexp.MustCompile(`{{t:([a-zA-Z0-9_.-]+)}}`)
+	// formatRegexp placeholders of values formatted with the conventions of the
+	// locale, e.g. {{date:2023-03-07}} or {{number:1234.50}}
+	formatRegexp = regexp.MustCompile(`{{(date|number):([^{}]+)}}`)
+	numberRegexp = regexp.MustCompile(`^-?[0-9]+(?:\.([0-9]+))?$`)
+)
+
+// NormalizeLocale returns the canonical form of the locale, with lowercase
+// language and uppercase region, e.g. it-IT for it_it
+func NormalizeLocale(locale string) (string, error) {
+	match := localeRegexp.FindStringSubmatch(strings.TrimSpace(locale))
+	if match == nil {
+		return "", fmt.Errorf("%w: %s", errInvalidLocale, locale)
+	}
+	if match[2] == "" {
+		return strings.ToLower(match[1]), nil
+	}
+	return strings.ToLower(match[1]) + "-" + strings.ToUpper(match[2]), nil
+}
+
+// Locales returns the fallback chain of the locale, from the most specific,
+// e.g. it-IT, it. Invalid or empty locales have no fallbacks.
+func Locales(locale string) []string {
+	normalized, err := NormalizeLocale(locale)
+	if err != nil {
+		return nil
+	}
+	if i := strings.Index(normalized, "-"); i > 0 {
+		return []string{normalized, normalized[:i]}
+	}
+	return []string{normalized}
+}
+
+// localize returns the path of the template of the locale, inserting the
+// locale before the extension, e.g. welcome.it.html
+func localize(path, locale string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + locale + ext
+}
+
+// Message returns the catalog message of the key, following the fallback
+// chain of the locale up to the default locale, empty if not translated
+func Message(key, locale string) string {
+	if cache == nil {
+		return ""
+	}
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	for _, l := range append(Locales(locale), DefaultLocale) {
+		if message, ok := cache.catalogs[l][key]; ok {
+			return message
+		}
+	}
+	return ""
+}
+
+// Subject returns the translated subject of the email type, defined in the
+// catalogs by the <type>.subject key, e.g. welcome.subject
+func Subject(taskType, locale string) string {
+	return Message(strings.TrimPrefix(taskType, "email:")+".subject", locale)
+}
+
+// Translate replaces the {{t:key}} placeholders of the content with the
+// messages of the locale, and the {{date:value}} and {{number:value}} ones
+// with the value formatted by FormatDate and FormatNumber. Placeholders of
+// missing messages or invalid values are kept.
+func Translate(content, locale string) string {
+	return translate(content, locale, func(s string) string { return s })
+}
+
+// Fill translates the content in the locale, then fills its formatting verbs
+// with the args, so that placeholders in the args are never expanded
+func Fill(content, locale string, args ...interface{}) string {
+	// translations are escaped, the content is a format string
+	return fmt.Sprintf(translate(content, locale, escapeVerbs.Replace), args...)
+}
+
+var escapeVerbs = strings.NewReplacer("%", "%%")
+
+// translate replaces the placeholders of the content as Translate, escaping
+// the replaced values
+func translate(content, locale string, escape func(string) string) string {
+	translated := messageRegexp.ReplaceAllStringFunc(content, func(placeholder string) string {
+		if message := Message(messageRegexp.FindStringSubmatch(placeholder)[1], locale); message != "" {
+			return escape(message)
+		}
+		return placeholder
+	})
+	return formatRegexp.ReplaceAllStringFunc(translated, func(placeholder string) string {
+		match := formatRegexp.FindStringSubmatch(placeholder)
+		value := strings.TrimSpace(match[2])
+		switch match[1] {
+		case "date":
+			// dates are formatted as they are, without time zone conversion
+			for _, layout := range []string{"2006-01-02", time.RFC3339} {
+				if t, err := time.Parse(layout, value); err == nil {
+					return escape(FormatDate(t, locale))
+				}
+			}
+		case "number":
+			// the number is formatted with the decimals of the value
+			if digits := numberRegexp.FindStringSubmatch(value); digits != nil {
+				n, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					return escape(FormatNumber(n, len(digits[1]), locale))
+				}
+			}
+		}
+		return placeholder
+	})
+}
+
+// FormatDate formats the date with the date.format message of the locale,
+// replacing {day}, {month} and {year}, with month names of the date.months
+// message, a comma separated list
+func FormatDate(t time.Time, locale string) string {
+	format := Message("date.format", locale)
+	if format == "" {
+		format = "{month} {day}, {year}"
+	}
+	month := t.Month().String()
+	if months := strings.Split(Message("date.months", locale), ","); len(months) == 12 {
+		month = strings.TrimSpace(months[t.Month()-1])
+	}
+	return strings.NewReplacer(
+		"{day}", strconv.Itoa(t.Day()),
+		"{month}", month,
+		"{year}", strconv.Itoa(t.Year()),
+	).Replace(format)
+}
+
+// FormatNumber formats the number with the decimals, using the number.decimal
+// and number.group separators of the locale
+func FormatNumber(value float64, decimals int, locale string) string {
+	decimal, group := Message("number.decimal", locale), Message("number.group", locale)
+	if decimal == "" {
+		decimal, group = ".", ","
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integer, fraction := formatted, ""
+	if i := strings.Index(formatted, "."); i >= 0 {
+		integer, fraction = formatted[:i], formatted[i+1:]
+	}
+	var b strings.Builder
+	if value < 0 && strings.Trim(formatted, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(decimal + fraction)
+	}
+	return b.String()
+}
+
+// isCatalog returns true if the file is a message catalog of the templates
+func isCatalog(name string) bool {
+	return path.Dir(name) == catalogsDir && path.Ext(name) == ".json"
+}
+
+// validateCatalog checks the catalog is a flat object of messages
+func validateCatalog(content []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(content, &messages); err != nil {
+		return fmt.Errorf("invalid message catalog: %w", err)
+	}
+	return nil
+}
+
+// parseCatalogs returns the messages of the catalogs of the templates,
+// indexed by locale
+func (c *TemplateCache) parseCatalogs(templates map[string][]byte) map[string]map[string]string {
+	catalogs := make(map[string]map[string]string)
+	for key, content := range templates {
+		rel, err := filepath.Rel(c.Dir, key)
+		if err != nil || !isCatalog(filepath.ToSlash(rel)) {
+			continue
+		}
+		locale, err := NormalizeLocale(strings.TrimSuffix(filepath.Base(key), ".json"))
+		if err != nil {
+			continue
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(content, &messages); err != nil {
+			continue
+		}
+		catalogs[locale] = messages
+	}
+	return catalogs
+}
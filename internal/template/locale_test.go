@@ -0,0 +1,65 @@
+package template_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestNormalizeLocale() {
+	for locale, expected := range map[string]string{"it": "it", "it_it": "it-IT", "EN-us": "en-US", "es-419": "es-419"} {
+		normalized, err := template.NormalizeLocale(locale)
+		s.Nil(err)
+		s.Equal(expected, normalized)
+	}
+	for _, locale := range []string{"", "italian", "it-ITA", "../it"} {
+		_, err := template.NormalizeLocale(locale)
+		s.NotNil(err, locale)
+	}
+	s.Equal([]string{"it-IT", "it"}, template.Locales("it_IT"))
+	s.Nil(template.Locales(""))
+}
+
+func (s *TemplateTestSuite) TestPathByTypeLocale() {
+	localized := fmt.Sprintf("%s/welcome.it.html", s.BaseDir)
+	s.Equal(localized, template.PathByType(template.WelcomeEmail, "it-IT"))
+	s.Equal(localized, template.PathByType(template.WelcomeEmail, "it"))
+	s.Equal(fmt.Sprintf("%s/welcome.html", s.BaseDir), template.PathByType(template.WelcomeEmail, "fr-FR"))
+	s.Equal(fmt.Sprintf("%s/welcome.html", s.BaseDir), template.PathByType(template.WelcomeEmail, ""))
+}
+
+func (s *TemplateTestSuite) TestTranslate() {
+	s.Equal("Benvenuto!", template.Subject(template.WelcomeEmail, "it-IT"))
+	s.Equal("Welcome!", template.Subject(template.WelcomeEmail, "fr"))
+	s.Equal("Welcome!", template.Subject(template.WelcomeEmail, ""))
+
+	html := string(s.Cache.Get(template.PathByType(template.WelcomeEmail, "it")))
+	filled := template.FillLayout(template.Fill(html, "it", "Mario", "test.org", "test.org"), "it")
+	s.Contains(filled, "Benvenuto <b>Mario.</b>")
+	s.Contains(filled, "Se il pulsante non funziona usa il seguente link: test.org")
+	s.NotContains(filled, "{{t:")
+
+	// params are substituted after translating, placeholders in them are kept
+	filled = template.FillLayout(template.Fill(html, "it", "{{t:welcome.subject}} {{date:2023-03-07}}", "test.org", "test.org"), "it")
+	s.Contains(filled, "Benvenuto <b>{{t:welcome.subject}} {{date:2023-03-07}}.</b>")
+	s.Equal("<p>Benvenuto! 100%</p>", template.Fill("<p>{{t:welcome.subject}} %s</p>", "it", "100%"))
+
+	s.Equal("<p>{{t:missing.key}}</p>", template.Translate("<p>{{t:missing.key}}</p>", "it"))
+}
+
+func (s *TemplateTestSuite) TestFormatHelpers() {
+	date := time.Date(2023, time.March, 7, 0, 0, 0, 0, time.UTC)
+	s.Equal("7 marzo 2023", template.FormatDate(date, "it-IT"))
+	s.Equal("March 7, 2023", template.FormatDate(date, "en-US"))
+
+	s.Equal("1.234.567,89", template.FormatNumber(1234567.891, 2, "it"))
+	s.Equal("1,234,567.89", template.FormatNumber(1234567.891, 2, "en"))
+	s.Equal("-999", template.FormatNumber(-999, 0, "en"))
+	s.Equal("0.00", template.FormatNumber(-0.001, 2, "en"))
+
+	content := "<p>{{date:2023-03-07}} {{date:2023-03-07T23:30:00+01:00}} {{number:1234567.50}} {{number:-42}}</p>"
+	s.Equal("<p>7 marzo 2023 7 marzo 2023 1.234.567,50 -42</p>", template.Translate(content, "it-IT"))
+	s.Equal("<p>March 7, 2023 March 7, 2023 1,234,567.50 -42</p>", template.Translate(content, "en"))
+	s.Equal("<p>{{date:tomorrow}} {{number:1e3}}</p>", template.Translate("<p>{{date:tomorrow}} {{number:1e3}}</p>", "en"))
+}
@@ -0,0 +1,223 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A4 page layout in points
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	defaultSize  = 11.0
+	lineSpacing  = 1.4
+	regularWidth = 0.5
+	boldWidth    = 0.56
+)
+
+// headingSizes font sizes of heading elements
+var headingSizes = map[string]float64{"h1": 18, "h2": 15, "h3": 13, "h4": 12, "h5": 11, "h6": 11}
+
+// blockElements elements rendered on a new line
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "tr": true, "li": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "hr": true, "section": true, "header": true, "footer": true,
+}
+
+// pdfLine line of text with a single font style
+type pdfLine struct {
+	text string
+	bold bool
+	size float64
+}
+
+// RenderPDF converts simple HTML documents, such as receipts, to a PDF
+// document. Only the text structure is kept: headings, paragraphs, lists and
+// table rows, with bold text for headings, b, strong and th elements. Text is
+// rendered with the standard Helvetica fonts, characters outside of the
+// Windows-1252 charset are replaced.
+func RenderPDF(content string) ([]byte, error) {
+	lines, err := textLines(content, wrap)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []string
+	var page strings.Builder
+	y := pageHeight - pageMargin
+	for _, line := range lines {
+		height := line.size * lineSpacing
+		if y-height < pageMargin {
+			pages, y = append(pages, page.String()), pageHeight-pageMargin
+			page.Reset()
+		}
+		y -= height
+		if line.text == "" {
+			continue
+		}
+		font := "F1"
+		if line.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&page, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, line.size, pageMargin, y, pdfString(line.text))
+	}
+	pages = append(pages, page.String())
+	return pdfDocument(pages), nil
+}
+
+// textLines extracts the text of the HTML document, a line for each block
+// element split by wrap, with empty spacer lines after paragraphs, tables and
+// headings
+func textLines(content string, wrap func(text string, bold bool, size float64) []pdfLine) ([]pdfLine, error) {
+	var lines []pdfLine
+	var current strings.Builder
+	bold, size := 0, defaultSize
+	skip := 0
+	flush := func() {
+		text := strings.Join(strings.Fields(current.String()), " ")
+		current.Reset()
+		if text == "" {
+			return
+		}
+		lines = append(lines, wrap(text, bold > 0, size)...)
+	}
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, z.Err()
+			}
+			flush()
+			return lines, nil
+		case html.TextToken:
+			if skip == 0 {
+				current.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			end := tt == html.EndTagToken
+			switch {
+			case tag == "style" || tag == "script" || tag == "head" || tag == "title":
+				if end {
+					skip--
+				} else {
+					skip++
+				}
+				continue
+			case tag == "td" || tag == "th":
+				current.WriteString("    ")
+			}
+			if blockElements[tag] {
+				flush()
+				// blank space after paragraphs, tables and headings
+				if end && (tag == "p" || tag == "table" || headingSizes[tag] > 0) {
+					lines = append(lines, pdfLine{size: defaultSize / 2})
+				}
+			}
+			if headingSizes[tag] > 0 || tag == "b" || tag == "strong" || tag == "th" {
+				if end {
+					bold--
+				} else {
+					bold++
+				}
+			}
+			if headingSizes[tag] > 0 {
+				size = defaultSize
+				if !end {
+					size = headingSizes[tag]
+				}
+			}
+		}
+	}
+}
+
+// wrap splits the text in lines fitting the page width, estimating the
+// average character width of the font
+func wrap(text string, bold bool, size float64) []pdfLine {
+	width := regularWidth
+	if bold {
+		width = boldWidth
+	}
+	max := int((pageWidth - 2*pageMargin) / (width * size))
+
+	var lines []pdfLine
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > max {
+			lines = append(lines, pdfLine{text: line, bold: bold, size: size})
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, pdfLine{text: line, bold: bold, size: size})
+}
+
+// pdfString encodes the text as Windows-1252 PDF literal string
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString("\\200")
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfDocument writes the PDF document with a page for each content stream
+func pdfDocument(pages []string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// objects 1-4: catalog, page tree and fonts, followed by page and content pairs
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i)
+		object("<< /Length %d >>\nstream\n%sendstream", len(content), content)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
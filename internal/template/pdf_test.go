@@ -0,0 +1,49 @@
+package template_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestRenderPDF() {
+	pdf, err := template.RenderPDF("<h1>Receipt</h1><p>Total: <b>€ 10.00</b> (paid)</p>")
+	s.Nil(err)
+
+	s.True(bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	s.True(bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	s.Contains(string(pdf), "/F2 18.0 Tf 50.0 766.8 Td (Receipt) Tj")
+	s.Contains(string(pdf), "(Total: \\200 10.00 \\(paid\\)) Tj")
+	s.Contains(string(pdf), "/Count 1")
+}
+
+func (s *TemplateTestSuite) TestRenderPDFPages() {
+	var content strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&content, "<p>Line %d</p>", i)
+	}
+	pdf, err := template.RenderPDF(content.String())
+	s.Nil(err)
+	s.Contains(string(pdf), "/Count 4")
+	s.Contains(string(pdf), "(Line 99) Tj")
+}
+
+func (s *TemplateTestSuite) TestAttachments() {
+	attachments, err := template.Attachments(template.WelcomeEmail, "", nil, "Test", "test.org", "test.org")
+	s.Nil(err)
+	s.Len(attachments, 1)
+	s.Equal(template.ReceiptName, attachments[0].Name)
+	s.Equal("application/pdf", attachments[0].ContentType)
+	content, err := base64.StdEncoding.DecodeString(attachments[0].Content)
+	s.Nil(err)
+	s.Contains(string(content), "(Thanks for joining, Test.) Tj")
+
+	attachments, err = template.Attachments(template.ResetEmail, "", s.event(), "test.org", "test.org")
+	s.Nil(err)
+	s.Len(attachments, 1)
+	s.Equal(template.InviteName, attachments[0].Name)
+	s.Equal("text/calendar; method=REQUEST; charset=utf-8", attachments[0].ContentType)
+}
@@ -0,0 +1,47 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// sampleExt extension of the sample data files defined alongside the
+// templates, e.g. welcome.sample.json for welcome.html
+const sampleExt = ".sample.json"
+
+// isSample returns true if the file is the sample data of a template
+func isSample(name string) bool {
+	return path.Dir(name) == "." && strings.HasSuffix(name, sampleExt)
+}
+
+// validateSample checks the sample data is a flat object of params
+func validateSample(content []byte) error {
+	var params map[string]string
+	if err := json.Unmarshal(content, &params); err != nil {
+		return fmt.Errorf("invalid sample data: %w", err)
+	}
+	return nil
+}
+
+// Sample returns the sample params of the email type used to preview its
+// template, errorx.ErrNotFound if the type has no sample data
+func Sample(taskType string) (map[string]string, error) {
+	name, ok := files[taskType]
+	if !ok || cache == nil {
+		return nil, fmt.Errorf("%w: sample data of %s", errorx.ErrNotFound, taskType)
+	}
+	content := cache.Get(filepath.Join(cache.Dir, strings.TrimSuffix(name, ".html")+sampleExt))
+	if content == nil {
+		return nil, fmt.Errorf("%w: sample data of %s", errorx.ErrNotFound, taskType)
+	}
+	var params map[string]string
+	if err := json.Unmarshal(content, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,30 @@
+package template_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestSample() {
+	params, err := template.Sample(template.WelcomeEmail)
+	s.Nil(err)
+	s.NotEmpty(params["name"])
+	s.NotEmpty(params["url"])
+
+	_, err = template.Sample(template.Layout)
+	s.True(errors.Is(err, errorx.ErrNotFound))
+	_, err = template.Sample("email:unknown")
+	s.True(errors.Is(err, errorx.ErrNotFound))
+}
+
+func (s *TemplateTestSuite) TestSampleInvalid() {
+	dir := s.T().TempDir()
+	s.Nil(os.WriteFile(filepath.Join(dir, "welcome.sample.json"), []byte(`{"name": ["Jane"]}`), 0o644))
+
+	_, err := template.LoadTemplates(dir)
+	s.NotNil(err)
+}
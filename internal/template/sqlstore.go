@@ -0,0 +1,144 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// schema tables of the SQL template store, compatible with PostgreSQL and SQLite
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS template_versions (
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		author TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (name, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS template_published (
+		name TEXT PRIMARY KEY,
+		version INTEGER NOT NULL
+	)`,
+}
+
+// SQLStore database implementation of TemplateStore
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a template store persisting versions in the database,
+// creating its tables if missing
+func NewSQLStore(ctx context.Context, db *sql.DB) (*SQLStore, error) {
+	for _, statement := range sqlSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("cannot create template tables: %w", err)
+		}
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Versions returns the versions of the template sorted by number
+func (s *SQLStore) Versions(ctx context.Context, name string) ([]Version, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT v.name, v.version, v.content, v.author, v.created_at, p.version IS NOT NULL
+		FROM template_versions v
+		LEFT JOIN template_published p ON p.name = v.name AND p.version = v.version
+		WHERE v.name = $1
+		ORDER BY v.version`, name)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := scanVersions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: template %s", errorx.ErrNotFound, name)
+	}
+	return versions, nil
+}
+
+// Create inserts the next version of the template. Concurrent creations of
+// the same version number conflict on the primary key, returning
+// errorx.ErrAlreadyExists.
+func (s *SQLStore) Create(ctx context.Context, name, content, author string) (*Version, error) {
+	if err := validateVersion(name, content); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	v := &Version{Name: name, Content: content, Author: author, CreatedAt: time.Now().UTC()}
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM template_versions WHERE name = $1`, name).Scan(&v.Version); err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO template_versions (name, version, content, author, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name, version) DO NOTHING`,
+		v.Name, v.Version, v.Content, v.Author, v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if inserted, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if inserted == 0 {
+		return nil, fmt.Errorf("%w: version %d of %s", errorx.ErrAlreadyExists, v.Version, name)
+	}
+	return v, tx.Commit()
+}
+
+// Publish replaces the published version of the template
+func (s *SQLStore) Publish(ctx context.Context, name string, version int) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	var exists int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM template_versions WHERE name = $1 AND version = $2`, name, version).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: version %d", errorx.ErrNotFound, version)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO template_published (name, version) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET version = excluded.version`, name, version)
+	return err
+}
+
+// Published returns the published version of every template
+func (s *SQLStore) Published(ctx context.Context) ([]Version, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT v.name, v.version, v.content, v.author, v.created_at, TRUE
+		FROM template_versions v
+		JOIN template_published p ON p.name = v.name AND p.version = v.version
+		ORDER BY v.name`)
+	if err != nil {
+		return nil, err
+	}
+	return scanVersions(rows)
+}
+
+func scanVersions(rows *sql.Rows) ([]Version, error) {
+	defer rows.Close()
+	var versions []Version
+	for rows.Next() {
+		var v Version
+		if err := rows.Scan(&v.Name, &v.Version, &v.Content, &v.Author, &v.CreatedAt, &v.Published); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package template_test
+
+import (
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+// sqlStore returns nil, the sqlite driver of the sql store tests requires cgo
+func (s *TemplateTestSuite) sqlStore() template.TemplateStore {
+	s.T().Log("sql store skipped, sqlite requires cgo")
+	return nil
+}
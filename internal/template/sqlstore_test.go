@@ -0,0 +1,23 @@
+//go:build cgo
+
+package template_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+// sqlStore returns a sql store backed by a temporary sqlite database, the
+// driver requires cgo
+func (s *TemplateTestSuite) sqlStore() template.TemplateStore {
+	db, err := sql.Open("sqlite3", filepath.Join(s.T().TempDir(), "templates.db"))
+	s.Require().Nil(err)
+	s.T().Cleanup(func() { db.Close() })
+	store, err := template.NewSQLStore(context.Background(), db)
+	s.Require().Nil(err)
+	return store
+}
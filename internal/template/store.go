@@ -0,0 +1,108 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+var (
+	errInvalidName     = errors.New("invalid template name, expected an html file name such as welcome.html")
+	errInvalidTemplate = errors.New("invalid template")
+	errNoRollback      = errors.New("no previous version to roll back to")
+)
+
+// Version immutable revision of a template. Name is the template file name,
+// versions are numbered from 1 in creation order.
+type Version struct {
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Author    string    `json:"author,omitempty"`
+	Published bool      `json:"published"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TemplateStore interface exports methods to persist template versions, with
+// a published version per template overriding the template files
+type TemplateStore interface {
+	// Versions returns the versions of the template sorted by number,
+	// errorx.ErrNotFound if the template has none
+	Versions(ctx context.Context, name string) ([]Version, error)
+	// Create stores a new version of the template, without publishing it
+	Create(ctx context.Context, name, content, author string) (*Version, error)
+	// Publish marks the version as the published one of the template
+	Publish(ctx context.Context, name string, version int) error
+	// Published returns the published version of every template
+	Published(ctx context.Context) ([]Version, error)
+}
+
+var store TemplateStore
+
+// SetStore assign the shared global template store
+func SetStore(s TemplateStore) {
+	store = s
+}
+
+// GetStore returns the shared global template store
+func GetStore() TemplateStore {
+	return store
+}
+
+// validateName checks the template name is an html file name
+func validateName(name string) error {
+	if name != path.Base(name) || path.Ext(name) != ".html" || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: %s", errInvalidName, name)
+	}
+	return nil
+}
+
+// validateVersion checks the template name and content of a new version
+func validateVersion(name, content string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := Validate(name, []byte(content)); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidTemplate, err)
+	}
+	return nil
+}
+
+// Rollback publishes the version preceding the published one of the template
+func Rollback(ctx context.Context, s TemplateStore, name string) (*Version, error) {
+	versions, err := s.Versions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	current := -1
+	for i, v := range versions {
+		if v.Published {
+			current = i
+		}
+	}
+	if current < 1 {
+		return nil, errNoRollback
+	}
+
+	previous := versions[current-1]
+	if err := s.Publish(ctx, name, previous.Version); err != nil {
+		return nil, err
+	}
+	previous.Published = true
+	return &previous, nil
+}
+
+// findVersion returns the version with the number, errorx.ErrNotFound if missing
+func findVersion(versions []Version, version int) (*Version, error) {
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: version %d", errorx.ErrNotFound, version)
+}
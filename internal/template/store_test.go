@@ -0,0 +1,117 @@
+package template_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) stores() map[string]template.TemplateStore {
+	fileStore, err := template.NewFileStore(s.T().TempDir())
+	s.Require().Nil(err)
+
+	stores := map[string]template.TemplateStore{"fs": fileStore}
+	if sqlStore := s.sqlStore(); sqlStore != nil {
+		stores["sql"] = sqlStore
+	}
+	return stores
+}
+
+func (s *TemplateTestSuite) TestStoreVersions() {
+	ctx := context.Background()
+	for name, store := range s.stores() {
+		s.Run(name, func() {
+			_, err := store.Versions(ctx, "welcome.html")
+			s.ErrorIs(err, errorx.ErrNotFound)
+
+			v1, err := store.Create(ctx, "welcome.html", validWelcome, "designer")
+			s.Nil(err)
+			s.Equal(1, v1.Version)
+			v2, err := store.Create(ctx, "welcome.html", "<p>%s</p><a href=\"%s\">%s</a>", "designer")
+			s.Nil(err)
+			s.Equal(2, v2.Version)
+
+			_, err = store.Create(ctx, "welcome.html", invalidWelcome, "designer")
+			s.NotNil(err)
+			_, err = store.Create(ctx, "../welcome.html", validWelcome, "designer")
+			s.NotNil(err)
+
+			versions, err := store.Versions(ctx, "welcome.html")
+			s.Nil(err)
+			s.Len(versions, 2)
+			s.Equal(validWelcome, versions[0].Content)
+			s.Equal("designer", versions[0].Author)
+			s.False(versions[0].Published || versions[1].Published)
+
+			published, err := store.Published(ctx)
+			s.Nil(err)
+			s.Empty(published)
+		})
+	}
+}
+
+func (s *TemplateTestSuite) TestStorePublishRollback() {
+	ctx := context.Background()
+	for name, store := range s.stores() {
+		s.Run(name, func() {
+			for i := 0; i < 3; i++ {
+				_, err := store.Create(ctx, "reset.html", "<a href=\"%s\">%s</a>", "")
+				s.Nil(err)
+			}
+			s.ErrorIs(store.Publish(ctx, "reset.html", 4), errorx.ErrNotFound)
+			_, err := template.Rollback(ctx, store, "reset.html")
+			s.NotNil(err)
+
+			s.Nil(store.Publish(ctx, "reset.html", 3))
+			published, err := store.Published(ctx)
+			s.Nil(err)
+			s.Len(published, 1)
+			s.Equal(3, published[0].Version)
+
+			v, err := template.Rollback(ctx, store, "reset.html")
+			s.Nil(err)
+			s.Equal(2, v.Version)
+			versions, err := store.Versions(ctx, "reset.html")
+			s.Nil(err)
+			s.True(versions[1].Published)
+			s.False(versions[2].Published)
+		})
+	}
+}
+
+func (s *TemplateTestSuite) TestStoreCache() {
+	ctx := context.Background()
+	store := s.stores()["fs"]
+	template.SetStore(store)
+	defer template.SetStore(nil)
+
+	dir := s.T().TempDir()
+	welcome := filepath.Join(dir, "welcome.html")
+	s.Nil(os.WriteFile(welcome, []byte(validWelcome), 0o644))
+
+	updated := "<h1>Hi %s</h1><a href=\"%s\">%s</a>"
+	v, err := store.Create(ctx, "welcome.html", updated, "")
+	s.Nil(err)
+	cache, err := template.LoadTemplates(dir)
+	s.Nil(err)
+	s.Equal(validWelcome, string(cache.Get(welcome)))
+
+	// published versions override the template files
+	s.Nil(store.Publish(ctx, "welcome.html", v.Version))
+	s.Nil(cache.Reload())
+	s.Equal(updated, string(cache.Get(welcome)))
+
+	// publications through other instances are applied by polling
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.Nil(cache.Poll(pollCtx, 10*time.Millisecond))
+	polled := "<h1>Hello %s</h1><a href=\"%s\">%s</a>"
+	v, err = store.Create(ctx, "welcome.html", polled, "")
+	s.Nil(err)
+	s.Nil(store.Publish(ctx, "welcome.html", v.Version))
+	s.Eventually(func() bool { return string(cache.Get(welcome)) == polled }, 2*time.Second, 10*time.Millisecond)
+}
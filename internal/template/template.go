@@ -1,8 +1,10 @@
 package template
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 )
 
 // List of template types.
@@ -14,17 +16,96 @@ const (
 	ResetEmail        = "email:reset"
 )
 
-func PathByType(taskType string) string {
-	return map[string]string{
-		Layout:            filepath.Join(cache.Dir, "layout.html"),
-		WelcomeEmail:      filepath.Join(cache.Dir, "welcome.html"),
-		VerificationEmail: filepath.Join(cache.Dir, "verification.html"),
-		ResetEmail:        filepath.Join(cache.Dir, "reset.html"),
-		// ReminderEmail: "",
-	}[taskType]
+var (
+	errInvalidVerb    = errors.New("invalid formatting verb, only %s and %% are supported")
+	errSchemaMismatch = errors.New("template params do not match the type schema")
+)
+
+// Schema params filled in order in the %s verbs of the templates of each type
+var Schema = map[string][]string{
+	Layout:            {"content"},
+	WelcomeEmail:      {"name", "url", "url"},
+	ReminderEmail:     {"name", "url", "url"},
+	VerificationEmail: {"name", "url", "url"},
+	ResetEmail:        {"url", "url"},
+}
+
+// files template file names of the email types
+var files = map[string]string{
+	Layout:            "layout.html",
+	WelcomeEmail:      "welcome.html",
+	ReminderEmail:     "reminder.html",
+	VerificationEmail: "verification.html",
+	ResetEmail:        "reset.html",
+}
+
+// PathByType returns the path of the template of the type, localized in the
+// most specific available locale, e.g. welcome.it-IT.html, welcome.it.html
+// and then welcome.html for it-IT
+func PathByType(taskType, locale string) string {
+	return cache.localized(cache.path(taskType), locale)
+}
+
+// path returns the path of the template of the type in the cache directory
+func (c *TemplateCache) path(taskType string) string {
+	name, ok := files[taskType]
+	if !ok {
+		return ""
+	}
+	return filepath.Join(c.Dir, name)
+}
+
+// localized returns the path of the template in the most specific locale
+// available in the cache, the path itself if not translated
+func (c *TemplateCache) localized(path, locale string) string {
+	if path == "" {
+		return ""
+	}
+	for _, l := range Locales(locale) {
+		if localized := localize(path, l); c.Get(localized) != nil {
+			return localized
+		}
+	}
+	return path
+}
+
+// TypeByPath returns the type of the template file, from the file name up
+// to the first dot, e.g. email:welcome for welcome.receipt.html
+func TypeByPath(path string) string {
+	name := filepath.Base(path)
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return "email:" + name
+}
+
+// Validate checks the template formatting verbs, matching their number with
+// the schema of the type of the template, if any
+func Validate(path string, content []byte) error {
+	verbs := 0
+	for i := 0; i < len(content); i++ {
+		if content[i] != '%' {
+			continue
+		}
+		i++
+		switch {
+		case i < len(content) && content[i] == '%':
+		case i < len(content) && content[i] == 's':
+			verbs++
+		default:
+			line := strings.Count(string(content[:i]), "\n") + 1
+			return fmt.Errorf("%w: line %d", errInvalidVerb, line)
+		}
+	}
+	if params, ok := Schema[TypeByPath(path)]; ok && verbs != len(params) {
+		return fmt.Errorf("%w: %d params, expected %d (%s)", errSchemaMismatch, verbs, len(params), strings.Join(params, ", "))
+	}
+	return nil
 }
 
-func FillLayout(content string) string {
-	layout := string(cache.Get(PathByType(Layout)))
-	return fmt.Sprintf(layout, content)
+// FillLayout wraps the content, already filled, in the translated layout of
+// the locale
+func FillLayout(content, locale string) string {
+	layout := string(cache.Get(PathByType(Layout, locale)))
+	return Fill(layout, locale, content)
 }
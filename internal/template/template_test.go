@@ -8,12 +8,12 @@ import (
 )
 
 func (s *TemplateTestSuite) TestPathByType() {
-	path := template.PathByType("email:layout")
+	path := template.PathByType("email:layout", "")
 	s.Equal(path, fmt.Sprintf("%s/layout.html", s.BaseDir))
 }
 
 func (s *TemplateTestSuite) TestFillLayout() {
-	path := template.PathByType("email:welcome")
+	path := template.PathByType("email:welcome", "")
 	html := string(s.Cache.Get(path))
 
 	name := "Test"
@@ -22,6 +22,6 @@ func (s *TemplateTestSuite) TestFillLayout() {
 	filledHtml := fmt.Sprintf(html, name, URL, URL)
 	s.False(strings.Contains(filledHtml, "%s"))
 
-	final := template.FillLayout(filledHtml)
+	final := template.FillLayout(filledHtml, "")
 	s.False(strings.Contains(final, "%s"))
 }
@@ -0,0 +1,29 @@
+package template
+
+import "strings"
+
+// PlainText converts the HTML email to its plain text alternative, with a
+// line for each block element and blank lines between paragraphs
+func PlainText(content string) (string, error) {
+	lines, err := textLines(content, func(text string, _ bool, _ float64) []pdfLine {
+		return []pdfLine{{text: text}}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	blank := true
+	for _, line := range lines {
+		if line.text == "" {
+			if !blank {
+				b.WriteByte('\n')
+				blank = true
+			}
+			continue
+		}
+		b.WriteString(line.text + "\n")
+		blank = false
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,13 @@
+package template_test
+
+import (
+	"github.com/xn3cr0nx/email-service/internal/template"
+)
+
+func (s *TemplateTestSuite) TestPlainText() {
+	text, err := template.PlainText(`<html><head><title>Welcome</title><style>p { color: red }</style></head>
+<body><h2>Welcome  Jane</h2><p>Thanks for
+joining us.</p><table><tr><th>Item</th><td>Plan</td></tr></table><p>Bye<br>Team</p></body></html>`)
+	s.Nil(err)
+	s.Equal("Welcome Jane\n\nThanks for joining us.\n\nItem Plan\n\nBye\nTeam", text)
+}
@@ -0,0 +1,118 @@
+package tracking
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+// pixel transparent 1x1 GIF served for opens
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// open godoc
+// @ID track-open
+//
+// @Router /t/o/{token} [get]
+// @Summary Track open
+// @Description Record the open of the tracked email, serving a transparent pixel
+// @Tags tracking
+//
+// @Produce  image/gif
+//
+// @Param token path string true "tracking token"
+//
+// @Success 200 {file} binary
+// @Failure 404 {string} string
+func OpenHandler(t *Tracker, s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		msg, err := t.Verify(c.Param("token"), Open)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		record(c, s, msg)
+
+		c.Response().Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		return c.Blob(http.StatusOK, "image/gif", pixel)
+	}
+}
+
+// click godoc
+// @ID track-click
+//
+// @Router /t/c/{token} [get]
+// @Summary Track click
+// @Description Record the click of the tracked link, redirecting to its URL
+// @Tags tracking
+//
+// @Param token path string true "tracking token"
+//
+// @Success 302
+// @Failure 404 {string} string
+func ClickHandler(t *Tracker, s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		msg, err := t.Verify(c.Param("token"), Click)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		record(c, s, msg)
+
+		return c.Redirect(http.StatusFound, msg.URL)
+	}
+}
+
+// events godoc
+// @ID tracking-events
+//
+// @Router /tracking/events [get]
+// @Summary Tracking events
+// @Description List the open and click events of the email
+// @Tags tracking
+//
+// @Produce  json
+//
+// @Param message_id query string true "Message-ID of the email"
+//
+// @Success 200 {array} Event
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 500 {string} string
+func EventsHandler(s Store) func(echo.Context) error {
+	return func(c echo.Context) error {
+		id := c.QueryParam("message_id")
+		if id == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "missing message_id")
+		}
+		events, err := s.Events(c.Request().Context(), id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, events)
+	}
+}
+
+// record stores the event of the message, failures are logged without
+// failing the request, the recipient still gets the pixel or the redirect
+func record(c echo.Context, s Store, msg *Message) {
+	event := Event{
+		MessageID: msg.ID,
+		Type:      msg.Type,
+		Recipient: msg.Recipient,
+		Kind:      msg.Kind,
+		URL:       msg.URL,
+		UserAgent: c.Request().UserAgent(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.Add(context.Background(), event); err != nil {
+		logger.Error("Tracking", err, logger.Params{"message_id": msg.ID, "kind": msg.Kind})
+	}
+}
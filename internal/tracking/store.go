@@ -0,0 +1,124 @@
+package tracking
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMessageEvents maximum number of events stored per message
+	maxMessageEvents = 100
+	// sweepInterval minimum interval between two evictions of old events
+	sweepInterval = time.Minute
+)
+
+// Event open or click of a tracked email
+type Event struct {
+	MessageID string    `json:"message_id"`
+	Type      string    `json:"type"`
+	Recipient string    `json:"recipient"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store interface exports methods to persist tracking events
+type Store interface {
+	// Add stores the event
+	Add(ctx context.Context, event Event) error
+	// Events returns the events of the message in creation order
+	Events(ctx context.Context, messageID string) ([]Event, error)
+}
+
+var store Store
+
+// SetStore assign the shared global events store
+func SetStore(s Store) {
+	store = s
+}
+
+// GetStore returns the shared global events store
+func GetStore() Store {
+	return store
+}
+
+// MemoryStore in memory implementation of Store. Only the first event of a
+// token, the kind and URL of the message, is stored, up to maxMessageEvents
+// per message, and events older than the retention are evicted.
+type MemoryStore struct {
+	lock      *sync.RWMutex
+	events    map[string][]Event
+	retention time.Duration
+	swept     time.Time
+}
+
+// NewMemoryStore returns a new in memory events store keeping events for the
+// retention, zero keeps them until restarted
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{
+		lock:      new(sync.RWMutex),
+		events:    make(map[string][]Event),
+		retention: retention,
+	}
+}
+
+// Add stores the event, ignoring repeated events of the same token and the
+// events exceeding maxMessageEvents
+func (s *MemoryStore) Add(ctx context.Context, event Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if now := time.Now(); now.Sub(s.swept) > sweepInterval {
+		s.sweep(now)
+		s.swept = now
+	}
+
+	events := s.events[event.MessageID]
+	if len(events) >= maxMessageEvents {
+		return nil
+	}
+	for _, e := range events {
+		if e.Kind == event.Kind && e.URL == event.URL {
+			return nil
+		}
+	}
+	s.events[event.MessageID] = append(events, event)
+	return nil
+}
+
+// Events returns the events of the message, empty if none
+func (s *MemoryStore) Events(ctx context.Context, messageID string) ([]Event, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	events := make([]Event, 0, len(s.events[messageID]))
+	for _, e := range s.events[messageID] {
+		if !s.expired(e, time.Now()) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// sweep removes the events older than the retention
+func (s *MemoryStore) sweep(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+	for id, events := range s.events {
+		// events are sorted by creation
+		i := 0
+		for i < len(events) && s.expired(events[i], now) {
+			i++
+		}
+		if i == len(events) {
+			delete(s.events, id)
+		} else if i > 0 {
+			s.events[id] = append([]Event{}, events[i:]...)
+		}
+	}
+}
+
+func (s *MemoryStore) expired(e Event, now time.Time) bool {
+	return s.retention > 0 && now.Sub(e.CreatedAt) > s.retention
+}
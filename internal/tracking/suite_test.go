@@ -0,0 +1,27 @@
+package tracking_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(TrackingTestSuite))
+}
+
+type TrackingTestSuite struct {
+	suite.Suite
+
+	Tracker *tracking.Tracker
+	Store   *tracking.MemoryStore
+}
+
+func (s *TrackingTestSuite) SetupTest() {
+	t, err := tracking.New(tracking.Config{BaseURL: "https://mail.test.com/", Secret: "secret", Clicks: true})
+	s.Require().Nil(err)
+	s.Tracker = t
+	s.Store = tracking.NewMemoryStore(time.Hour)
+}
@@ -0,0 +1,226 @@
+package tracking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Kinds of tracked events
+const (
+	Open  = "open"
+	Click = "click"
+)
+
+// noTrackAttr attribute excluding a link from click tracking
+const noTrackAttr = "data-notrack"
+
+var (
+	errInvalidConfig = errors.New("invalid tracking configuration, expected an absolute base URL and a secret")
+	// ErrInvalidToken returned for tokens not signed by the tracker
+	ErrInvalidToken = errors.New("invalid tracking token")
+)
+
+// Config tracking configuration. BaseURL is the public URL of the service
+// serving the tracking endpoints, Secret the key signing the tokens.
+type Config struct {
+	BaseURL string `mapstructure:"base_url"`
+	Secret  string `mapstructure:"secret"`
+	// Opens enables open tracking of every email, emails with TrackOpens are
+	// always tracked
+	Opens bool `mapstructure:"opens"`
+	// Clicks enables rewriting links to tracked redirects
+	Clicks bool `mapstructure:"clicks"`
+	// Retention of the recorded events, zero keeps them
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// Message attribution of the events of a sent email
+type Message struct {
+	ID        string `json:"m"`
+	Type      string `json:"t"`
+	Recipient string `json:"r"`
+	Kind      string `json:"k"`
+	URL       string `json:"u,omitempty"`
+}
+
+// Tracker rewrites emails to track opens and clicks through signed tokens
+type Tracker struct {
+	base   *url.URL
+	secret []byte
+	opens  bool
+	clicks bool
+}
+
+var tracker *Tracker
+
+// SetTracker assign the shared global tracker
+func SetTracker(t *Tracker) {
+	tracker = t
+}
+
+// GetTracker returns the shared global tracker, nil if tracking is disabled
+func GetTracker() *Tracker {
+	return tracker
+}
+
+// New returns a tracker of the configuration
+func New(conf Config) (*Tracker, error) {
+	base, err := url.Parse(strings.TrimSuffix(conf.BaseURL, "/"))
+	if err != nil || !base.IsAbs() || base.Host == "" || conf.Secret == "" {
+		return nil, errInvalidConfig
+	}
+	return &Tracker{base: base, secret: []byte(conf.Secret), opens: conf.Opens, clicks: conf.Clicks}, nil
+}
+
+// Track assigns a Message-ID to the email and rewrites its links to tracked
+// redirects, if click tracking is enabled and clicks is set, and adds a
+// tracking pixel if the email has TrackOpens or open tracking is enabled.
+// Clicks must not be set for emails with secrets in their links, which would
+// end up in the tracking tokens. Events are attributed to the Message-ID,
+// returned, the email type and the recipients.
+func (t *Tracker) Track(email *model.Email, taskType string, clicks bool) (string, error) {
+	id := email.Header("Message-ID")
+	if id == "" {
+		from, err := validator.ParseAddress(email.From)
+		if err != nil {
+			return "", err
+		}
+		if id, err = model.NewMessageID(from.Address[strings.LastIndex(from.Address, "@")+1:]); err != nil {
+			return "", err
+		}
+		email.Headers = append(email.Headers, model.Header{Name: "Message-ID", Value: id})
+	}
+	email.TrackOpens = email.TrackOpens || t.opens
+	clicks = clicks && t.clicks
+	if !email.TrackOpens && !clicks {
+		return id, nil
+	}
+
+	msg := Message{ID: id, Type: taskType, Recipient: email.To}
+	body, err := t.rewrite(email.HtmlBody, msg, email.TrackOpens, clicks)
+	if err != nil {
+		return "", err
+	}
+	email.HtmlBody = body
+	return id, nil
+}
+
+// rewrite replaces the http links of the html with tracked redirects, if
+// clicks is set, and adds the pixel before the end of the body, or at the
+// end of fragments, if opens is set
+func (t *Tracker) rewrite(content string, msg Message, opens, clicks bool) (string, error) {
+	var b strings.Builder
+	pixel := ""
+	if opens {
+		open := msg
+		open.Kind = Open
+		pixel = fmt.Sprintf(`<img src="%s" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`, html.EscapeString(t.URL(open)))
+	}
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return "", z.Err()
+			}
+			b.WriteString(pixel)
+			return b.String(), nil
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "body" {
+				b.WriteString(pixel)
+				pixel = ""
+			}
+		case html.StartTagToken:
+			// the raw tag is kept as is unless the link is tracked
+			raw := string(z.Raw())
+			if token := z.Token(); clicks && token.DataAtom == atom.A && t.link(&token, msg) {
+				b.WriteString(token.String())
+			} else {
+				b.WriteString(raw)
+			}
+			continue
+		}
+		b.Write(z.Raw())
+	}
+}
+
+// link replaces the http href of the anchor with a tracked redirect,
+// returning false if the link is not tracked
+func (t *Tracker) link(token *html.Token, msg Message) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == noTrackAttr {
+			return false
+		}
+	}
+	for i, attr := range token.Attr {
+		href := strings.TrimSpace(attr.Val)
+		lower := strings.ToLower(href)
+		if attr.Key != "href" || !(strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")) {
+			continue
+		}
+		msg.Kind, msg.URL = Click, href
+		token.Attr[i].Val = t.URL(msg)
+		return true
+	}
+	return false
+}
+
+// URL returns the tracking URL of the event of the message
+func (t *Tracker) URL(msg Message) string {
+	kind := "o"
+	if msg.Kind == Click {
+		kind = "c"
+	}
+	return t.base.String() + "/t/" + kind + "/" + t.token(msg)
+}
+
+// token signs the message, a base64 JSON payload followed by its HMAC
+func (t *Tracker) token(msg Message) string {
+	// the payload is a plain struct, it can't fail to marshal
+	payload, _ := json.Marshal(msg)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded))
+}
+
+func (t *Tracker) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)[:16]
+}
+
+// Verify returns the message of the token of the kind, ErrInvalidToken if it
+// is not signed by the tracker
+func (t *Tracker) Verify(token, kind string) (*Message, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(sig, t.sign(token[:i])) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Kind != kind {
+		return nil, ErrInvalidToken
+	}
+	return &msg, nil
+}
@@ -0,0 +1,170 @@
+package tracking_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/tracking"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+var tokenRegexp = regexp.MustCompile(`https://mail\.test\.com/t/([co])/([\w.-]+)`)
+
+func (s *TrackingTestSuite) TestNew() {
+	_, err := tracking.New(tracking.Config{BaseURL: "mail.test.com", Secret: "secret"})
+	s.NotNil(err)
+	_, err = tracking.New(tracking.Config{BaseURL: "https://mail.test.com"})
+	s.NotNil(err)
+}
+
+func (s *TrackingTestSuite) TestTrack() {
+	email := &model.Email{
+		From:       "Test <noreply@test.com>",
+		To:         "user@test.com",
+		TrackOpens: true,
+		HtmlBody: `<html><body><a href="https://test.com/welcome" class="button">Start</a>` +
+			`<a href="mailto:support@test.com">Support</a><a href="https://test.com/terms" data-notrack>Terms</a></body></html>`,
+	}
+	id, err := s.Tracker.Track(email, "email:welcome", true)
+	s.Nil(err)
+	s.True(strings.HasSuffix(id, "@test.com>"))
+	s.Equal(id, email.Header("Message-ID"))
+
+	s.Contains(email.HtmlBody, `<a href="mailto:support@test.com">`)
+	s.Contains(email.HtmlBody, `<a href="https://test.com/terms" data-notrack>`)
+	s.NotContains(email.HtmlBody, "https://test.com/welcome")
+	s.Contains(email.HtmlBody, `height="1" alt="" style="display:block;border:0;width:1px;height:1px"></body>`)
+
+	tokens := tokenRegexp.FindAllStringSubmatch(email.HtmlBody, -1)
+	s.Len(tokens, 2)
+	s.Equal("c", tokens[0][1])
+	msg, err := s.Tracker.Verify(tokens[0][2], tracking.Click)
+	s.Nil(err)
+	s.Equal(tracking.Message{ID: id, Type: "email:welcome", Recipient: "user@test.com", Kind: tracking.Click, URL: "https://test.com/welcome"}, *msg)
+
+	s.Equal("o", tokens[1][1])
+	msg, err = s.Tracker.Verify(tokens[1][2], tracking.Open)
+	s.Nil(err)
+	s.Equal(id, msg.ID)
+	s.Empty(msg.URL)
+
+	// the Message-ID of the email is kept and opens are not tracked
+	email = &model.Email{From: "noreply@test.com", To: "user@test.com", HtmlBody: `<p>Hi</p>`,
+		Headers: []model.Header{{Name: "Message-Id", Value: "<1@test.com>"}}}
+	id, err = s.Tracker.Track(email, "email:welcome", true)
+	s.Nil(err)
+	s.Equal("<1@test.com>", id)
+	s.Len(email.Headers, 1)
+	s.Equal(`<p>Hi</p>`, email.HtmlBody)
+}
+
+func (s *TrackingTestSuite) TestTrackWithoutClicks() {
+	email := &model.Email{
+		From:       "noreply@test.com",
+		To:         "user@test.com",
+		TrackOpens: true,
+		HtmlBody:   `<a href="https://test.com/reset?token=secret">Reset</a>`,
+	}
+	_, err := s.Tracker.Track(email, "email:reset", false)
+	s.Nil(err)
+	s.Contains(email.HtmlBody, `<a href="https://test.com/reset?token=secret">`)
+	tokens := tokenRegexp.FindAllStringSubmatch(email.HtmlBody, -1)
+	s.Require().Len(tokens, 1)
+	s.Equal("o", tokens[0][1])
+}
+
+func (s *TrackingTestSuite) TestVerify() {
+	url := s.Tracker.URL(tracking.Message{ID: "<1@test.com>", Kind: tracking.Click, URL: "https://test.com"})
+	token := tokenRegexp.FindStringSubmatch(url)[2]
+
+	_, err := s.Tracker.Verify(token, tracking.Open)
+	s.ErrorIs(err, tracking.ErrInvalidToken)
+	_, err = s.Tracker.Verify("x"+token, tracking.Click)
+	s.ErrorIs(err, tracking.ErrInvalidToken)
+	_, err = s.Tracker.Verify(strings.Split(token, ".")[0], tracking.Click)
+	s.ErrorIs(err, tracking.ErrInvalidToken)
+
+	other, err := tracking.New(tracking.Config{BaseURL: "https://mail.test.com", Secret: "other"})
+	s.Nil(err)
+	_, err = other.Verify(token, tracking.Click)
+	s.ErrorIs(err, tracking.ErrInvalidToken)
+}
+
+func (s *TrackingTestSuite) TestHandlers() {
+	e := echo.New()
+	e.GET("/t/c/:token", tracking.ClickHandler(s.Tracker, s.Store))
+	e.GET("/t/o/:token", tracking.OpenHandler(s.Tracker, s.Store))
+
+	msg := tracking.Message{ID: "<1@test.com>", Type: "email:reset", Recipient: "user@test.com", Kind: tracking.Click, URL: "https://test.com/reset"}
+	click := s.Tracker.URL(msg)
+	msg.Kind, msg.URL = tracking.Open, ""
+	open := s.Tracker.URL(msg)
+
+	req := httptest.NewRequest(http.MethodGet, strings.TrimPrefix(click, "https://mail.test.com"), nil)
+	req.Header.Set("User-Agent", "test")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	s.Equal(http.StatusFound, rec.Code)
+	s.Equal("https://test.com/reset", rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(open, "https://mail.test.com"), nil))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("image/gif", rec.Header().Get("Content-Type"))
+	s.Contains(rec.Header().Get("Cache-Control"), "no-store")
+
+	// click tokens can't be used as open tokens
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/t/o/"+tokenRegexp.FindStringSubmatch(click)[2], nil))
+	s.Equal(http.StatusNotFound, rec.Code)
+
+	events, err := s.Store.Events(context.Background(), "<1@test.com>")
+	s.Nil(err)
+	s.Len(events, 2)
+	s.Equal(tracking.Click, events[0].Kind)
+	s.Equal("https://test.com/reset", events[0].URL)
+	s.Equal("email:reset", events[0].Type)
+	s.Equal("user@test.com", events[0].Recipient)
+	s.Equal("test", events[0].UserAgent)
+	s.Equal(tracking.Open, events[1].Kind)
+}
+
+func (s *TrackingTestSuite) TestStoreReplay() {
+	ctx := context.Background()
+	event := tracking.Event{MessageID: "<1@test.com>", Kind: tracking.Click, URL: "https://test.com", CreatedAt: time.Now().UTC()}
+	s.Nil(s.Store.Add(ctx, event))
+	s.Nil(s.Store.Add(ctx, event))
+	event.URL = "https://test.com/other"
+	s.Nil(s.Store.Add(ctx, event))
+
+	events, err := s.Store.Events(ctx, "<1@test.com>")
+	s.Nil(err)
+	s.Len(events, 2)
+
+	for i := 0; i < 200; i++ {
+		event.URL = fmt.Sprintf("https://test.com/%d", i)
+		s.Nil(s.Store.Add(ctx, event))
+	}
+	events, err = s.Store.Events(ctx, "<1@test.com>")
+	s.Nil(err)
+	s.Len(events, 100)
+}
+
+func (s *TrackingTestSuite) TestStoreRetention() {
+	ctx := context.Background()
+	s.Nil(s.Store.Add(ctx, tracking.Event{MessageID: "<1@test.com>", Kind: tracking.Open, CreatedAt: time.Now().Add(-2 * time.Hour)}))
+	s.Nil(s.Store.Add(ctx, tracking.Event{MessageID: "<2@test.com>", Kind: tracking.Open, CreatedAt: time.Now()}))
+
+	events, err := s.Store.Events(ctx, "<1@test.com>")
+	s.Nil(err)
+	s.Empty(events)
+	events, err = s.Store.Events(ctx, "<2@test.com>")
+	s.Nil(err)
+	s.Len(events, 1)
+}
@@ -0,0 +1,100 @@
+package verify
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// startVerification godoc
+// @ID start-verification
+//
+// @Router /verify/start [post]
+// @Summary Start verification
+// @Description Send a verification code or link to the recipient through the verification email
+// @Tags verify
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param start body StartBody true "recipient to verify"
+//
+// @Success 201 {object} Started
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 403 {string} string
+// @Failure 422 {string} string
+// @Failure 429 {string} string
+// @Failure 500 {string} string
+func StartHandler(v *Verifier, m provider.Mailer) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(StartBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		started, err := v.Start(c.Request().Context(), m, b)
+		if err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusCreated, started)
+	}
+}
+
+// checkVerification godoc
+// @ID check-verification
+//
+// @Router /verify/check [post]
+// @Summary Check verification
+// @Description Check the code, or link secret, of a started verification
+// @Tags verify
+//
+// @Accept  json
+// @Produce  json
+//
+// @Param check body CheckBody true "code to check"
+//
+// @Success 200 {object} Verified
+// @Security ApiKeyAuth
+// @Security BearerAuth
+//
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Failure 404 {string} string
+// @Failure 422 {string} string
+// @Failure 429 {string} string
+// @Failure 500 {string} string
+func CheckHandler(v *Verifier) func(echo.Context) error {
+	return func(c echo.Context) error {
+		b := new(CheckBody)
+		if err := validator.Struct(&c, b); err != nil {
+			return err
+		}
+
+		verified, err := v.Check(c.Request().Context(), b)
+		if err != nil {
+			return httpError(err)
+		}
+		return c.JSON(http.StatusOK, verified)
+	}
+}
+
+func httpError(err error) error {
+	switch {
+	case errors.Is(err, errorx.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "verification not found or expired")
+	case errors.Is(err, ErrInvalidCode):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrTooManyAttempts), errors.Is(err, ErrCooldown):
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	}
+	return email.HTTPError(err)
+}
@@ -0,0 +1,121 @@
+package verify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+)
+
+// Challenge pending verification of a recipient, the code is stored hashed
+type Challenge struct {
+	ID        string
+	Recipient string
+	Method    string
+	Hash      []byte
+	Attempts  int
+	ResendAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Store interface exports methods to persist verification challenges
+type Store interface {
+	// Set stores the challenge, replacing any pending challenge of the same
+	// recipient and keeping its attempts, ErrCooldown if the pending
+	// challenge cannot be resent yet
+	Set(ctx context.Context, c *Challenge) error
+	// Get returns the challenge of the id, errorx.ErrNotFound if it doesn't
+	// exist or it expired
+	Get(ctx context.Context, id string) (*Challenge, error)
+	// Attempt atomically increments the wrong attempts of the challenge,
+	// returning them
+	Attempt(ctx context.Context, id string) (int, error)
+	// Delete removes the challenge of the id
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore in memory implementation of Store
+type MemoryStore struct {
+	lock        *sync.RWMutex
+	challenges  map[string]Challenge
+	byRecipient map[string]string
+}
+
+// NewMemoryStore returns a new in memory challenges store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lock:        new(sync.RWMutex),
+		challenges:  make(map[string]Challenge),
+		byRecipient: make(map[string]string),
+	}
+}
+
+// Set stores the challenge, replacing any pending challenge of the same
+// recipient and keeping its attempts, ErrCooldown if the pending challenge
+// cannot be resent yet. Expired challenges are removed.
+func (s *MemoryStore) Set(ctx context.Context, c *Challenge) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for id, challenge := range s.challenges {
+		if !challenge.ExpiresAt.After(now) {
+			s.delete(id)
+		}
+	}
+	if id, ok := s.byRecipient[c.Recipient]; ok {
+		pending := s.challenges[id]
+		if pending.ResendAt.After(now) {
+			return ErrCooldown
+		}
+		c.Attempts = pending.Attempts
+		s.delete(id)
+	}
+	s.challenges[c.ID] = *c
+	s.byRecipient[c.Recipient] = c.ID
+	return nil
+}
+
+// Get returns the challenge of the id, errorx.ErrNotFound if it doesn't exist
+// or it expired
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Challenge, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	c, ok := s.challenges[id]
+	if !ok || !c.ExpiresAt.After(time.Now()) {
+		return nil, errorx.ErrNotFound
+	}
+	return &c, nil
+}
+
+// Attempt increments the wrong attempts of the challenge, returning them
+func (s *MemoryStore) Attempt(ctx context.Context, id string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.challenges[id]
+	if !ok {
+		return 0, errorx.ErrNotFound
+	}
+	c.Attempts++
+	s.challenges[id] = c
+	return c.Attempts, nil
+}
+
+// Delete removes the challenge of the id
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.challenges[id]; !ok {
+		return errorx.ErrNotFound
+	}
+	s.delete(id)
+	return nil
+}
+
+func (s *MemoryStore) delete(id string) {
+	if c, ok := s.challenges[id]; ok && s.byRecipient[c.Recipient] == id {
+		delete(s.byRecipient, c.Recipient)
+	}
+	delete(s.challenges, id)
+}
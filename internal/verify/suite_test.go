@@ -0,0 +1,42 @@
+package verify_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/xn3cr0nx/email-service/internal/environment"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/internal/verify"
+	"github.com/xn3cr0nx/email-service/pkg/logger"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(VerifyTestSuite))
+}
+
+type VerifyTestSuite struct {
+	suite.Suite
+
+	Verifier *verify.Verifier
+	Mailer   *mockMailer
+}
+
+func (s *VerifyTestSuite) SetupSuite() {
+	logger.Setup()
+	env := environment.New()
+	env.Sender = "noreply@test.com"
+	environment.Set(env)
+
+	// the default templates are used, the directory doesn't exist
+	dir := "templates_test"
+	_, err := template.NewTemplateCache(&dir)
+	s.Require().Nil(err)
+}
+
+func (s *VerifyTestSuite) SetupTest() {
+	v, err := verify.New("https://frontend.com", verify.Config{TTL: time.Minute, Length: 6, MaxAttempts: 3, Path: "/verify"}, verify.NewMemoryStore())
+	s.Require().Nil(err)
+	s.Verifier = v
+	s.Mailer = new(mockMailer)
+}
@@ -0,0 +1,306 @@
+package verify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/email"
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/provider"
+	"github.com/xn3cr0nx/email-service/internal/template"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+// Verification methods, a numeric code typed by the recipient or a link
+const (
+	Code = "code"
+	Link = "link"
+)
+
+var (
+	errInvalidMethod = errors.New("invalid verification method, expected code or link")
+	errInvalidName   = errors.New("invalid name parameter")
+	errInvalidPath   = errors.New("invalid path, expected an absolute path")
+
+	// ErrInvalidCode returned when the code doesn't match the challenge
+	ErrInvalidCode = errors.New("invalid verification code")
+	// ErrTooManyAttempts returned when the challenge exceeded the attempts,
+	// the recipient cannot be verified until it expires
+	ErrTooManyAttempts = errors.New("too many verification attempts")
+	// ErrCooldown returned when a verification of the recipient has been
+	// started less than Cooldown ago
+	ErrCooldown = errors.New("verification recently sent, retry later")
+)
+
+// Config verification configuration. Codes are Length digits long, links and
+// codes expire after TTL and are invalidated after MaxAttempts wrong checks,
+// counted across the verifications of the recipient until expired. A new
+// verification of the recipient can be started after Cooldown. Path is the
+// default frontend path of the verification page. Codes are hashed with
+// Secret, a random one is generated if empty.
+type Config struct {
+	TTL         time.Duration `mapstructure:"ttl"`
+	Length      int           `mapstructure:"length"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Cooldown    time.Duration `mapstructure:"cooldown"`
+	Path        string        `mapstructure:"path"`
+	Secret      string        `mapstructure:"secret"`
+}
+
+// Verifier starts and checks verifications of recipients
+type Verifier struct {
+	host   *url.URL
+	conf   Config
+	secret []byte
+	store  Store
+}
+
+var verifier *Verifier
+
+// Set assign the shared global verifier
+func Set(v *Verifier) {
+	verifier = v
+}
+
+// Get returns the shared global verifier, nil if verification is disabled
+func Get() *Verifier {
+	return verifier
+}
+
+// New returns a verifier sending links to the frontend host and storing
+// challenges in the store
+func New(host string, conf Config, store Store) (*Verifier, error) {
+	base, err := url.Parse(strings.TrimSuffix(host, "/"))
+	if err != nil || !base.IsAbs() || base.Host == "" {
+		return nil, fmt.Errorf("%w: invalid frontend host %s", errorx.ErrConfig, host)
+	}
+	if conf.TTL <= 0 || conf.Length < 4 || conf.Length > 10 || conf.MaxAttempts <= 0 {
+		return nil, fmt.Errorf("%w: verification requires a ttl, a length between 4 and 10 and max_attempts", errorx.ErrConfig)
+	}
+	if conf.Cooldown < 0 || conf.Cooldown > conf.TTL {
+		return nil, fmt.Errorf("%w: verification cooldown must be between 0 and the ttl", errorx.ErrConfig)
+	}
+	if !validPath(conf.Path) {
+		return nil, fmt.Errorf("%w: %v", errorx.ErrConfig, errInvalidPath)
+	}
+
+	secret := []byte(conf.Secret)
+	if len(secret) == 0 {
+		// challenges don't survive restarts, like the memory store
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Verifier{host: base, conf: conf, secret: secret, store: store}, nil
+}
+
+// StartBody request body to start the verification of a recipient
+type StartBody struct {
+	// Sender: id of the sender identity, takes precedence over From
+	Sender string `json:"sender,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to"`
+	Name   string `json:"name"`
+	// Method: code (default) or link
+	Method string `json:"method,omitempty"`
+	// Path: frontend path of the verification page, the challenge id, and
+	// the secret of links, are added as id and code query params
+	Path    string `json:"path,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	// Locale: recipient locale of the template and subject, e.g. it-IT
+	Locale string `json:"locale,omitempty"`
+}
+
+// Started challenge of a started verification
+type Started struct {
+	ID        string    `json:"id"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CheckBody request body to check a verification code
+type CheckBody struct {
+	ID   string `json:"id" validate:"required"`
+	Code string `json:"code" validate:"required"`
+}
+
+// Verified recipient of a checked verification
+type Verified struct {
+	ID        string `json:"id"`
+	Recipient string `json:"recipient"`
+}
+
+// Start creates a challenge for the recipient, replacing the pending one, if
+// any, and sends it through the verification email. Codes are sent in the
+// body, and in the default subject, with a link to the verification page,
+// links carry a long secret. The wrong attempts of the pending challenge are
+// kept, ErrTooManyAttempts is returned if exhausted, and ErrCooldown if it
+// has been started less than Cooldown ago.
+func (v *Verifier) Start(ctx context.Context, m provider.Mailer, b *StartBody) (*Started, error) {
+	var errs validator.FieldErrors
+	// a challenge verifies a single recipient
+	to, err := validator.ParseAddressList(b.To, 1)
+	if err != nil {
+		errs.Add("to", err)
+	}
+	if b.Method == "" {
+		b.Method = Code
+	}
+	if b.Method != Code && b.Method != Link {
+		errs.Add("method", errInvalidMethod)
+	}
+	if b.Name == "" || len(b.Name) > 200 {
+		errs.Add("name", errInvalidName)
+	}
+	if b.Path == "" {
+		b.Path = v.conf.Path
+	}
+	if !validPath(b.Path) {
+		errs.Add("path", errInvalidPath)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	id, err := random(16)
+	if err != nil {
+		return nil, err
+	}
+	code, err := v.code(b.Method)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"id": {id}}
+	if b.Method == Link {
+		query.Set("code", code)
+	}
+	u, _ := url.Parse(v.host.String() + b.Path)
+	params := u.Query()
+	for key, value := range query {
+		params[key] = value
+	}
+	u.RawQuery = params.Encode()
+
+	body := &email.VerificationEmailBody{
+		Sender:  b.Sender,
+		From:    b.From,
+		To:      b.To,
+		Subject: b.Subject,
+		Locale:  b.Locale,
+		Params:  email.VerificationEmailParams{Name: b.Name, URL: u.String()},
+	}
+	if b.Method == Code {
+		// the code is always in the body, custom subjects may not show it
+		body.Params.Code = code
+		if body.Subject == "" {
+			body.Subject = codeSubject(code, b.Locale)
+		}
+	}
+	message, err := body.Email(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	c := &Challenge{
+		ID:        id,
+		Recipient: strings.ToLower(to[0].Address),
+		Method:    b.Method,
+		Hash:      v.hash(id, code),
+		ResendAt:  now.Add(v.conf.Cooldown),
+		ExpiresAt: now.Add(v.conf.TTL),
+	}
+	if err := v.store.Set(ctx, c); err != nil {
+		return nil, err
+	}
+	if c.Attempts >= v.conf.MaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	if err := email.Deliver(ctx, template.VerificationEmail, m, message); err != nil {
+		_ = v.store.Delete(ctx, id)
+		return nil, err
+	}
+	return &Started{ID: id, Method: c.Method, ExpiresAt: c.ExpiresAt}, nil
+}
+
+// Check verifies the code of the challenge, which is removed once verified.
+// After MaxAttempts wrong codes the challenge is kept until expired, so that
+// new verifications of the recipient keep the attempts, returning
+// ErrTooManyAttempts. Expired or unknown challenges return errorx.ErrNotFound.
+func (v *Verifier) Check(ctx context.Context, b *CheckBody) (*Verified, error) {
+	c, err := v.store.Get(ctx, b.ID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Attempts >= v.conf.MaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	if hmac.Equal(c.Hash, v.hash(c.ID, strings.TrimSpace(b.Code))) {
+		if err := v.store.Delete(ctx, c.ID); err != nil {
+			// a concurrent check already verified, or exhausted, the challenge
+			return nil, err
+		}
+		return &Verified{ID: c.ID, Recipient: c.Recipient}, nil
+	}
+
+	attempts, err := v.store.Attempt(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if attempts >= v.conf.MaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	return nil, ErrInvalidCode
+}
+
+// code returns a random numeric code or link secret
+func (v *Verifier) code(method string) (string, error) {
+	if method == Link {
+		return random(32)
+	}
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(v.conf.Length)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", v.conf.Length, n), nil
+}
+
+func (v *Verifier) hash(id, code string) []byte {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(id + ":" + code))
+	return mac.Sum(nil)
+}
+
+// codeSubject returns the verification.code message of the locale with the
+// code, or the code followed by the verification subject if missing
+func codeSubject(code, locale string) string {
+	if message := template.Message("verification.code", locale); message != "" {
+		return strings.ReplaceAll(message, "{code}", code)
+	}
+	return code + " - " + template.Subject(template.VerificationEmail, locale)
+}
+
+func validPath(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && u.Fragment == ""
+}
+
+// random returns n random bytes, base64 URL encoded
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
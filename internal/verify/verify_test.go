@@ -0,0 +1,177 @@
+package verify_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/internal/errorx"
+	"github.com/xn3cr0nx/email-service/internal/verify"
+	"github.com/xn3cr0nx/email-service/pkg/model"
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+var (
+	codeRegexp = regexp.MustCompile(`\b\d{6}\b`)
+	linkRegexp = regexp.MustCompile(`https://frontend\.com/verify\?code=([\w-]+)&amp;id=([\w-]+)`)
+)
+
+type mockMailer struct {
+	sent []model.Email
+}
+
+func (m *mockMailer) Send(ctx context.Context, email model.Email) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+func (m *mockMailer) SendBatch(ctx context.Context, email model.Email, recipients []model.Recipient) ([]model.SendResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *VerifyTestSuite) TestNew() {
+	_, err := verify.New("frontend.com", verify.Config{TTL: time.Minute, Length: 6, MaxAttempts: 3, Path: "/verify"}, verify.NewMemoryStore())
+	s.NotNil(err)
+	_, err = verify.New("https://frontend.com", verify.Config{TTL: time.Minute, Length: 2, MaxAttempts: 3, Path: "/verify"}, verify.NewMemoryStore())
+	s.NotNil(err)
+	_, err = verify.New("https://frontend.com", verify.Config{TTL: time.Minute, Length: 6, MaxAttempts: 3, Path: "verify"}, verify.NewMemoryStore())
+	s.NotNil(err)
+	_, err = verify.New("https://frontend.com", verify.Config{TTL: time.Minute, Length: 6, MaxAttempts: 3, Cooldown: time.Hour, Path: "/verify"}, verify.NewMemoryStore())
+	s.NotNil(err)
+}
+
+func (s *VerifyTestSuite) TestCode() {
+	ctx := context.Background()
+	started, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "User@Test.com", Name: "Jane"})
+	s.Nil(err)
+	s.Equal(verify.Code, started.Method)
+	s.WithinDuration(time.Now().Add(time.Minute), started.ExpiresAt, time.Second)
+
+	s.Require().Len(s.Mailer.sent, 1)
+	sent := s.Mailer.sent[0]
+	s.Equal("User@test.com", sent.To)
+	s.Contains(sent.Subject, "Your verification code is ")
+	code := codeRegexp.FindString(sent.Subject)
+	s.Len(code, 6)
+	s.Contains(sent.HtmlBody, "https://frontend.com/verify?id="+started.ID)
+	s.Contains(sent.HtmlBody, "Your verification code is "+code)
+
+	_, err = s.Verifier.Check(ctx, &verify.CheckBody{ID: started.ID, Code: "x"})
+	s.ErrorIs(err, verify.ErrInvalidCode)
+	verified, err := s.Verifier.Check(ctx, &verify.CheckBody{ID: started.ID, Code: " " + code})
+	s.Nil(err)
+	s.Equal(&verify.Verified{ID: started.ID, Recipient: "user@test.com"}, verified)
+
+	// codes are single use
+	_, err = s.Verifier.Check(ctx, &verify.CheckBody{ID: started.ID, Code: code})
+	s.ErrorIs(err, errorx.ErrNotFound)
+}
+
+func (s *VerifyTestSuite) TestCodeSubject() {
+	ctx := context.Background()
+	started, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane", Subject: "Welcome back"})
+	s.Nil(err)
+
+	s.Require().Len(s.Mailer.sent, 1)
+	sent := s.Mailer.sent[0]
+	s.Equal("Welcome back", sent.Subject)
+	code := codeRegexp.FindString(sent.TextBody)
+	s.Len(code, 6)
+	s.Contains(sent.HtmlBody, code)
+	s.Nil(check(s.Verifier, started.ID, code))
+}
+
+func (s *VerifyTestSuite) TestLink() {
+	ctx := context.Background()
+	started, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane", Method: verify.Link, Locale: "it"})
+	s.Nil(err)
+
+	s.Require().Len(s.Mailer.sent, 1)
+	s.Equal("Verifica il tuo indirizzo email", s.Mailer.sent[0].Subject)
+	match := linkRegexp.FindStringSubmatch(s.Mailer.sent[0].HtmlBody)
+	s.Require().Len(match, 3)
+	s.Equal(started.ID, match[2])
+
+	_, err = s.Verifier.Check(ctx, &verify.CheckBody{ID: started.ID, Code: match[1]})
+	s.Nil(err)
+}
+
+func (s *VerifyTestSuite) TestAttempts() {
+	ctx := context.Background()
+	started, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.Nil(err)
+	code := codeRegexp.FindString(s.Mailer.sent[0].Subject)
+
+	s.ErrorIs(check(s.Verifier, started.ID, "x"), verify.ErrInvalidCode)
+	s.ErrorIs(check(s.Verifier, started.ID, "x"), verify.ErrInvalidCode)
+	s.ErrorIs(check(s.Verifier, started.ID, "x"), verify.ErrTooManyAttempts)
+	s.ErrorIs(check(s.Verifier, started.ID, code), verify.ErrTooManyAttempts)
+
+	// restarting doesn't reset the attempts
+	_, err = s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.ErrorIs(err, verify.ErrTooManyAttempts)
+	s.Len(s.Mailer.sent, 1)
+}
+
+func (s *VerifyTestSuite) TestRestartAttempts() {
+	ctx := context.Background()
+	first, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.Nil(err)
+	s.ErrorIs(check(s.Verifier, first.ID, "x"), verify.ErrInvalidCode)
+	s.ErrorIs(check(s.Verifier, first.ID, "x"), verify.ErrInvalidCode)
+
+	second, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "User@test.com", Name: "Jane"})
+	s.Nil(err)
+	s.ErrorIs(check(s.Verifier, second.ID, "x"), verify.ErrTooManyAttempts)
+}
+
+func (s *VerifyTestSuite) TestCooldown() {
+	ctx := context.Background()
+	v, err := verify.New("https://frontend.com", verify.Config{TTL: time.Minute, Length: 6, MaxAttempts: 3, Cooldown: time.Minute, Path: "/verify"}, verify.NewMemoryStore())
+	s.Require().Nil(err)
+
+	first, err := v.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.Nil(err)
+	_, err = v.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.ErrorIs(err, verify.ErrCooldown)
+	s.Len(s.Mailer.sent, 1)
+	// the pending challenge is still valid
+	s.Nil(check(v, first.ID, codeRegexp.FindString(s.Mailer.sent[0].Subject)))
+
+	_, err = v.Start(ctx, s.Mailer, &verify.StartBody{To: "other@test.com", Name: "Jane"})
+	s.Nil(err)
+}
+
+func (s *VerifyTestSuite) TestRestart() {
+	ctx := context.Background()
+	first, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.Nil(err)
+	second, err := s.Verifier.Start(ctx, s.Mailer, &verify.StartBody{To: "user@test.com", Name: "Jane"})
+	s.Nil(err)
+
+	s.ErrorIs(check(s.Verifier, first.ID, codeRegexp.FindString(s.Mailer.sent[0].Subject)), errorx.ErrNotFound)
+	s.Nil(check(s.Verifier, second.ID, codeRegexp.FindString(s.Mailer.sent[1].Subject)))
+}
+
+func (s *VerifyTestSuite) TestStartInvalid() {
+	_, err := s.Verifier.Start(context.Background(), s.Mailer, &verify.StartBody{To: "invalid", Method: "sms", Path: "verify"})
+	var fields validator.FieldErrors
+	s.True(errors.As(err, &fields))
+	s.Len(fields, 4)
+
+	_, err = s.Verifier.Start(context.Background(), s.Mailer, &verify.StartBody{To: "invalid", Name: "Jane"})
+	s.True(errors.As(err, &fields))
+	s.Equal("to", fields[0].Field)
+
+	_, err = s.Verifier.Start(context.Background(), s.Mailer, &verify.StartBody{To: "user@test.com, other@test.com", Name: "Jane"})
+	s.True(errors.As(err, &fields))
+	s.Len(fields, 1)
+	s.Equal("to", fields[0].Field)
+	s.Empty(s.Mailer.sent)
+}
+
+func check(v *verify.Verifier, id, code string) error {
+	_, err := v.Check(context.Background(), &verify.CheckBody{ID: id, Code: code})
+	return err
+}
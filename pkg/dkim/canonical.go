@@ -0,0 +1,116 @@
+package dkim
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var errMalformedMessage = errors.New("malformed message, missing header and body separator")
+
+// header raw header field, including folding and the trailing CRLF
+type header struct {
+	name string
+	raw  string
+}
+
+// crlf normalizes the message line endings to CRLF
+func crlf(message []byte) []byte {
+	message = bytes.ReplaceAll(message, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(message, []byte("\n"), []byte("\r\n"))
+}
+
+// split splits the message in header fields and body
+func split(message []byte) ([]header, []byte, error) {
+	end := bytes.Index(message, []byte("\r\n\r\n"))
+	if end == -1 {
+		return nil, nil, errMalformedMessage
+	}
+	var headers []header
+	for _, line := range strings.SplitAfter(string(message[:end+2]), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1].raw += line
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			return nil, nil, errMalformedMessage
+		}
+		headers = append(headers, header{name: strings.TrimSpace(line[:colon]), raw: line})
+	}
+	return headers, message[end+4:], nil
+}
+
+// relaxedHeader canonicalizes the header field using the relaxed algorithm
+// of RFC 6376 section 3.4.2, including the trailing CRLF
+func relaxedHeader(raw string) string {
+	colon := strings.Index(raw, ":")
+	name := strings.ToLower(strings.TrimSpace(raw[:colon]))
+	value := strings.NewReplacer("\r\n", "").Replace(raw[colon+1:])
+	return name + ":" + strings.TrimSpace(compressSpaces(value)) + "\r\n"
+}
+
+// relaxedBody canonicalizes the body using the relaxed algorithm of RFC 6376
+// section 3.4.4
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(compressSpaces(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// compressSpaces reduces each sequence of whitespaces to a single space
+func compressSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// selectHeaders returns the canonicalized header fields listed in names.
+// Repeated names select instances from the bottom up, missing instances are
+// signed as empty, as required by RFC 6376 section 5.4.2.
+func selectHeaders(headers []header, names []string) string {
+	used := make(map[string]int)
+	var b strings.Builder
+	for _, name := range names {
+		key := strings.ToLower(name)
+		skip := used[key]
+		used[key]++
+		for i := len(headers) - 1; i >= 0; i-- {
+			if strings.ToLower(headers[i].name) != key {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			b.WriteString(relaxedHeader(headers[i].raw))
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+// Package dkim signs RFC 5322 messages with DomainKeys Identified Mail
+// signatures (RFC 6376), using RSA-SHA256 or Ed25519-SHA256 (RFC 8463) keys
+// and relaxed/relaxed canonicalization.
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	errNoKey          = errors.New("no DKIM key active")
+	errInvalidKey     = errors.New("invalid DKIM private key")
+	errUnsupportedKey = errors.New("unsupported DKIM key type, only RSA and Ed25519 are supported")
+	errMissingFrom    = errors.New("message without From header")
+)
+
+// DefaultHeaders header fields signed when present in the message. From is
+// listed twice to prevent the addition of another From header.
+var DefaultHeaders = []string{
+	"From", "From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// Key private key published under the selector of the signing domain
+type Key struct {
+	Selector string
+	Signer   crypto.Signer
+	// NotBefore: time from which the key is used, allowing to configure the
+	// next key before rotation
+	NotBefore time.Time
+}
+
+// Signer signs messages for a domain with its most recent active key
+type Signer struct {
+	Domain  string
+	Headers []string
+	keys    []Key
+}
+
+// NewSigner returns a signer of the domain rotating among the keys according
+// to their NotBefore time
+func NewSigner(domain string, keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errNoKey
+	}
+	for _, key := range keys {
+		if _, err := algorithm(key.Signer); err != nil {
+			return nil, err
+		}
+	}
+	sorted := append([]Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].NotBefore.After(sorted[j].NotBefore)
+	})
+	return &Signer{Domain: strings.ToLower(domain), Headers: DefaultHeaders, keys: sorted}, nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS#1 or PKCS#8 RSA or Ed25519 private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errInvalidKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidKey, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errUnsupportedKey
+	}
+	if _, err := algorithm(signer); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// Key returns the most recent key active at the given time
+func (s *Signer) Key(now time.Time) (*Key, error) {
+	for i := range s.keys {
+		if !s.keys[i].NotBefore.After(now) {
+			return &s.keys[i], nil
+		}
+	}
+	return nil, errNoKey
+}
+
+// Sign returns the message, with CRLF line endings, and the DKIM-Signature
+// header field prepended
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	return s.SignAt(message, time.Now())
+}
+
+// SignAt signs the message with the key active at the given time, which is
+// also used as signature timestamp
+func (s *Signer) SignAt(message []byte, now time.Time) ([]byte, error) {
+	key, err := s.Key(now)
+	if err != nil {
+		return nil, err
+	}
+	message = crlf(message)
+	headers, body, err := split(message)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]int)
+	for _, h := range headers {
+		present[strings.ToLower(h.name)]++
+	}
+	if present["from"] == 0 {
+		return nil, errMissingFrom
+	}
+	var names []string
+	for _, name := range s.Headers {
+		// From is listed even when already signed, to prevent its addition
+		lower := strings.ToLower(name)
+		if present[lower] > 0 || lower == "from" {
+			present[lower]--
+			names = append(names, lower)
+		}
+	}
+
+	alg, _ := algorithm(key.Signer)
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		alg, s.Domain, key.Selector, now.Unix(), strings.Join(names, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	signature, err := sign(key.Signer, selectHeaders(headers, names)+strings.TrimSuffix(relaxedHeader("DKIM-Signature:"+value), "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	field := "DKIM-Signature: " + strings.ReplaceAll(value, "; ", ";\r\n ") + fold(base64.StdEncoding.EncodeToString(signature)) + "\r\n"
+	return append([]byte(field), message...), nil
+}
+
+// algorithm returns the DKIM signing algorithm of the key
+func algorithm(key crypto.Signer) (string, error) {
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		return "rsa-sha256", nil
+	case ed25519.PublicKey:
+		return "ed25519-sha256", nil
+	}
+	return "", errUnsupportedKey
+}
+
+// sign signs the SHA-256 digest of data. Ed25519 keys sign the digest itself,
+// as defined in RFC 8463.
+func sign(key crypto.Signer, data string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(data))
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return key.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	}
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// fold splits the base64 value in lines of at most 72 characters
+func fold(value string) string {
+	var b strings.Builder
+	for len(value) > 72 {
+		b.WriteString(value[:72] + "\r\n ")
+		value = value[72:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
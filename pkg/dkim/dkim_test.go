@@ -0,0 +1,128 @@
+package dkim_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/xn3cr0nx/email-service/pkg/dkim"
+)
+
+const message = "From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+func lookup(key crypto.PublicKey) dkim.PublicKeyLookup {
+	return func(domain, selector string) (crypto.PublicKey, error) {
+		return key, nil
+	}
+}
+
+// TestVerifyRFC8463 verifies the Ed25519 example signature of RFC 8463 appendix A.3
+func (s *DKIMTestSuite) TestVerifyRFC8463() {
+	signed := "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+		" d=football.example.com; i=@football.example.com;\r\n" +
+		" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+		" subject : date : message-id : from : subject : date;\r\n" +
+		" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+		" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+		" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" + message
+	key, err := base64.StdEncoding.DecodeString("11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=")
+	s.Nil(err)
+
+	s.Nil(dkim.Verify([]byte(signed), lookup(ed25519.PublicKey(key))))
+	s.NotNil(dkim.Verify([]byte(strings.Replace(signed, "Joe.", "Jim.", 1)), lookup(ed25519.PublicKey(key))))
+}
+
+func (s *DKIMTestSuite) TestSign() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Nil(err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Nil(err)
+
+	for _, key := range []crypto.Signer{rsaKey, edKey} {
+		signer, err := dkim.NewSigner("football.example.com", []dkim.Key{{Selector: "brisbane", Signer: key}})
+		s.Nil(err)
+
+		signed, err := signer.Sign([]byte(strings.ReplaceAll(message, "\r\n", "\n")))
+		s.Nil(err)
+		s.True(strings.HasPrefix(string(signed), "DKIM-Signature: v=1;"))
+		s.Contains(string(signed), "bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;")
+		s.Nil(dkim.Verify(signed, lookup(key.Public())))
+
+		// relaxed canonicalization tolerates whitespace changes
+		relaxed := strings.Replace(string(signed), "Subject: Is dinner ready?", "Subject:  Is dinner   ready? ", 1)
+		s.Nil(dkim.Verify([]byte(relaxed), lookup(key.Public())))
+
+		tampered := strings.Replace(string(signed), "Subject: Is dinner ready?", "Subject: Is lunch ready?", 1)
+		s.NotNil(dkim.Verify([]byte(tampered), lookup(key.Public())))
+
+		added := strings.Replace(string(signed), "From: Joe", "From: Eve <eve@evil.example>\r\nFrom: Joe", 1)
+		s.NotNil(dkim.Verify([]byte(added), lookup(key.Public())))
+	}
+
+	signer, err := dkim.NewSigner("football.example.com", []dkim.Key{{Selector: "brisbane", Signer: edKey}})
+	s.Nil(err)
+	_, err = signer.Sign([]byte("To: suzie@shopping.example.net\r\n\r\nHi.\r\n"))
+	s.NotNil(err)
+}
+
+func (s *DKIMTestSuite) TestKeyRotation() {
+	_, current, err := ed25519.GenerateKey(rand.Reader)
+	s.Nil(err)
+	_, next, err := ed25519.GenerateKey(rand.Reader)
+	s.Nil(err)
+
+	rotation := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	signer, err := dkim.NewSigner("example.com", []dkim.Key{
+		{Selector: "next", Signer: next, NotBefore: rotation},
+		{Selector: "current", Signer: current},
+	})
+	s.Nil(err)
+
+	key, err := signer.Key(rotation.Add(-time.Second))
+	s.Nil(err)
+	s.Equal("current", key.Selector)
+	key, err = signer.Key(rotation)
+	s.Nil(err)
+	s.Equal("next", key.Selector)
+
+	signed, err := signer.SignAt([]byte(message), rotation)
+	s.Nil(err)
+	s.Contains(string(signed), "s=next;")
+	s.Nil(dkim.Verify(signed, lookup(next.Public())))
+	s.NotNil(dkim.Verify(signed, lookup(current.Public())))
+}
+
+func (s *DKIMTestSuite) TestParsePrivateKey() {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	s.Nil(err)
+	key, err := dkim.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	s.Nil(err)
+	s.IsType(&rsa.PrivateKey{}, key)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Nil(err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	s.Nil(err)
+	key, err = dkim.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	s.Nil(err)
+	s.IsType(ed25519.PrivateKey{}, key)
+
+	_, err = dkim.ParsePrivateKey([]byte("invalid"))
+	s.NotNil(err)
+}
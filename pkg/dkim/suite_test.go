@@ -0,0 +1,15 @@
+package dkim_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(DKIMTestSuite))
+}
+
+type DKIMTestSuite struct {
+	suite.Suite
+}
@@ -0,0 +1,105 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	errMissingSignature     = errors.New("message without DKIM-Signature header")
+	errInvalidSignature     = errors.New("invalid DKIM signature")
+	errBodyHashMismatch     = errors.New("DKIM body hash mismatch")
+	errUnsupportedSignature = errors.New("unsupported DKIM signature, only relaxed/relaxed canonicalization is supported")
+)
+
+// signatureValue matches the b= tag value of a DKIM-Signature header field
+var signatureValue = regexp.MustCompile(`(^|;)(\s*b\s*=)[^;]*`)
+
+// PublicKeyLookup returns the public key published by the domain under the
+// selector, usually from DNS
+type PublicKeyLookup func(domain, selector string) (crypto.PublicKey, error)
+
+// Verify checks the topmost DKIM-Signature of the message
+func Verify(message []byte, lookup PublicKeyLookup) error {
+	headers, body, err := split(crlf(message))
+	if err != nil {
+		return err
+	}
+	var signature *header
+	for i := range headers {
+		if strings.EqualFold(headers[i].name, "DKIM-Signature") {
+			signature = &headers[i]
+			break
+		}
+	}
+	if signature == nil {
+		return errMissingSignature
+	}
+
+	tags := parseTags(signature.raw[strings.Index(signature.raw, ":")+1:])
+	if tags["v"] != "1" || tags["d"] == "" || tags["s"] == "" || tags["h"] == "" || tags["b"] == "" {
+		return errInvalidSignature
+	}
+	if tags["c"] != "relaxed/relaxed" || tags["l"] != "" {
+		return errUnsupportedSignature
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return errBodyHashMismatch
+	}
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	key, err := lookup(tags["d"], tags["s"])
+	if err != nil {
+		return err
+	}
+
+	names := strings.Split(tags["h"], ":")
+	unsigned := signatureValue.ReplaceAllString(signature.raw, "$1$2")
+	data := selectHeaders(headers, names) + strings.TrimSuffix(relaxedHeader(unsigned), "\r\n")
+	digest := sha256.Sum256([]byte(data))
+
+	switch tags["a"] {
+	case "rsa-sha256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errInvalidSignature
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidSignature, err)
+		}
+	case "ed25519-sha256":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, digest[:], sig) {
+			return errInvalidSignature
+		}
+	default:
+		return errUnsupportedSignature
+	}
+	return nil
+}
+
+// parseTags parses the tag=value list of a DKIM-Signature, removing
+// whitespaces from values
+func parseTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		eq := strings.Index(tag, "=")
+		if eq == -1 {
+			continue
+		}
+		name := strings.TrimSpace(tag[:eq])
+		tags[name] = strings.Join(strings.Fields(tag[eq+1:]), "")
+	}
+	return tags
+}
@@ -18,9 +18,9 @@ func (s *LoggerTestSuite) TestLogger() {
 	logger.Error("test", errors.New("testing Error function"), logger.Params{"error": "error"})
 
 	s.Equal(3, len(hook.Entries))
-	s.Equal(string(logrus.InfoLevel), string(hook.Entries[0].Level))
-	s.Equal(string(logrus.WarnLevel), string(hook.Entries[1].Level))
-	s.Equal(string(logrus.ErrorLevel), string(hook.LastEntry().Level))
+	s.Equal(logrus.InfoLevel.String(), hook.Entries[0].Level.String())
+	s.Equal(logrus.WarnLevel.String(), hook.Entries[1].Level.String())
+	s.Equal(logrus.ErrorLevel.String(), hook.LastEntry().Level.String())
 	s.Equal("testing Error function", hook.LastEntry().Message)
 
 	hook.Reset()
@@ -21,7 +21,8 @@ var (
 	errMissingName = errors.New("service name not provided")
 )
 
-var meter = metric.NewNoopMeterProvider().Meter("")
+// meter is nil until configured, so that NewMeter configures the exporter once
+var meter metric.Meter
 
 // Config tracer configuration
 type Config struct {
@@ -0,0 +1,362 @@
+package model
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxLineLength maximum length of base64 encoded lines, as defined by RFC 2045
+const maxLineLength = 76
+
+// ErrInvalidAttachment returned when the content of an attachment is not valid base64
+var ErrInvalidAttachment = errors.New("invalid base64 attachment content")
+
+// part MIME entity, either a leaf with header and encoded body or a
+// multipart container of children parts
+type part struct {
+	header   textproto.MIMEHeader
+	body     []byte
+	subtype  string
+	children []part
+}
+
+// NewMessageID returns a unique Message-ID of the domain
+func NewMessageID(domain string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain), nil
+}
+
+// BuildMIME renders the email as RFC 5322 message. Text and html bodies are
+// multipart/alternative, attachments with a ContentID are inlined in a
+// multipart/related with the html body and the other ones are wrapped in a
+// multipart/mixed. Date and Message-ID can be set through Headers, the date
+// defaults to the current time. Bcc is not included in the message.
+func BuildMIME(email Email) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+	for _, field := range []struct{ name, value string }{
+		{"From", email.From},
+		{"To", email.To},
+		{"Cc", email.Cc},
+		{"Reply-To", email.ReplyTo},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value, err := formatAddressList(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", field.name, err)
+		}
+		header(field.name, value)
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+
+	date := true
+	for _, h := range email.Headers {
+		date = date && !strings.EqualFold(h.Name, "Date")
+	}
+	if date {
+		header("Date", time.Now().Format(time.RFC1123Z))
+	}
+	for _, h := range email.Headers {
+		header(h.Name, mime.QEncoding.Encode("utf-8", h.Value))
+	}
+	header("MIME-Version", "1.0")
+
+	root, err := tree(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := root.write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tree returns the MIME structure of the email content
+func tree(email Email) (part, error) {
+	var inline, attached []part
+	for _, attachment := range email.Attachments {
+		p, err := attachmentPart(attachment, email.HtmlBody != "")
+		if err != nil {
+			return part{}, err
+		}
+		if attachment.ContentID != "" && email.HtmlBody != "" {
+			inline = append(inline, p)
+		} else {
+			attached = append(attached, p)
+		}
+	}
+
+	var body part
+	switch {
+	case email.HtmlBody != "":
+		body = textPart("text/html", email.HtmlBody)
+		if len(inline) > 0 {
+			body = part{subtype: "related", children: append([]part{body}, inline...)}
+		}
+		if email.TextBody != "" {
+			body = part{subtype: "alternative", children: []part{textPart("text/plain", email.TextBody), body}}
+		}
+	default:
+		body = textPart("text/plain", email.TextBody)
+	}
+
+	if len(attached) > 0 {
+		body = part{subtype: "mixed", children: append([]part{body}, attached...)}
+	}
+	return body, nil
+}
+
+func textPart(contentType, text string) part {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(text))
+	w.Close()
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+func attachmentPart(attachment Attachment, html bool) (part, error) {
+	content, err := base64.StdEncoding.DecodeString(attachment.Content)
+	if err != nil {
+		return part{}, fmt.Errorf("%w: %s", ErrInvalidAttachment, attachment.Name)
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if attachment.ContentID != "" && html {
+		disposition = "inline"
+	}
+
+	h := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Name})},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if h.Get("Content-Type") == "" {
+		return part{}, fmt.Errorf("invalid content type of attachment %s: %s", attachment.Name, attachment.ContentType)
+	}
+	if attachment.ContentID != "" {
+		h.Set("Content-ID", "<"+strings.Trim(attachment.ContentID, "<>")+">")
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var body bytes.Buffer
+	for len(encoded) > maxLineLength {
+		body.WriteString(encoded[:maxLineLength] + "\r\n")
+		encoded = encoded[maxLineLength:]
+	}
+	body.WriteString(encoded)
+	return part{header: h, body: body.Bytes()}, nil
+}
+
+// write writes the part header fields, a blank line and its body. Multipart
+// bodies are written recursively, separated by a random boundary.
+func (p part) write(w io.Writer) error {
+	if p.subtype == "" {
+		if err := writeHeader(w, p.header); err != nil {
+			return err
+		}
+		_, err := w.Write(p.body)
+		return err
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	header := textproto.MIMEHeader{"Content-Type": {mime.FormatMediaType("multipart/"+p.subtype, map[string]string{"boundary": boundary})}}
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+	for i, child := range p.children {
+		delimiter := "--" + boundary + "\r\n"
+		if i > 0 {
+			delimiter = "\r\n" + delimiter
+		}
+		if _, err := io.WriteString(w, delimiter); err != nil {
+			return err
+		}
+		if err := child.write(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\r\n--"+boundary+"--\r\n")
+	return err
+}
+
+// writeHeader writes the header fields sorted by name, followed by a blank line
+func writeHeader(w io.Writer, header textproto.MIMEHeader) error {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", name, value); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// formatAddressList parses the comma separated addresses and formats them,
+// encoding non ASCII display names according to RFC 2047
+func formatAddressList(list string) (string, error) {
+	addresses, err := mail.ParseAddressList(list)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = address.String()
+	}
+	return strings.Join(formatted, ", "), nil
+}
+
+// ParseMIME parses an RFC 5322 message into an email, decoding text and html
+// bodies and attachments. Header fields other than addresses and subject are
+// returned in Headers, body line breaks are returned as LF.
+func ParseMIME(data []byte) (Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return Email{}, err
+	}
+	decoder := new(mime.WordDecoder)
+	email := Email{
+		From:    msg.Header.Get("From"),
+		To:      msg.Header.Get("To"),
+		Cc:      msg.Header.Get("Cc"),
+		ReplyTo: msg.Header.Get("Reply-To"),
+	}
+	for _, address := range []*string{&email.From, &email.To, &email.Cc, &email.ReplyTo} {
+		if *address == "" {
+			continue
+		}
+		if *address, err = parseAddressList(*address); err != nil {
+			return Email{}, err
+		}
+	}
+	if email.Subject, err = decoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		return Email{}, err
+	}
+	for name, values := range msg.Header {
+		switch name {
+		case "From", "To", "Cc", "Reply-To", "Subject", "Mime-Version", "Content-Type", "Content-Transfer-Encoding":
+			continue
+		}
+		for _, value := range values {
+			decoded, err := decoder.DecodeHeader(value)
+			if err != nil {
+				return Email{}, err
+			}
+			email.Headers = append(email.Headers, Header{Name: name, Value: decoded})
+		}
+	}
+	sort.SliceStable(email.Headers, func(i, j int) bool { return email.Headers[i].Name < email.Headers[j].Name })
+
+	if err := parsePart(&email, textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return Email{}, err
+	}
+	return email, nil
+}
+
+// parsePart decodes the MIME part into the email, walking multipart parts
+func parsePart(email *Email, header textproto.MIMEHeader, body io.Reader) error {
+	contentType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		contentType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(contentType, "multipart/") {
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := parsePart(email, p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	switch {
+	case disposition == "" && contentType == "text/plain" && email.TextBody == "":
+		email.TextBody = strings.ReplaceAll(string(content), "\r\n", "\n")
+	case disposition == "" && contentType == "text/html" && email.HtmlBody == "":
+		email.HtmlBody = strings.ReplaceAll(string(content), "\r\n", "\n")
+	default:
+		name := dispositionParams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		email.Attachments = append(email.Attachments, Attachment{
+			Name:        name,
+			Content:     base64.StdEncoding.EncodeToString(content),
+			ContentType: contentType,
+			ContentID:   strings.Trim(header.Get("Content-ID"), "<>"),
+		})
+	}
+	return nil
+}
+
+// parseAddressList decodes the address list header, formatting it with UTF-8
+// display names
+func parseAddressList(list string) (string, error) {
+	addresses, err := mail.ParseAddressList(list)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		if address.Name == "" {
+			formatted[i] = address.Address
+		} else {
+			formatted[i] = fmt.Sprintf("%q <%s>", address.Name, address.Address)
+		}
+	}
+	return strings.Join(formatted, ", "), nil
+}
@@ -0,0 +1,95 @@
+package model_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+// structure returns the content types of the message parts, nested multiparts
+// enclosed in brackets
+func structure(contentType string, body io.Reader) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return mediaType
+	}
+	var parts []string
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err != nil {
+			break
+		}
+		parts = append(parts, structure(p.Header.Get("Content-Type"), p))
+	}
+	return mediaType + "[" + strings.Join(parts, " ") + "]"
+}
+
+func (s *ModelTestSuite) TestBuildMIME() {
+	email := model.Email{
+		From:     "Zoë Info <info@test.com>",
+		To:       "user@test.com, \"Other, User\" <other@test.com>",
+		Bcc:      "hidden@test.com",
+		Subject:  "Ciao è arrivato",
+		TextBody: "Hello,\nwelcome!",
+		HtmlBody: "<p>Hello, <img src=\"cid:logo\"> welcome! " + strings.Repeat("long line ", 20) + "</p>",
+		Headers:  []model.Header{{Name: "Message-ID", Value: "<1@test.com>"}, {Name: "X-Tag", Value: "welcome"}},
+		Attachments: []model.Attachment{
+			{Name: "logo.png", Content: base64.StdEncoding.EncodeToString([]byte("\x89PNG fake")), ContentType: "image/png", ContentID: "logo"},
+			{Name: "fattura è.pdf", Content: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("%PDF"), 100)), ContentType: "application/pdf"},
+		},
+	}
+	data, err := model.BuildMIME(email)
+	s.Nil(err)
+
+	for _, line := range strings.Split(string(data), "\r\n") {
+		s.LessOrEqual(len(line), 998)
+		s.NotContains(line, "\n")
+	}
+	s.NotContains(string(data), "hidden@test.com")
+	s.Contains(string(data), "Subject: =?utf-8?q?Ciao_=C3=A8_arrivato?=")
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	s.Nil(err)
+	s.NotEmpty(msg.Header.Get("Date"))
+	s.Equal("<1@test.com>", msg.Header.Get("Message-ID"))
+	s.Equal("multipart/mixed[multipart/alternative[text/plain multipart/related[text/html image/png]] application/pdf]",
+		structure(msg.Header.Get("Content-Type"), msg.Body))
+
+	parsed, err := model.ParseMIME(data)
+	s.Nil(err)
+	s.Equal("\"Zoë Info\" <info@test.com>", parsed.From)
+	s.Equal("user@test.com, \"Other, User\" <other@test.com>", parsed.To)
+	s.Equal(email.Subject, parsed.Subject)
+	s.Equal(email.TextBody, parsed.TextBody)
+	s.Equal(email.HtmlBody, parsed.HtmlBody)
+	s.Contains(parsed.Headers, model.Header{Name: "X-Tag", Value: "welcome"})
+	s.Equal(email.Attachments, parsed.Attachments)
+}
+
+func (s *ModelTestSuite) TestBuildMIMESinglePart() {
+	data, err := model.BuildMIME(model.Email{From: "info@test.com", To: "user@test.com", Subject: "Hi", TextBody: "Plain text"})
+	s.Nil(err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	s.Nil(err)
+	s.Equal("text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+	s.Equal("quoted-printable", msg.Header.Get("Content-Transfer-Encoding"))
+
+	parsed, err := model.ParseMIME(data)
+	s.Nil(err)
+	s.Equal("Plain text", parsed.TextBody)
+	s.Empty(parsed.HtmlBody)
+	s.Empty(parsed.Attachments)
+
+	_, err = model.BuildMIME(model.Email{From: "info@test.com", To: "user@test.com", Attachments: []model.Attachment{{Name: "a", Content: "not base64!"}}})
+	s.NotNil(err)
+	_, err = model.BuildMIME(model.Email{From: "invalid", To: "user@test.com"})
+	s.NotNil(err)
+}
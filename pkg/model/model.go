@@ -1,5 +1,11 @@
 package model
 
+import (
+	"html"
+	"net/mail"
+	"strings"
+)
+
 type Email struct {
 	From string `json:"from,omitempty"`
 	// To: REQUIRED Recipient email address. Multiple addresses are comma separated. Max 50.
@@ -36,6 +42,17 @@ type Header struct {
 	Value string
 }
 
+// Header returns the value of the first header of the name, case
+// insensitive, or an empty string if the email has no such header
+func (e Email) Header(name string) string {
+	for _, h := range e.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
 // Attachment is an optional encoded file to send along with an email
 type Attachment struct {
 	// Name: attachment name
@@ -47,3 +64,74 @@ type Attachment struct {
 	// ContentId: populate for inlining images with the images cid
 	ContentID string `json:",omitempty"`
 }
+
+// SendResult is the outcome of sending an email to a single recipient
+type SendResult struct {
+	// To: recipient email address
+	To string `json:"to"`
+	// MessageID: provider message identifier, if the email has been accepted
+	MessageID string `json:"message_id,omitempty"`
+	// Error: reason why the email has not been sent to the recipient
+	Error string `json:"error,omitempty"`
+}
+
+// Recipient of a personalized batch email
+type Recipient struct {
+	// To: recipient email address
+	To string `json:"to"`
+	// Name: recipient display name
+	Name string `json:"name,omitempty"`
+	// Params: values replacing the corresponding placeholders in the email
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Address returns the recipient address, including the display name if present
+func (r Recipient) Address() string {
+	if r.Name == "" {
+		return r.To
+	}
+	return (&mail.Address{Name: r.Name, Address: r.To}).String()
+}
+
+// Placeholder returns the placeholder replaced by the value of the param key
+// when an email is personalized
+func Placeholder(key string) string {
+	return "{{" + key + "}}"
+}
+
+// HTMLKey returns the key of the HTML escaped value of the param, substituted
+// in HTML bodies by providers replacing the same placeholders in every part
+// of the email
+func HTMLKey(key string) string {
+	return key + "_html"
+}
+
+// HTMLPlaceholders returns a copy of the email with the placeholders of the
+// keys in the HTML body replaced by the placeholders of their HTML keys
+func HTMLPlaceholders(email Email, keys []string) Email {
+	pairs := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, Placeholder(key), Placeholder(HTMLKey(key)))
+	}
+	email.HtmlBody = strings.NewReplacer(pairs...).Replace(email.HtmlBody)
+	return email
+}
+
+// Personalize returns a copy of the email addressed to the recipient, with
+// placeholders in subject and bodies replaced by the recipient params, HTML
+// escaped in the HTML body
+func Personalize(email Email, r Recipient) Email {
+	pairs := make([]string, 0, 2*len(r.Params))
+	escaped := make([]string, 0, 2*len(r.Params))
+	for key, value := range r.Params {
+		pairs = append(pairs, Placeholder(key), value)
+		escaped = append(escaped, Placeholder(key), html.EscapeString(value))
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	email.To = r.Address()
+	email.Subject = replacer.Replace(email.Subject)
+	email.HtmlBody = strings.NewReplacer(escaped...).Replace(email.HtmlBody)
+	email.TextBody = replacer.Replace(email.TextBody)
+	return email
+}
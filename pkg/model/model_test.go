@@ -0,0 +1,46 @@
+package model_test
+
+import (
+	"github.com/xn3cr0nx/email-service/pkg/model"
+)
+
+func (s *ModelTestSuite) TestPersonalize() {
+	email := model.Email{
+		From:     "info@test.com",
+		Subject:  "Welcome {{name}}",
+		HtmlBody: "<a href=\"{{url}}\">{{name}}</a>",
+	}
+	personalized := model.Personalize(email, model.Recipient{
+		To:     "user@test.com",
+		Name:   "Test User",
+		Params: map[string]string{"name": "Test", "url": "https://test.com"},
+	})
+
+	s.Equal("\"Test User\" <user@test.com>", personalized.To)
+	s.Equal("Welcome Test", personalized.Subject)
+	s.Equal("<a href=\"https://test.com\">Test</a>", personalized.HtmlBody)
+	s.Equal("Welcome {{name}}", email.Subject)
+
+	// params are HTML escaped in the HTML body only
+	personalized = model.Personalize(email, model.Recipient{
+		To:     "user@test.com",
+		Params: map[string]string{"name": "<b>Tom & Jerry</b>", "url": "https://test.com/?a=1&b=\"2\""},
+	})
+	s.Equal("Welcome <b>Tom & Jerry</b>", personalized.Subject)
+	s.Equal("<a href=\"https://test.com/?a=1&amp;b=&#34;2&#34;\">&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;</a>", personalized.HtmlBody)
+}
+
+func (s *ModelTestSuite) TestHTMLPlaceholders() {
+	email := model.HTMLPlaceholders(model.Email{
+		Subject:  "Welcome {{name}}",
+		HtmlBody: "<p>{{name}} {{url}}</p>",
+		TextBody: "{{name}}",
+	}, []string{"name"})
+	s.Equal("Welcome {{name}}", email.Subject)
+	s.Equal("<p>{{name_html}} {{url}}</p>", email.HtmlBody)
+	s.Equal("{{name}}", email.TextBody)
+}
+
+func (s *ModelTestSuite) TestRecipientAddress() {
+	s.Equal("user@test.com", model.Recipient{To: "user@test.com"}.Address())
+}
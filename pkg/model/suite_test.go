@@ -0,0 +1,15 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(ModelTestSuite))
+}
+
+type ModelTestSuite struct {
+	suite.Suite
+}
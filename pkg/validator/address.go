@@ -0,0 +1,99 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	errMissingAddress  = errors.New("missing email address")
+	errInvalidDomain   = errors.New("invalid email address domain")
+	errTooManyAddress  = errors.New("too many email addresses")
+	errDuplicatedEmail = errors.New("duplicated email address")
+	errInvalidURL      = errors.New("invalid absolute http(s) URL")
+)
+
+// ParseAddress parses a single RFC 5322 address, optionally including a
+// display name. Internationalized domains are converted to punycode.
+func ParseAddress(address string) (*mail.Address, error) {
+	if strings.TrimSpace(address) == "" {
+		return nil, errMissingAddress
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeDomain(parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// ParseAddressList parses a comma separated list of RFC 5322 addresses,
+// returning an error if it contains more than max addresses or duplicates.
+// Zero max means no limit.
+func ParseAddressList(list string, max int) ([]*mail.Address, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, errMissingAddress
+	}
+	addresses, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, err
+	}
+	if max > 0 && len(addresses) > max {
+		return nil, fmt.Errorf("%w: %d, max %d", errTooManyAddress, len(addresses), max)
+	}
+
+	seen := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		if err := normalizeDomain(address); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(address.Address)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s", errDuplicatedEmail, address.Address)
+		}
+		seen[key] = true
+	}
+	return addresses, nil
+}
+
+// FormatAddressList formats the addresses as comma separated list, encoding
+// display names according to RFC 2047 when needed
+func FormatAddressList(addresses []*mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		if address.Name == "" {
+			formatted[i] = address.Address
+		} else {
+			formatted[i] = address.String()
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// normalizeDomain converts the address domain to its ASCII form, checking it
+// is a valid fully qualified host name
+func normalizeDomain(address *mail.Address) error {
+	at := strings.LastIndex(address.Address, "@")
+	domain, err := idna.Lookup.ToASCII(address.Address[at+1:])
+	if err != nil || !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return fmt.Errorf("%w: %s", errInvalidDomain, address.Address[at+1:])
+	}
+	address.Address = address.Address[:at+1] + strings.ToLower(domain)
+	return nil
+}
+
+// ValidateURL checks the value is an absolute http or https URL
+func ValidateURL(value string) error {
+	u, err := url.ParseRequestURI(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errInvalidURL
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package validator_test
+
+import (
+	"errors"
+
+	"github.com/xn3cr0nx/email-service/pkg/validator"
+)
+
+func (s *ValidatorTestSuite) TestParseAddress() {
+	address, err := validator.ParseAddress("Test User <user@test.com>")
+	s.Nil(err)
+	s.Equal("Test User", address.Name)
+	s.Equal("user@test.com", address.Address)
+
+	address, err = validator.ParseAddress("user@bücher.example")
+	s.Nil(err)
+	s.Equal("user@xn--bcher-kva.example", address.Address)
+
+	for _, invalid := range []string{"", "user", "user@", "user@localhost", "user@test.com, other@test.com"} {
+		_, err := validator.ParseAddress(invalid)
+		s.NotNil(err, invalid)
+	}
+}
+
+func (s *ValidatorTestSuite) TestParseAddressList() {
+	addresses, err := validator.ParseAddressList("a@test.com, \"User, B\" <b@TEST.com>", 0)
+	s.Nil(err)
+	s.Len(addresses, 2)
+	s.Equal("b@test.com", addresses[1].Address)
+	s.Equal("a@test.com, \"User, B\" <b@test.com>", validator.FormatAddressList(addresses))
+
+	_, err = validator.ParseAddressList("a@test.com, b@test.com", 1)
+	s.NotNil(err)
+	_, err = validator.ParseAddressList("a@test.com, A@test.com", 0)
+	s.NotNil(err)
+	_, err = validator.ParseAddressList("a@test.com, b", 0)
+	s.NotNil(err)
+}
+
+func (s *ValidatorTestSuite) TestValidateURL() {
+	s.Nil(validator.ValidateURL("https://test.com/verify?token=1"))
+	s.NotNil(validator.ValidateURL("/verify"))
+	s.NotNil(validator.ValidateURL("javascript:alert(1)"))
+	s.NotNil(validator.ValidateURL(""))
+}
+
+func (s *ValidatorTestSuite) TestFieldErrors() {
+	var errs validator.FieldErrors
+	s.Nil(errs.Err())
+
+	errs.Add("to", errors.New("missing email address"))
+	errs.Add("subject", errors.New("invalid subject"))
+	err := errs.Err()
+	s.NotNil(err)
+	s.Equal("to: missing email address; subject: invalid subject", err.Error())
+
+	var fields validator.FieldErrors
+	s.True(errors.As(err, &fields))
+	s.Len(fields, 2)
+}
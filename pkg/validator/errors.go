@@ -0,0 +1,33 @@
+package validator
+
+import "strings"
+
+// FieldError describes why a request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors list of invalid request fields, returned as a single error
+type FieldErrors []FieldError
+
+// Add appends the error of the field to the list
+func (e *FieldErrors) Add(field string, err error) {
+	*e = append(*e, FieldError{Field: field, Message: err.Error()})
+}
+
+// Err returns the list as error, nil if empty
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,15 @@
+package validator_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSuite(t *testing.T) {
+	suite.Run(t, new(ValidatorTestSuite))
+}
+
+type ValidatorTestSuite struct {
+	suite.Suite
+}